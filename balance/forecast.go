@@ -0,0 +1,165 @@
+package balance
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/money"
+)
+
+// DefaultWindow is a period of readings the forecast is based on by default.
+const DefaultWindow = 7 * 24 * time.Hour
+
+// ErrNotEnoughReadings is returned when the readings don't span any time.
+var ErrNotEnoughReadings = errors.New("balance: at least two readings at different times are required")
+
+// Forecast represents spending over the period of readings and the time when funds run out at that rate.
+type Forecast struct {
+	From        time.Time     `json:"from"`         // From is a time of the first reading.
+	To          time.Time     `json:"to"`           // To is a time of the last reading.
+	Readings    int           `json:"readings"`     // Readings is a number of readings.
+	Spent       money.Money   `json:"spent"`        // Spent is a sum of all decreases of the available funds.
+	TopUps      money.Money   `json:"top_ups"`      // TopUps is a sum of all increases of the available funds.
+	PerHour     money.Money   `json:"per_hour"`     // PerHour is an average spending per hour.
+	PerDay      money.Money   `json:"per_day"`      // PerDay is an average spending per day.
+	Available   money.Money   `json:"available"`    // Available is the balance plus the credit of the last reading.
+	TimeLeft    time.Duration `json:"time_left"`    // TimeLeft is a time from the last reading until funds run out.
+	ExhaustedAt time.Time     `json:"exhausted_at"` // ExhaustedAt is a time when funds run out (zero if nothing is spent).
+}
+
+// RunsOut returns true if funds run out at the current spending rate.
+func (f Forecast) RunsOut() bool {
+	return !f.ExhaustedAt.IsZero()
+}
+
+// ForecastReadings computes the spending rate of the readings ordered by time and forecasts when funds run out.
+// Top-ups are not counted as spending, so the rate is not distorted by replenishments.
+func ForecastReadings(readings []Reading) (Forecast, error) {
+	if len(readings) < 2 {
+		return Forecast{}, ErrNotEnoughReadings
+	}
+
+	first, last := readings[0], readings[len(readings)-1]
+	elapsed := last.At.Sub(first.At)
+	if elapsed <= 0 {
+		return Forecast{}, ErrNotEnoughReadings
+	}
+
+	currency := last.Balance.Currency
+	forecast := Forecast{
+		From:      first.At,
+		To:        last.At,
+		Readings:  len(readings),
+		Spent:     money.Zero(currency),
+		TopUps:    money.Zero(currency),
+		Available: last.Balance.Available(),
+	}
+
+	previous := first.Balance.Available()
+	for _, reading := range readings[1:] {
+		current := reading.Balance.Available()
+		change, err := current.Sub(previous)
+		if err != nil {
+			return Forecast{}, err
+		}
+
+		if change.Sign() < 0 {
+			forecast.Spent, err = forecast.Spent.Sub(change)
+		} else {
+			forecast.TopUps, err = forecast.TopUps.Add(change)
+		}
+		if err != nil {
+			return Forecast{}, err
+		}
+		previous = current
+	}
+
+	var err error
+	if forecast.PerHour, err = forecast.Spent.MulFloat(float64(time.Hour) / float64(elapsed)); err != nil {
+		return Forecast{}, err
+	}
+	if forecast.PerDay, err = forecast.Spent.MulFloat(float64(24*time.Hour) / float64(elapsed)); err != nil {
+		return Forecast{}, err
+	}
+
+	switch {
+	case forecast.Available.Sign() <= 0:
+		forecast.ExhaustedAt = last.At
+	case forecast.Spent.Sign() > 0:
+		// the rate is computed from exact amounts, so only the duration is approximate
+		left := float64(forecast.Available.Nanos()) / float64(forecast.Spent.Nanos()) * float64(elapsed)
+		if left >= math.MaxInt64 {
+			break
+		}
+		forecast.TimeLeft = time.Duration(left)
+		forecast.ExhaustedAt = last.At.Add(forecast.TimeLeft)
+	}
+
+	return forecast, nil
+}
+
+// ForecastHistory forecasts by the readings of the history received during the window before now
+// (DefaultWindow if the window is not positive).
+func ForecastHistory(history History, window time.Duration, now time.Time) (Forecast, error) {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+
+	readings, err := history.Readings(now.Add(-window), time.Time{})
+	if err != nil {
+		return Forecast{}, err
+	}
+
+	return ForecastReadings(readings)
+}
+
+// ForecastHandler serves the forecast of the history as JSON. The period of readings is set
+// by the "window" query parameter in the Go duration format, like "72h" (DefaultWindow by default).
+type ForecastHandler struct {
+	Now func() time.Time // Now returns current time (time.Now by default).
+
+	history History
+}
+
+// NewForecastHandler creates new ForecastHandler of the history.
+func NewForecastHandler(history History) *ForecastHandler {
+	return &ForecastHandler{history: history}
+}
+
+// ServeHTTP implements http.Handler interface.
+func (h *ForecastHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var window time.Duration
+	if text := r.URL.Query().Get("window"); text != "" {
+		var err error
+		if window, err = time.ParseDuration(text); err != nil {
+			http.Error(w, "invalid window: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	now := time.Now()
+	if h.Now != nil {
+		now = h.Now()
+	}
+
+	forecast, err := ForecastHistory(h.history, window, now)
+	if err == ErrNotEnoughReadings {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(forecast)
+}
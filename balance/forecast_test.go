@@ -0,0 +1,155 @@
+package balance_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/balance"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/money"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+)
+
+var start = time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+
+func reading(hours int, amount string) balance.Reading {
+	return balance.Reading{At: start.Add(time.Duration(hours) * time.Hour), Balance: newBalance(amount, "0")}
+}
+
+func TestForecastReadings(t *testing.T) {
+	var inputData = []struct {
+		name             string
+		readings         []balance.Reading
+		expectedSpent    string
+		expectedTopUps   string
+		expectedPerDay   string
+		expectedTimeLeft time.Duration
+		expectedRunsOut  bool
+	}{
+		{"steady spending", []balance.Reading{reading(0, "100"), reading(12, "94"), reading(24, "88")}, "12", "0", "12", 176 * time.Hour, true},
+		{"top-up ignored", []balance.Reading{reading(0, "20"), reading(24, "10"), reading(25, "110"), reading(48, "100")}, "20", "100", "10", 240 * time.Hour, true},
+		{"no spending", []balance.Reading{reading(0, "50"), reading(24, "50")}, "0", "0", "0", 0, false},
+		{"already exhausted", []balance.Reading{reading(0, "5"), reading(24, "-1")}, "6", "0", "6", 0, true},
+		{"exact amounts", []balance.Reading{reading(0, "0.3"), reading(24, "0.2"), reading(48, "0.1")}, "0.2", "0", "0.1", 24 * time.Hour, true},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			forecast, err := balance.ForecastReadings(input.readings)
+			if err != nil {
+				t.Fatalf("FAIL. Unexpected error: %v", err)
+			}
+
+			if forecast.Spent != eur(input.expectedSpent) || forecast.TopUps != eur(input.expectedTopUps) {
+				t.Errorf("FAIL. Expected spent %s and top-ups %s, but got %s and %s", input.expectedSpent, input.expectedTopUps, forecast.Spent, forecast.TopUps)
+			}
+			if forecast.PerDay != eur(input.expectedPerDay) {
+				t.Errorf("FAIL. Expected %s per day, but got %s", input.expectedPerDay, forecast.PerDay)
+			}
+			if forecast.RunsOut() != input.expectedRunsOut || forecast.TimeLeft.Round(time.Minute) != input.expectedTimeLeft {
+				t.Errorf("FAIL. Expected time left %s (%t), but got %s (%t)", input.expectedTimeLeft, input.expectedRunsOut, forecast.TimeLeft, forecast.RunsOut())
+			}
+			if forecast.RunsOut() && !forecast.ExhaustedAt.Equal(forecast.To.Add(forecast.TimeLeft)) {
+				t.Errorf("FAIL. Unexpected exhaustion time %s", forecast.ExhaustedAt)
+			}
+		})
+	}
+
+	if _, err := balance.ForecastReadings([]balance.Reading{reading(0, "10")}); err != balance.ErrNotEnoughReadings {
+		t.Errorf("FAIL. Expected error '%v', but got '%v'", balance.ErrNotEnoughReadings, err)
+	}
+	if _, err := balance.ForecastReadings([]balance.Reading{reading(0, "10"), reading(0, "9")}); err != balance.ErrNotEnoughReadings {
+		t.Errorf("FAIL. Expected error '%v', but got '%v'", balance.ErrNotEnoughReadings, err)
+	}
+}
+
+func TestFileHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	history, err := balance.OpenFileHistory(path)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error: %v", err)
+	}
+
+	for _, r := range []balance.Reading{reading(24, "90"), reading(0, "100"), reading(48, "80")} {
+		if err := history.Append(r); err != nil {
+			t.Fatalf("FAIL. Unexpected error: %v", err)
+		}
+	}
+	history.Close()
+
+	reopened, err := balance.OpenFileHistory(path)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error: %v", err)
+	}
+	defer reopened.Close()
+
+	readings, _ := reopened.Readings(start.Add(time.Hour), time.Time{})
+	if len(readings) != 2 || readings[0].Balance.BalanceAmount != eur("90") || readings[1].Balance.BalanceAmount != eur("80") {
+		t.Errorf("FAIL. Unexpected readings '%+v'", readings)
+	}
+
+	forecast, err := balance.ForecastHistory(reopened, 72*time.Hour, start.Add(50*time.Hour))
+	if err != nil || forecast.Readings != 3 || forecast.PerDay != eur("10") {
+		t.Errorf("FAIL. Unexpected forecast '%+v' (%v)", forecast, err)
+	}
+}
+
+func TestMonitorHistory(t *testing.T) {
+	now := start
+	history := balance.NewMemoryHistory()
+	client := &fakeClient{balances: []sms.Balance{newBalance("100", "0"), newBalance("99", "0"), newBalance("98", "0")}}
+	monitor := balance.New(client).SetHistory(history)
+	monitor.Now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		monitor.Check()
+		now = now.Add(time.Hour)
+	}
+
+	forecast, err := monitor.Forecast(0)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error: %v", err)
+	}
+	if forecast.PerHour != eur("1") || forecast.TimeLeft != 98*time.Hour {
+		t.Errorf("FAIL. Unexpected forecast '%+v'", forecast)
+	}
+}
+
+func TestForecastHandler(t *testing.T) {
+	history := balance.NewMemoryHistory()
+	history.Append(reading(0, "100"))
+	history.Append(reading(24, "90"))
+	handler := balance.NewForecastHandler(history)
+	handler.Now = func() time.Time { return start.Add(25 * time.Hour) }
+
+	var inputData = []struct {
+		query        string
+		expectedCode int
+	}{
+		{"", http.StatusOK},
+		{"?window=48h", http.StatusOK},
+		{"?window=1h", http.StatusNotFound},
+		{"?window=week", http.StatusBadRequest},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.query, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/balance/forecast"+input.query, nil))
+			if recorder.Code != input.expectedCode {
+				t.Fatalf("FAIL. Expected status %d, but got %d", input.expectedCode, recorder.Code)
+			}
+			if recorder.Code != http.StatusOK {
+				return
+			}
+
+			var forecast balance.Forecast
+			if err := json.NewDecoder(recorder.Body).Decode(&forecast); err != nil || forecast.PerDay != money.MustParse("10", "EUR") {
+				t.Errorf("FAIL. Unexpected forecast '%+v' (%v)", forecast, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package balance
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/internal/jsonl"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+)
+
+// Reading represents the balance received at the time.
+type Reading struct {
+	At      time.Time   `json:"at"`      // At is a time the balance was received.
+	Balance sms.Balance `json:"balance"` // Balance is the received balance.
+}
+
+// History keeps balance readings over time.
+type History interface {
+	// Append saves the reading.
+	Append(reading Reading) error
+	// Readings returns readings received within [from, to) ordered by time. Zero times mean no limit.
+	Readings(from time.Time, to time.Time) ([]Reading, error)
+}
+
+// MemoryHistory is a History which keeps readings in memory.
+type MemoryHistory struct {
+	mu       sync.Mutex
+	readings readings
+}
+
+// NewMemoryHistory creates new empty MemoryHistory.
+func NewMemoryHistory() *MemoryHistory {
+	return &MemoryHistory{}
+}
+
+// Append implements History interface.
+func (h *MemoryHistory) Append(reading Reading) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.readings = h.readings.insert(reading)
+	return nil
+}
+
+// Readings implements History interface.
+func (h *MemoryHistory) Readings(from time.Time, to time.Time) ([]Reading, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.readings.between(from, to), nil
+}
+
+// FileHistory is a History which appends every reading to a file as a JSON line.
+type FileHistory struct {
+	mu       sync.Mutex
+	path     string
+	file     *os.File
+	readings readings
+}
+
+// OpenFileHistory opens (or creates) the balance history file at the given path and reads its content.
+func OpenFileHistory(path string) (*FileHistory, error) {
+	rs, size, err := readHistoryFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := jsonl.OpenAppend(path, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileHistory{path: path, file: file, readings: rs}, nil
+}
+
+// Append implements History interface.
+func (h *FileHistory) Append(reading Reading) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.file == nil {
+		return fmt.Errorf("balance history file '%s' is closed", h.path)
+	}
+
+	line, err := json.Marshal(reading)
+	if err != nil {
+		return err
+	}
+	if _, err := h.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if err := h.file.Sync(); err != nil {
+		return err
+	}
+
+	h.readings = h.readings.insert(reading)
+	return nil
+}
+
+// Readings implements History interface.
+func (h *FileHistory) Readings(from time.Time, to time.Time) ([]Reading, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.readings.between(from, to), nil
+}
+
+// Close closes the underlying file.
+func (h *FileHistory) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.file == nil {
+		return nil
+	}
+
+	err := h.file.Close()
+	h.file = nil
+	return err
+}
+
+// readHistoryFile returns the readings saved to the file and the size of its complete lines.
+func readHistoryFile(path string) (readings, int64, error) {
+	var rs readings
+	size, err := jsonl.Replay(path, func(line []byte) error {
+		var reading Reading
+		if err := json.Unmarshal(line, &reading); err != nil {
+			return fmt.Errorf("invalid balance history file '%s': %v", path, err)
+		}
+
+		rs = rs.insert(reading)
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return rs, size, nil
+}
+
+// readings are ordered by time.
+type readings []Reading
+
+func (rs readings) insert(reading Reading) readings {
+	i := sort.Search(len(rs), func(i int) bool { return rs[i].At.After(reading.At) })
+	rs = append(rs, Reading{})
+	copy(rs[i+1:], rs[i:])
+	rs[i] = reading
+	return rs
+}
+
+func (rs readings) between(from time.Time, to time.Time) []Reading {
+	start := 0
+	if !from.IsZero() {
+		start = sort.Search(len(rs), func(i int) bool { return !rs[i].At.Before(from) })
+	}
+	end := len(rs)
+	if !to.IsZero() {
+		end = sort.Search(len(rs), func(i int) bool { return !rs[i].At.Before(to) })
+	}
+	if start >= end {
+		return nil
+	}
+
+	result := make([]Reading, end-start)
+	copy(result, rs[start:end])
+	return result
+}
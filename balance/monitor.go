@@ -0,0 +1,270 @@
+// Package balance monitors the SMS account balance in the background, alerts when it runs low
+// and pauses dispatch queues when funds run out.
+package balance
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/money"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+)
+
+const defaultInterval = 5 * time.Minute
+
+// Getter gets the account balance. It is implemented by sms.Client.
+type Getter interface {
+	GetBalance() (*sms.Balance, error)
+}
+
+// Pausable is a dispatch queue which can be paused. It is implemented by outbox.Outbox.
+type Pausable interface {
+	Pause()
+	Resume()
+}
+
+// Direction represents a direction the available funds crossed the threshold in.
+type Direction int
+
+const (
+	Below Direction = iota // Below means the available funds dropped below the threshold.
+	Above                  // Above means the available funds were replenished up to the threshold or above.
+)
+
+// String returns the direction description.
+func (d Direction) String() string {
+	switch d {
+	case Below:
+		return "Below"
+	case Above:
+		return "Above"
+	default:
+		return "Invalid direction"
+	}
+}
+
+// Alert represents the available funds crossing the threshold.
+type Alert struct {
+	Threshold money.Money // Threshold which was crossed.
+	Direction Direction   // Direction the threshold was crossed in.
+	Balance   sms.Balance // Balance which crossed the threshold.
+	CheckedAt time.Time   // CheckedAt is a time of the balance reading.
+}
+
+type threshold struct {
+	amount   money.Money
+	callback func(alert Alert)
+}
+
+// Monitor polls the account balance on the interval. Alerts are fired when the available funds
+// (balance plus credit) cross thresholds, the first reading below a threshold fires its alert as well.
+// Added queues are paused when the available funds drop to PauseBelow and resumed when they are replenished.
+type Monitor struct {
+	Interval   time.Duration    // Interval between balance requests (5 minutes by default).
+	PauseBelow money.Money      // PauseBelow is an amount of the available funds queues are paused at (zero by default).
+	OnError    func(err error)  // OnError is called when the balance request or saving the reading fails.
+	Now        func() time.Time // Now returns current time (time.Now by default).
+
+	client     Getter
+	history    History
+	mu         sync.Mutex
+	thresholds []threshold
+	queues     []Pausable
+	last       *sms.Balance
+	checkedAt  time.Time
+	paused     bool
+	cancel     context.CancelFunc
+	done       chan struct{}
+}
+
+// New creates new Monitor which gets the balance using the client.
+func New(client Getter) *Monitor {
+	return &Monitor{client: client}
+}
+
+// SetHistory sets the history every balance reading is appended to.
+func (m *Monitor) SetHistory(history History) *Monitor {
+	m.history = history
+	return m
+}
+
+// AddThreshold adds the threshold of the available funds. The callback is called when they cross it in any direction.
+func (m *Monitor) AddThreshold(amount money.Money, callback func(alert Alert)) *Monitor {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.thresholds = append(m.thresholds, threshold{amount: amount, callback: callback})
+	return m
+}
+
+// AddQueue adds the queue which is paused when funds run out.
+func (m *Monitor) AddQueue(queue Pausable) *Monitor {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.queues = append(m.queues, queue)
+	if m.paused {
+		queue.Pause()
+	}
+	return m
+}
+
+// Start checks the balance and starts polling in the background.
+func (m *Monitor) Start(ctx context.Context) error {
+	m.mu.Lock()
+	if m.cancel != nil {
+		m.mu.Unlock()
+		return errors.New("balance: monitor already started")
+	}
+
+	ctx, m.cancel = context.WithCancel(ctx)
+	m.done = make(chan struct{})
+	done := m.done
+	m.mu.Unlock()
+
+	go m.run(ctx, done)
+	return nil
+}
+
+// Stop stops polling. Paused queues stay paused.
+func (m *Monitor) Stop() {
+	m.mu.Lock()
+	cancel, done := m.cancel, m.done
+	m.cancel, m.done = nil, nil
+	m.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// Check gets the balance, fires alerts and pauses or resumes queues.
+func (m *Monitor) Check() (sms.Balance, error) {
+	balance, err := m.client.GetBalance()
+	if err != nil {
+		if m.OnError != nil {
+			m.OnError(err)
+		}
+		return sms.Balance{}, err
+	}
+
+	m.Observe(*balance)
+	return *balance, nil
+}
+
+// Observe handles the balance reading received elsewhere, like it was polled by the monitor.
+func (m *Monitor) Observe(balance sms.Balance) {
+	m.mu.Lock()
+	now := m.now()
+	available := balance.Available()
+
+	var alerts []func()
+	for _, t := range m.thresholds {
+		t := t
+		alert := Alert{Threshold: t.amount, Balance: balance, CheckedAt: now}
+		switch {
+		case available.Cmp(t.amount) < 0 && (m.last == nil || m.last.Available().Cmp(t.amount) >= 0):
+			alert.Direction = Below
+		case available.Cmp(t.amount) >= 0 && m.last != nil && m.last.Available().Cmp(t.amount) < 0:
+			alert.Direction = Above
+		default:
+			continue
+		}
+		alerts = append(alerts, func() { t.callback(alert) })
+	}
+
+	pause := available.Cmp(m.PauseBelow) <= 0
+	changed := pause != m.paused
+	m.paused = pause
+	queues := m.queues
+
+	m.last = &balance
+	m.checkedAt = now
+	m.mu.Unlock()
+
+	if m.history != nil {
+		if err := m.history.Append(Reading{At: now, Balance: balance}); err != nil && m.OnError != nil {
+			m.OnError(err)
+		}
+	}
+
+	if changed {
+		for _, queue := range queues {
+			if pause {
+				queue.Pause()
+			} else {
+				queue.Resume()
+			}
+		}
+	}
+
+	for _, alert := range alerts {
+		alert()
+	}
+}
+
+// Last returns the last known balance and the time it was received. False is returned if the balance was not received yet.
+func (m *Monitor) Last() (sms.Balance, time.Time, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.last == nil {
+		return sms.Balance{}, time.Time{}, false
+	}
+
+	return *m.last, m.checkedAt, true
+}
+
+// Forecast forecasts when funds run out by the history readings received during the window
+// (DefaultWindow if the window is not positive).
+func (m *Monitor) Forecast(window time.Duration) (Forecast, error) {
+	if m.history == nil {
+		return Forecast{}, errors.New("balance: history is not set")
+	}
+
+	return ForecastHistory(m.history, window, m.now())
+}
+
+// IsPaused returns true if the queues are paused because funds ran out.
+func (m *Monitor) IsPaused() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.paused
+}
+
+func (m *Monitor) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(m.interval())
+	defer ticker.Stop()
+
+	for {
+		m.Check()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Monitor) interval() time.Duration {
+	if m.Interval <= 0 {
+		return defaultInterval
+	}
+
+	return m.Interval
+}
+
+func (m *Monitor) now() time.Time {
+	if m.Now == nil {
+		return time.Now()
+	}
+
+	return m.Now()
+}
@@ -0,0 +1,179 @@
+package balance_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/balance"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/money"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+)
+
+type fakeClient struct {
+	mu       sync.Mutex
+	balances []sms.Balance
+	err      error
+}
+
+func (c *fakeClient) GetBalance() (*sms.Balance, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	b := c.balances[0]
+	if len(c.balances) > 1 {
+		c.balances = c.balances[1:]
+	}
+	return &b, nil
+}
+
+type fakeQueue struct {
+	mu     sync.Mutex
+	paused bool
+	calls  int
+}
+
+func (q *fakeQueue) Pause() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.paused = true
+	q.calls++
+}
+
+func (q *fakeQueue) Resume() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.paused = false
+	q.calls++
+}
+
+func eur(amount string) money.Money {
+	return money.MustParse(amount, "EUR")
+}
+
+func newBalance(amount string, credit string) sms.Balance {
+	return sms.Balance{BalanceAmount: eur(amount), CreditAmount: eur(credit), Currency: "EUR"}
+}
+
+func TestThresholdAlerts(t *testing.T) {
+	client := &fakeClient{balances: []sms.Balance{
+		newBalance("150", "0"),
+		newBalance("80", "10"),
+		newBalance("40", "0"),
+		newBalance("5", "0"),
+		newBalance("200", "0"),
+	}}
+
+	var alerts []balance.Alert
+	monitor := balance.New(client).
+		AddThreshold(eur("100"), func(alert balance.Alert) { alerts = append(alerts, alert) }).
+		AddThreshold(eur("50"), func(alert balance.Alert) { alerts = append(alerts, alert) })
+
+	var expected = []struct {
+		threshold money.Money
+		direction balance.Direction
+	}{
+		{eur("100"), balance.Below},
+		{eur("50"), balance.Below},
+		{eur("100"), balance.Above},
+		{eur("50"), balance.Above},
+	}
+
+	for i := 0; i < 5; i++ {
+		if _, err := monitor.Check(); err != nil {
+			t.Fatalf("FAIL. Unexpected error: %v", err)
+		}
+	}
+
+	if len(alerts) != len(expected) {
+		t.Fatalf("FAIL. Expected %d alerts, but got %d: %+v", len(expected), len(alerts), alerts)
+	}
+	for i, e := range expected {
+		if alerts[i].Threshold != e.threshold || alerts[i].Direction != e.direction {
+			t.Errorf("FAIL. Expected alert %d of %s %s, but got %s %s", i, e.threshold, e.direction, alerts[i].Threshold, alerts[i].Direction)
+		}
+	}
+
+	last, _, ok := monitor.Last()
+	if !ok || last.BalanceAmount != eur("200") {
+		t.Errorf("FAIL. Expected last balance 200, but got '%+v'", last)
+	}
+}
+
+func TestFirstReadingBelowThreshold(t *testing.T) {
+	client := &fakeClient{balances: []sms.Balance{newBalance("10", "0")}}
+	alerts := 0
+	monitor := balance.New(client).AddThreshold(eur("50"), func(alert balance.Alert) { alerts++ })
+
+	monitor.Check()
+	monitor.Check()
+	if alerts != 1 {
+		t.Errorf("FAIL. Expected 1 alert, but got %d", alerts)
+	}
+}
+
+func TestPauseQueues(t *testing.T) {
+	var inputData = []struct {
+		balance        sms.Balance
+		expectedPaused bool
+	}{
+		{newBalance("10", "0"), false},
+		{newBalance("-5", "5"), true},
+		{newBalance("-5.000000001", "5"), true},
+		{newBalance("0.000000001", "0"), false},
+		{sms.Balance{}, true},
+	}
+
+	queue := &fakeQueue{}
+	monitor := balance.New(&fakeClient{}).AddQueue(queue)
+
+	for i, input := range inputData {
+		monitor.Observe(input.balance)
+		if queue.paused != input.expectedPaused || monitor.IsPaused() != input.expectedPaused {
+			t.Errorf("FAIL. Expected paused: %t after reading %d, but got %t", input.expectedPaused, i, queue.paused)
+		}
+	}
+	if queue.calls != 3 {
+		t.Errorf("FAIL. Expected 3 pause and resume calls, but got %d", queue.calls)
+	}
+
+	late := &fakeQueue{}
+	monitor.AddQueue(late)
+	if !late.paused {
+		t.Errorf("FAIL. Expected the queue added while funds ran out to be paused")
+	}
+}
+
+func TestPolling(t *testing.T) {
+	client := &fakeClient{err: errors.New("connection refused")}
+	errs := make(chan error, 10)
+	monitor := balance.New(client)
+	monitor.Interval = 10 * time.Millisecond
+	monitor.OnError = func(err error) { errs <- err }
+
+	if err := monitor.Start(context.Background()); err != nil {
+		t.Fatalf("FAIL. Unexpected error: %v", err)
+	}
+	defer monitor.Stop()
+	if err := monitor.Start(context.Background()); err == nil {
+		t.Errorf("FAIL. Expected error of the second start")
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-errs:
+		case <-time.After(time.Second):
+			t.Fatalf("FAIL. Expected the balance to be polled")
+		}
+	}
+
+	if _, _, ok := monitor.Last(); ok {
+		t.Errorf("FAIL. Expected no balance to be known")
+	}
+}
@@ -0,0 +1,148 @@
+// Command balance records SMS account balance readings and forecasts when funds run out.
+//
+// Usage:
+//
+//	balance [flags] check     get the balance and append it to the history
+//	balance [flags] watch     check the balance on the interval until interrupted
+//	balance [flags] history   print readings of the window
+//	balance [flags] forecast  print spending rates and the time funds run out
+//
+// The login and the password are read from the DECISIONTELECOM_LOGIN and DECISIONTELECOM_PASSWORD
+// environment variables unless the flags are set.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/balance"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "balance:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, out io.Writer) error {
+	flags := flag.NewFlagSet("balance", flag.ContinueOnError)
+	flags.SetOutput(out)
+	login := flags.String("login", "", "SMS account login (DECISIONTELECOM_LOGIN by default)")
+	password := flags.String("password", "", "SMS account password (DECISIONTELECOM_PASSWORD by default)")
+	historyPath := flags.String("history", "balance-history.jsonl", "path of the balance history file")
+	window := flags.Duration("window", balance.DefaultWindow, "period of readings the history and the forecast are based on")
+	interval := flags.Duration("interval", 5*time.Minute, "interval between balance checks of the watch command")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return errors.New("expected one command: check, watch, history or forecast")
+	}
+
+	// credentials are not flag defaults, so usage never prints them
+	if *login == "" {
+		*login = os.Getenv("DECISIONTELECOM_LOGIN")
+	}
+	if *password == "" {
+		*password = os.Getenv("DECISIONTELECOM_PASSWORD")
+	}
+
+	command := flags.Arg(0)
+	switch command {
+	case "check", "watch":
+		if *login == "" || *password == "" {
+			return errors.New("login and password are required")
+		}
+	case "history", "forecast":
+	default:
+		return fmt.Errorf("unknown command %q", command)
+	}
+
+	history, err := balance.OpenFileHistory(*historyPath)
+	if err != nil {
+		return err
+	}
+	defer history.Close()
+
+	switch command {
+	case "check":
+		return check(balance.New(sms.NewClient(*login, *password)).SetHistory(history), out)
+	case "watch":
+		return watch(balance.New(sms.NewClient(*login, *password)).SetHistory(history), *interval, out)
+	case "history":
+		return printHistory(history, *window, out)
+	default:
+		return printForecast(history, *window, out)
+	}
+}
+
+func check(monitor *balance.Monitor, out io.Writer) error {
+	b, err := monitor.Check()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "balance: %s, credit: %s, available: %s\n", b.BalanceAmount, b.CreditAmount, b.Available())
+	return nil
+}
+
+func watch(monitor *balance.Monitor, interval time.Duration, out io.Writer) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := check(monitor, out); err != nil {
+			fmt.Fprintln(out, "error:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func printHistory(history balance.History, window time.Duration, out io.Writer) error {
+	readings, err := history.Readings(time.Now().Add(-window), time.Time{})
+	if err != nil {
+		return err
+	}
+
+	for _, reading := range readings {
+		fmt.Fprintf(out, "%s  %s\n", reading.At.Format(time.RFC3339), reading.Balance.Available())
+	}
+	return nil
+}
+
+func printForecast(history balance.History, window time.Duration, out io.Writer) error {
+	forecast, err := balance.ForecastHistory(history, window, time.Now())
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "period:     %s - %s (%d readings)\n", forecast.From.Format(time.RFC3339), forecast.To.Format(time.RFC3339), forecast.Readings)
+	fmt.Fprintf(out, "spent:      %s (top-ups %s)\n", forecast.Spent, forecast.TopUps)
+	fmt.Fprintf(out, "per hour:   %s %s\n", forecast.PerHour.Format(4), forecast.PerHour.Currency())
+	fmt.Fprintf(out, "per day:    %s %s\n", forecast.PerDay.Format(2), forecast.PerDay.Currency())
+	fmt.Fprintf(out, "available:  %s\n", forecast.Available)
+	if !forecast.RunsOut() {
+		fmt.Fprintln(out, "runs out:   never at the current rate")
+		return nil
+	}
+
+	fmt.Fprintf(out, "runs out:   %s (in %s)\n", forecast.ExhaustedAt.Format(time.RFC3339), forecast.TimeLeft.Round(time.Minute))
+	return nil
+}
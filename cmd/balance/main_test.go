@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/balance"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/money"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+)
+
+func TestForecastCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	history, err := balance.OpenFileHistory(path)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error: %v", err)
+	}
+
+	now := time.Now()
+	for i, amount := range []string{"100", "90", "80"} {
+		b := sms.Balance{BalanceAmount: money.MustParse(amount, "EUR"), CreditAmount: money.Zero("EUR"), Currency: "EUR"}
+		history.Append(balance.Reading{At: now.Add(time.Duration(i-2) * 24 * time.Hour), Balance: b})
+	}
+	history.Close()
+
+	var inputData = []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{"-history", path, "forecast"}, []string{"per day:    10.00 EUR", "available:  80 EUR", "(in 192h0m0s)"}},
+		{[]string{"-history", path, "history"}, []string{"100 EUR", "90 EUR", "80 EUR"}},
+		{[]string{"-history", path, "-window", "36h", "history"}, []string{"90 EUR", "80 EUR"}},
+	}
+
+	for _, input := range inputData {
+		t.Run(strings.Join(input.args[2:], " "), func(t *testing.T) {
+			var out bytes.Buffer
+			if err := run(input.args, &out); err != nil {
+				t.Fatalf("FAIL. Unexpected error: %v", err)
+			}
+
+			for _, expected := range input.expected {
+				if !strings.Contains(out.String(), expected) {
+					t.Errorf("FAIL. Expected output to contain '%s', but got '%s'", expected, out.String())
+				}
+			}
+		})
+	}
+
+	t.Setenv("DECISIONTELECOM_LOGIN", "")
+	t.Setenv("DECISIONTELECOM_PASSWORD", "")
+	for _, args := range [][]string{{"-history", path}, {"-history", path, "unknown"}, {"-history", path, "check"}} {
+		if err := run(args, &bytes.Buffer{}); err == nil {
+			t.Errorf("FAIL. Expected error of arguments %v", args)
+		}
+	}
+}
+
+func TestUsageHidesCredentials(t *testing.T) {
+	t.Setenv("DECISIONTELECOM_PASSWORD", "s3cr3t-pass")
+
+	var out bytes.Buffer
+	if err := run(nil, &out); err == nil {
+		t.Fatalf("FAIL. Expected error of missing command")
+	}
+	if strings.Contains(out.String(), "s3cr3t-pass") {
+		t.Errorf("FAIL. Expected usage without the password, but got '%s'", out.String())
+	}
+}
+
+func TestUnknownCommandKeepsHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+
+	if err := run([]string{"-history", path, "unknown"}, &bytes.Buffer{}); err == nil {
+		t.Fatalf("FAIL. Expected error of the unknown command")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("FAIL. Expected the history file not to be created, but got '%v'", err)
+	}
+}
@@ -0,0 +1,43 @@
+package country_test
+
+import (
+	"testing"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/country"
+)
+
+func TestFromPhone(t *testing.T) {
+	var inputData = []struct {
+		phone           string
+		expectedCountry string
+		expectedOk      bool
+	}{
+		{"380671234567", "UA", true},
+		{"+380 (67) 123-45-67", "UA", true},
+		{"00380671234567", "UA", true},
+		{"48601234567", "PL", true},
+		{"4915112345678", "DE", true},
+		{"77012345678", "KZ", true},
+		{"79161234567", "RU", true},
+		{"12025550123", "US", true},
+		{"+1 (416) 555-0123", "CA", true},
+		{"18765550123", "JM", true},
+		{"17875550123", "PR", true},
+		{"2250712345678", "CI", true},
+		{"59995123456", "CW", true},
+		{"97798412345678", "NP", true},
+		{"37360123456", "MD", true},
+		{"999123456", "", false},
+		{"", "", false},
+		{"380", "", false},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.phone, func(t *testing.T) {
+			actual, ok := country.FromPhone(input.phone)
+			if actual != input.expectedCountry || ok != input.expectedOk {
+				t.Errorf("FAIL. Expected country '%s' (%t), but got '%s' (%t)", input.expectedCountry, input.expectedOk, actual, ok)
+			}
+		})
+	}
+}
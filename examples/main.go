@@ -89,8 +89,8 @@ func smsClientGetBalance() {
 		}
 	} else {
 		// If no errors occurred, GetBalance method should return SMS balance information.
-		fmt.Printf("balance information: Balance: %f, Credit: %f, Currency: %s\n",
-			balance.BalanceAmount, balance.CreditAmount, balance.Currency)
+		fmt.Printf("balance information: Balance: %s, Credit: %s, Currency: %s\n",
+			balance.BalanceAmount.Amount(), balance.CreditAmount.Amount(), balance.Currency)
 	}
 }
 
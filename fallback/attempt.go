@@ -0,0 +1,78 @@
+package fallback
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	viberplussms "github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+// Outcome represents the result of the fallback attempt as a whole. It is the outcome of Viber plus SMS messages,
+// so attempts and Viber plus SMS receipts are reported the same way. The attempt is InProgress until it is
+// DeliveredViaViber, DeliveredViaSms, NotDelivered or Unconfirmed.
+type Outcome = viberplussms.Outcome
+
+// Reason represents why the SMS message was sent.
+type Reason string
+
+const (
+	ViberSendFailed Reason = "viber_send_failed" // ViberSendFailed means the Viber message could not be sent.
+	ViberFailed     Reason = "viber_failed"      // ViberFailed means the Viber message ended in Undelivered, Rejected or ErrorStatus.
+	ViberTimedOut   Reason = "viber_timed_out"   // ViberTimedOut means the Viber message was still pending at the deadline.
+)
+
+// Leg represents a message sent through one channel.
+type Leg struct {
+	Channel   messaging.Channel        `json:"channel"`    // Channel of the message.
+	MessageId int64                    `json:"message_id"` // Id of the message (zero if it was not sent).
+	Text      string                   `json:"text"`       // Text of the message.
+	Status    messaging.DeliveryStatus `json:"status"`     // Current status of the message.
+	Error     string                   `json:"error"`      // Error of sending the message.
+	SentAt    time.Time                `json:"sent_at"`    // Time when the message was sent.
+	UpdatedAt time.Time                `json:"updated_at"` // Time of the last status change.
+}
+
+// Attempt represents the Viber message with its SMS fallback.
+type Attempt struct {
+	Id          string    `json:"id"`           // Id of the attempt.
+	Recipient   string    `json:"recipient"`    // Recipient phone number.
+	Viber       Leg       `json:"viber"`        // Viber message.
+	Sms         *Leg      `json:"sms"`          // SMS message, nil if the fallback was not triggered.
+	Reason      Reason    `json:"reason"`       // Reason why the SMS message was sent.
+	Deadline    time.Time `json:"deadline"`     // Time until which the Viber message may stay pending.
+	Outcome     Outcome   `json:"outcome"`      // Outcome of the attempt.
+	StartedAt   time.Time `json:"started_at"`   // Time when the Viber message was sent.
+	CompletedAt time.Time `json:"completed_at"` // Time when the outcome became final.
+}
+
+// FallbackTriggered returns true if the SMS message was sent.
+func (a *Attempt) FallbackTriggered() bool {
+	return a.Sms != nil
+}
+
+// IsFinal returns true if the outcome of the attempt won't change.
+func (a *Attempt) IsFinal() bool {
+	return a.Outcome != viberplussms.InProgress
+}
+
+func (a *Attempt) clone() Attempt {
+	c := *a
+	if a.Sms != nil {
+		sms := *a.Sms
+		c.Sms = &sms
+	}
+
+	return c
+}
+
+func newAttemptId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// fall back to the time based id, it is still unique enough within a single orchestrator
+		return time.Now().UTC().Format("20060102150405.000000000")
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,576 @@
+// Package fallback contains an orchestrator which sends Viber messages and falls back to SMS
+// messages sent by the client when the Viber message is not delivered.
+//
+// Unlike viber/sms.Client, which relies on the provider fallback for transactional messages only,
+// the orchestrator works for promotional messages as well and records both messages and the outcome.
+package fallback
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/reconcile"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/store"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	viberplussms "github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+const (
+	defaultPendingTimeout = 10 * time.Minute
+	defaultTimeout        = 24 * time.Hour
+	defaultPollInterval   = 30 * time.Second
+
+	// AttemptIdKey is a metadata key of the attempt id in the recorded messages.
+	AttemptIdKey = "fallback_attempt_id"
+	// FallbackForKey is a metadata key of the Viber message id in the recorded SMS messages.
+	FallbackForKey = "fallback_for"
+)
+
+// ErrNotFound is returned when the attempt is not in progress.
+var ErrNotFound = errors.New("fallback: attempt not found")
+
+// ViberClient sends Viber messages and gets their statuses. It is implemented by viber.Client.
+type ViberClient interface {
+	SendMessage(message *viber.Message) (int64, error)
+	GetMessageStatus(messageId int64) (*viber.MessageReceipt, error)
+}
+
+// SmsClient sends SMS messages and gets their statuses. It is implemented by sms.Client.
+type SmsClient interface {
+	SendMessage(message *sms.Message) (int64, error)
+	GetMessageStatus(messageId int64) (sms.MessageStatus, error)
+}
+
+// Orchestrator sends Viber messages and watches their statuses by polling, callbacks, or both.
+// If the Viber message ends in Undelivered, Rejected or ErrorStatus, cannot be sent at all,
+// or is still pending (or its status is unknown) at the deadline, the SMS message is sent instead.
+type Orchestrator struct {
+	PendingTimeout time.Duration                       // PendingTimeout is a time the Viber message may stay pending (10 minutes by default).
+	Timeout        time.Duration                       // Timeout is a maximum time to wait for the final outcome (24 hours by default).
+	PollInterval   time.Duration                       // PollInterval is a delay between status requests (30 seconds by default), negative value disables polling.
+	SmsSender      string                              // SmsSender is a sender of SMS messages (the Viber message sender by default).
+	SmsText        func(message *viber.Message) string // SmsText derives the SMS text from the Viber message (its text by default).
+	OnOutcome      func(attempt Attempt)               // OnOutcome is called when the outcome of the attempt becomes final.
+	Now            func() time.Time                    // Now returns current time (time.Now by default).
+
+	viberClient ViberClient
+	smsClient   SmsClient
+	messages    store.MessageStore
+
+	mu       sync.Mutex
+	attempts map[string]*attempt
+	byViber  map[int64]*attempt
+	bySms    map[int64]*attempt
+	waiters  map[string][]chan Attempt
+	wake     chan struct{}
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// attempt is the attempt in progress.
+type attempt struct {
+	Attempt
+	message     *viber.Message
+	fallingBack bool
+	nextPollAt  time.Time
+}
+
+// New creates new orchestrator which sends messages with the given clients.
+func New(viberClient ViberClient, smsClient SmsClient) *Orchestrator {
+	return &Orchestrator{
+		viberClient: viberClient,
+		smsClient:   smsClient,
+		attempts:    make(map[string]*attempt),
+		byViber:     make(map[int64]*attempt),
+		bySms:       make(map[int64]*attempt),
+		waiters:     make(map[string][]chan Attempt),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// SetMessageStore sets the store where both messages and their statuses are recorded.
+// The attempt id is saved to the record metadata under the AttemptIdKey key.
+func (o *Orchestrator) SetMessageStore(messages store.MessageStore) *Orchestrator {
+	o.messages = messages
+	return o
+}
+
+// Start starts polling statuses and checking deadlines of attempts in progress.
+func (o *Orchestrator) Start(ctx context.Context) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.cancel != nil {
+		return errors.New("fallback: already started")
+	}
+
+	ctx, o.cancel = context.WithCancel(ctx)
+	o.done = make(chan struct{})
+	go o.run(ctx, o.done)
+
+	return nil
+}
+
+// Stop stops polling and checking deadlines.
+func (o *Orchestrator) Stop() {
+	o.mu.Lock()
+	cancel, done := o.cancel, o.done
+	o.cancel, o.done = nil, nil
+	o.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// Send sends the Viber message and starts the attempt. If the Viber message cannot be sent,
+// the SMS message is sent right away and the error is returned only if it fails too.
+func (o *Orchestrator) Send(message *viber.Message) (Attempt, error) {
+	now := o.now()
+	a := &attempt{
+		Attempt: Attempt{
+			Id:        newAttemptId(),
+			Recipient: message.Receiver,
+			Viber:     Leg{Channel: messaging.Viber, Text: message.Text, SentAt: now, UpdatedAt: now},
+			Deadline:  now.Add(o.pendingTimeout()),
+			Outcome:   viberplussms.InProgress,
+			StartedAt: now,
+		},
+		message: message,
+	}
+
+	messageId, err := o.viberClient.SendMessage(message)
+	if err != nil {
+		a.Viber.Status = messaging.Failed
+		a.Viber.Error = err.Error()
+		o.mu.Lock()
+		o.attempts[a.Id] = a
+		o.mu.Unlock()
+
+		result := o.fallback(a, ViberSendFailed)
+		if result.Sms.MessageId == 0 {
+			return result, err
+		}
+		return result, nil
+	}
+
+	a.Viber.MessageId = messageId
+	a.Viber.Status = messaging.Accepted
+	a.nextPollAt = now.Add(o.pollInterval())
+
+	o.mu.Lock()
+	o.attempts[a.Id] = a
+	o.byViber[messageId] = a
+	result := a.clone()
+	o.mu.Unlock()
+
+	o.record(store.NewViberRecord(messageId, message), a.Id, 0)
+	o.notify()
+	return result, nil
+}
+
+// Get returns the attempt in progress.
+func (o *Orchestrator) Get(id string) (Attempt, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	a, ok := o.attempts[id]
+	if !ok {
+		return Attempt{}, ErrNotFound
+	}
+
+	return a.clone(), nil
+}
+
+// Wait blocks until the outcome of the attempt becomes final and returns the attempt.
+func (o *Orchestrator) Wait(ctx context.Context, id string) (Attempt, error) {
+	waiter := make(chan Attempt, 1)
+
+	o.mu.Lock()
+	if _, ok := o.attempts[id]; !ok {
+		o.mu.Unlock()
+		return Attempt{}, ErrNotFound
+	}
+	o.waiters[id] = append(o.waiters[id], waiter)
+	o.mu.Unlock()
+
+	select {
+	case result := <-waiter:
+		return result, nil
+	case <-ctx.Done():
+		o.mu.Lock()
+		waiters := o.waiters[id]
+		for i, w := range waiters {
+			if w == waiter {
+				o.waiters[id] = append(waiters[:i], waiters[i+1:]...)
+				break
+			}
+		}
+		o.mu.Unlock()
+		return Attempt{}, ctx.Err()
+	}
+}
+
+// ObserveViberStatus applies the status of the Viber message received by a callback.
+// It returns false if the message doesn't belong to an attempt in progress.
+func (o *Orchestrator) ObserveViberStatus(messageId int64, status viber.MessageStatus) bool {
+	return o.observeViber(messageId, messaging.FromViberStatus(status), reconcile.Callback)
+}
+
+// ObserveSmsStatus applies the status of the SMS message received by other means than polling.
+// It returns false if the message doesn't belong to an attempt in progress.
+func (o *Orchestrator) ObserveSmsStatus(messageId int64, status sms.MessageStatus) bool {
+	return o.observeSms(messageId, messaging.FromSmsStatus(status), reconcile.Callback)
+}
+
+// HandleViberCallback applies the callback status. Pass it to viber.NewCallbackHandler.
+func (o *Orchestrator) HandleViberCallback(event *viber.CallbackEvent) error {
+	o.ObserveViberStatus(event.MessageId, event.Status)
+	return nil
+}
+
+func (o *Orchestrator) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	for {
+		next := o.check(ctx)
+
+		var timer <-chan time.Time
+		if !next.IsZero() {
+			delay := next.Sub(o.now())
+			if delay < 0 {
+				delay = 0
+			}
+			timer = time.After(delay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-o.wake:
+		case <-timer:
+		}
+	}
+}
+
+// check polls due statuses, applies deadlines and returns the time of the next check.
+func (o *Orchestrator) check(ctx context.Context) time.Time {
+	o.mu.Lock()
+	var active []*attempt
+	for _, a := range o.attempts {
+		active = append(active, a)
+	}
+	o.mu.Unlock()
+
+	for _, a := range active {
+		if ctx.Err() != nil {
+			return time.Time{}
+		}
+		o.checkAttempt(a)
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var next time.Time
+	earliest := func(t time.Time) {
+		if next.IsZero() || t.Before(next) {
+			next = t
+		}
+	}
+
+	for _, a := range o.attempts {
+		if a.fallingBack {
+			continue
+		}
+		if o.pollInterval() > 0 {
+			earliest(a.nextPollAt)
+		}
+		if a.Sms == nil && isPending(a.Viber.Status) {
+			earliest(a.Deadline)
+		}
+		earliest(a.StartedAt.Add(o.timeout()))
+	}
+
+	return next
+}
+
+func (o *Orchestrator) checkAttempt(a *attempt) {
+	now := o.now()
+
+	o.mu.Lock()
+	viberId, smsLeg, fallingBack := a.Viber.MessageId, a.Sms, a.fallingBack
+	due := o.pollInterval() > 0 && !a.nextPollAt.After(now)
+	if due {
+		a.nextPollAt = now.Add(o.pollInterval())
+	}
+	o.mu.Unlock()
+
+	if due && smsLeg == nil && !fallingBack && viberId > 0 {
+		if receipt, err := o.viberClient.GetMessageStatus(viberId); err == nil {
+			o.observeViber(viberId, messaging.FromViberStatus(receipt.Status), reconcile.Polling)
+		}
+	}
+	if due && smsLeg != nil && smsLeg.MessageId > 0 {
+		if status, err := o.smsClient.GetMessageStatus(smsLeg.MessageId); err == nil {
+			o.observeSms(smsLeg.MessageId, messaging.FromSmsStatus(status), reconcile.Polling)
+		}
+	}
+
+	o.mu.Lock()
+	if _, ok := o.attempts[a.Id]; !ok {
+		o.mu.Unlock()
+		return
+	}
+
+	pending := a.Sms == nil && !a.fallingBack && isPending(a.Viber.Status)
+	if pending && !now.Before(a.Deadline) {
+		o.mu.Unlock()
+		o.fallback(a, ViberTimedOut)
+		return
+	}
+
+	if !now.Before(a.StartedAt.Add(o.timeout())) && !a.fallingBack {
+		completed := o.complete(a, viberplussms.Unconfirmed)
+		o.mu.Unlock()
+		o.finish(completed)
+		return
+	}
+	o.mu.Unlock()
+}
+
+func (o *Orchestrator) observeViber(messageId int64, status messaging.DeliveryStatus, source reconcile.Source) bool {
+	o.mu.Lock()
+	a, ok := o.byViber[messageId]
+	if !ok {
+		o.mu.Unlock()
+		return false
+	}
+
+	now := o.now()
+	if !reconcile.Advances(a.Viber.Status, status) {
+		o.mu.Unlock()
+		return true
+	}
+	a.Viber.Status = status
+	a.Viber.UpdatedAt = now
+
+	var completed *Attempt
+	needsFallback := false
+	if a.Sms == nil && !a.fallingBack {
+		switch {
+		case status == messaging.Delivered:
+			c := o.complete(a, viberplussms.DeliveredViaViber)
+			completed = &c
+		case status.IsFailure():
+			needsFallback = true
+		}
+	}
+	o.mu.Unlock()
+
+	o.updateRecord(messaging.Viber, messageId, status, source, now)
+	if completed != nil {
+		o.finish(*completed)
+	}
+	if needsFallback {
+		o.fallback(a, ViberFailed)
+	}
+
+	return true
+}
+
+func (o *Orchestrator) observeSms(messageId int64, status messaging.DeliveryStatus, source reconcile.Source) bool {
+	o.mu.Lock()
+	a, ok := o.bySms[messageId]
+	if !ok {
+		o.mu.Unlock()
+		return false
+	}
+
+	now := o.now()
+	if !reconcile.Advances(a.Sms.Status, status) {
+		o.mu.Unlock()
+		return true
+	}
+	a.Sms.Status = status
+	a.Sms.UpdatedAt = now
+
+	var completed *Attempt
+	switch {
+	case status == messaging.Delivered:
+		c := o.complete(a, viberplussms.DeliveredViaSms)
+		completed = &c
+	case status.IsFailure():
+		c := o.complete(a, viberplussms.NotDelivered)
+		completed = &c
+	}
+	o.mu.Unlock()
+
+	o.updateRecord(messaging.Sms, messageId, status, source, now)
+	if completed != nil {
+		o.finish(*completed)
+	}
+
+	return true
+}
+
+// fallback sends the SMS message once per attempt and returns the attempt after it.
+func (o *Orchestrator) fallback(a *attempt, reason Reason) Attempt {
+	o.mu.Lock()
+	if a.fallingBack || a.Sms != nil || a.IsFinal() {
+		result := a.clone()
+		o.mu.Unlock()
+		return result
+	}
+	a.fallingBack = true
+	message := a.message
+	o.mu.Unlock()
+
+	sender := o.SmsSender
+	if sender == "" {
+		sender = message.Sender
+	}
+	smsMessage := sms.NewMessage(message.Receiver, sender, o.smsText(message), true)
+
+	messageId, err := o.smsClient.SendMessage(smsMessage)
+	now := o.now()
+
+	o.mu.Lock()
+	a.fallingBack = false
+	a.Reason = reason
+	a.Sms = &Leg{Channel: messaging.Sms, Text: smsMessage.Text, SentAt: now, UpdatedAt: now}
+	if err != nil {
+		a.Sms.Status = messaging.Failed
+		a.Sms.Error = err.Error()
+		completed := o.complete(a, viberplussms.NotDelivered)
+		o.mu.Unlock()
+		o.finish(completed)
+		return completed
+	}
+
+	a.Sms.MessageId = messageId
+	a.Sms.Status = messaging.Accepted
+	a.nextPollAt = now.Add(o.pollInterval())
+	o.bySms[messageId] = a
+	result := a.clone()
+	o.mu.Unlock()
+
+	o.record(store.NewSmsRecord(messageId, smsMessage), a.Id, a.Viber.MessageId)
+	o.notify()
+	return result
+}
+
+// complete sets the final outcome and removes the attempt. It must be called under the lock.
+func (o *Orchestrator) complete(a *attempt, outcome Outcome) Attempt {
+	a.Outcome = outcome
+	a.CompletedAt = o.now()
+
+	delete(o.attempts, a.Id)
+	delete(o.byViber, a.Viber.MessageId)
+	if a.Sms != nil {
+		delete(o.bySms, a.Sms.MessageId)
+	}
+
+	return a.clone()
+}
+
+// finish passes the completed attempt to waiters and OnOutcome.
+func (o *Orchestrator) finish(completed Attempt) {
+	o.mu.Lock()
+	waiters := o.waiters[completed.Id]
+	delete(o.waiters, completed.Id)
+	o.mu.Unlock()
+
+	for _, waiter := range waiters {
+		waiter <- completed
+	}
+
+	if o.OnOutcome != nil {
+		o.OnOutcome(completed)
+	}
+}
+
+func (o *Orchestrator) record(record *store.MessageRecord, attemptId string, fallbackFor int64) {
+	if o.messages == nil {
+		return
+	}
+
+	record.SentAt = o.now()
+	record.Metadata = map[string]string{AttemptIdKey: attemptId}
+	if fallbackFor > 0 {
+		record.Metadata[FallbackForKey] = strconv.FormatInt(fallbackFor, 10)
+	}
+	// the attempt keeps its own state, so a failed write only affects reporting
+	o.messages.SaveMessage(record)
+}
+
+func (o *Orchestrator) updateRecord(channel messaging.Channel, messageId int64, status messaging.DeliveryStatus, source reconcile.Source, at time.Time) {
+	if o.messages == nil {
+		return
+	}
+
+	o.messages.UpdateStatus(reconcile.Observation{
+		Key:    reconcile.Key{Channel: channel, MessageId: messageId},
+		Status: status,
+		Source: source,
+		At:     at,
+	})
+}
+
+func (o *Orchestrator) notify() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// isPending returns true if the Viber message has not reached the user's device yet.
+func isPending(status messaging.DeliveryStatus) bool {
+	return status == messaging.Unknown || status == messaging.Accepted || status == messaging.Pending
+}
+
+func (o *Orchestrator) smsText(message *viber.Message) string {
+	if o.SmsText != nil {
+		return o.SmsText(message)
+	}
+
+	return message.Text
+}
+
+func (o *Orchestrator) pendingTimeout() time.Duration {
+	if o.PendingTimeout <= 0 {
+		return defaultPendingTimeout
+	}
+
+	return o.PendingTimeout
+}
+
+func (o *Orchestrator) timeout() time.Duration {
+	if o.Timeout <= 0 {
+		return defaultTimeout
+	}
+
+	return o.Timeout
+}
+
+func (o *Orchestrator) pollInterval() time.Duration {
+	if o.PollInterval == 0 {
+		return defaultPollInterval
+	}
+
+	return o.PollInterval
+}
+
+func (o *Orchestrator) now() time.Time {
+	if o.Now != nil {
+		return o.Now()
+	}
+
+	return time.Now()
+}
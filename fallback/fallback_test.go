@@ -0,0 +1,232 @@
+package fallback_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/fallback"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/store"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	viberplussms "github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+var (
+	_ fallback.ViberClient = viber.NewClient("<YOUR_API_KEY>")
+	_ fallback.SmsClient   = sms.NewClient("<YOUR_LOGIN>", "<YOUR_PASSWORD>")
+)
+
+type fakeViberClient struct {
+	mu       sync.Mutex
+	sendErr  error
+	statuses []viber.MessageStatus
+}
+
+func (c *fakeViberClient) SendMessage(message *viber.Message) (int64, error) {
+	if c.sendErr != nil {
+		return -1, c.sendErr
+	}
+	return 429, nil
+}
+
+func (c *fakeViberClient) GetMessageStatus(messageId int64) (*viber.MessageReceipt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	status := c.statuses[0]
+	if len(c.statuses) > 1 {
+		c.statuses = c.statuses[1:]
+	}
+	return &viber.MessageReceipt{MessageId: messageId, Status: status}, nil
+}
+
+type fakeSmsClient struct {
+	mu      sync.Mutex
+	sendErr error
+	status  sms.MessageStatus
+	sent    []*sms.Message
+}
+
+func (c *fakeSmsClient) SendMessage(message *sms.Message) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.sendErr != nil {
+		return -1, c.sendErr
+	}
+	c.sent = append(c.sent, message)
+	return 31885463, nil
+}
+
+func (c *fakeSmsClient) GetMessageStatus(messageId int64) (sms.MessageStatus, error) {
+	return c.status, nil
+}
+
+func newMessage() *viber.Message {
+	return viber.NewMessage().SetSender("Sender").SetReceiver("380504444444").SetMessageType(viber.TextOnly).
+		SetSourceType(viber.Promotional).SetText("Spring sale: -20% on everything")
+}
+
+func TestFallbackOutcomes(t *testing.T) {
+	var inputData = []struct {
+		name            string
+		viberSendErr    error
+		viberStatuses   []viber.MessageStatus
+		smsSendErr      error
+		smsStatus       sms.MessageStatus
+		expectedOutcome fallback.Outcome
+		expectedReason  fallback.Reason
+		expectedSms     bool
+	}{
+		{"delivered by Viber", nil, []viber.MessageStatus{viber.Pending, viber.Sent, viber.Delivered}, nil, sms.Delivered, viberplussms.DeliveredViaViber, "", false},
+		{"undelivered by Viber", nil, []viber.MessageStatus{viber.Sent, viber.Undelivered}, nil, sms.Delivered, viberplussms.DeliveredViaSms, fallback.ViberFailed, true},
+		{"rejected by Viber", nil, []viber.MessageStatus{viber.Rejected}, nil, sms.Delivered, viberplussms.DeliveredViaSms, fallback.ViberFailed, true},
+		{"Viber error status", nil, []viber.MessageStatus{viber.ErrorStatus}, nil, sms.Undeliverable, viberplussms.NotDelivered, fallback.ViberFailed, true},
+		{"pending past deadline", nil, []viber.MessageStatus{viber.Pending}, nil, sms.Delivered, viberplussms.DeliveredViaSms, fallback.ViberTimedOut, true},
+		{"Viber send failed", viber.Error{Name: "Invalid Parameter: destination_addr", Status: 400}, nil, nil, sms.Delivered, viberplussms.DeliveredViaSms, fallback.ViberSendFailed, true},
+		{"SMS send failed", nil, []viber.MessageStatus{viber.Undelivered}, sms.Error{Code: sms.InvalidNumber}, sms.Delivered, viberplussms.NotDelivered, fallback.ViberFailed, false},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			smsClient := &fakeSmsClient{sendErr: input.smsSendErr, status: input.smsStatus}
+			o := fallback.New(&fakeViberClient{sendErr: input.viberSendErr, statuses: input.viberStatuses}, smsClient)
+			o.PollInterval = time.Millisecond
+			o.PendingTimeout = 50 * time.Millisecond
+			o.SmsText = func(message *viber.Message) string { return "Sale -20%" }
+
+			outcomes := make(chan fallback.Attempt, 2)
+			o.OnOutcome = func(attempt fallback.Attempt) {
+				outcomes <- attempt
+			}
+
+			if err := o.Start(context.Background()); err != nil {
+				t.Fatalf("FAIL. Unexpected error '%v'", err)
+			}
+			defer o.Stop()
+
+			if _, err := o.Send(newMessage()); err != nil && input.smsSendErr == nil {
+				t.Fatalf("FAIL. Unexpected error '%v'", err)
+			}
+
+			var attempt fallback.Attempt
+			select {
+			case attempt = <-outcomes:
+			case <-time.After(2 * time.Second):
+				t.Fatalf("FAIL. Outcome was not reported in time")
+			}
+
+			if attempt.Outcome != input.expectedOutcome || attempt.Reason != input.expectedReason {
+				t.Errorf("FAIL. Expected outcome '%s' (reason '%s'), but got '%s' (reason '%s')", input.expectedOutcome, input.expectedReason, attempt.Outcome, attempt.Reason)
+			}
+
+			if (len(smsClient.sent) == 1) != input.expectedSms {
+				t.Errorf("FAIL. Expected SMS sent: %t, but got '%+v'", input.expectedSms, smsClient.sent)
+			}
+			if len(smsClient.sent) == 1 && (smsClient.sent[0].Text != "Sale -20%" || smsClient.sent[0].ReceiverPhone != "380504444444" || smsClient.sent[0].Sender != "Sender") {
+				t.Errorf("FAIL. Unexpected SMS message '%+v'", smsClient.sent[0])
+			}
+
+			if len(outcomes) != 0 {
+				t.Errorf("FAIL. Expected one outcome, but got another '%+v'", <-outcomes)
+			}
+		})
+	}
+}
+
+func TestFallbackWithCallbacks(t *testing.T) {
+	smsClient := &fakeSmsClient{}
+	messages := store.NewMemoryStore()
+	o := fallback.New(&fakeViberClient{}, smsClient).SetMessageStore(messages)
+	o.PollInterval = -1
+	o.SmsSender = "SmsSender"
+
+	attempt, err := o.Send(newMessage())
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	if err := o.HandleViberCallback(&viber.CallbackEvent{MessageId: 429, Status: viber.Undelivered}); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	attempt, err = o.Get(attempt.Id)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+	if !attempt.FallbackTriggered() || attempt.Sms.MessageId != 31885463 || attempt.Outcome != viberplussms.InProgress {
+		t.Fatalf("FAIL. Expected SMS to be sent, but got '%+v'", attempt)
+	}
+	if smsClient.sent[0].Sender != "SmsSender" {
+		t.Errorf("FAIL. Expected SMS sender 'SmsSender', but got '%s'", smsClient.sent[0].Sender)
+	}
+
+	// repeated callback doesn't send another SMS
+	o.ObserveViberStatus(429, viber.Undelivered)
+	if len(smsClient.sent) != 1 {
+		t.Errorf("FAIL. Expected one SMS, but got %d", len(smsClient.sent))
+	}
+
+	if !o.ObserveSmsStatus(31885463, sms.Delivered) {
+		t.Fatalf("FAIL. Expected SMS message to belong to the attempt")
+	}
+	if _, err := o.Get(attempt.Id); err != fallback.ErrNotFound {
+		t.Errorf("FAIL. Expected completed attempt to be removed, but got '%v'", err)
+	}
+
+	viberRecord, err := messages.Get(messaging.Viber, 429)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+	smsRecord, err := messages.Get(messaging.Sms, 31885463)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	if viberRecord.Status != messaging.Undelivered || viberRecord.Metadata[fallback.AttemptIdKey] != attempt.Id {
+		t.Errorf("FAIL. Unexpected Viber record '%+v'", viberRecord)
+	}
+	if smsRecord.Status != messaging.Delivered || smsRecord.Metadata[fallback.FallbackForKey] != strconv.Itoa(429) {
+		t.Errorf("FAIL. Unexpected SMS record '%+v'", smsRecord)
+	}
+}
+
+func TestFallbackUnconfirmed(t *testing.T) {
+	o := fallback.New(&fakeViberClient{statuses: []viber.MessageStatus{viber.Sent}}, &fakeSmsClient{})
+	o.PollInterval = time.Millisecond
+	o.Timeout = 30 * time.Millisecond
+
+	o.Start(context.Background())
+	defer o.Stop()
+
+	attempt, _ := o.Send(newMessage())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	attempt, err := o.Wait(ctx, attempt.Id)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	if attempt.Outcome != viberplussms.Unconfirmed || attempt.FallbackTriggered() {
+		t.Errorf("FAIL. Expected unconfirmed outcome without SMS, but got '%+v'", attempt)
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	viberErr := errors.New("connection refused")
+	o := fallback.New(&fakeViberClient{sendErr: viberErr}, &fakeSmsClient{sendErr: errors.New("connection refused")})
+
+	attempt, err := o.Send(newMessage())
+	if err != viberErr {
+		t.Errorf("FAIL. Expected Viber error, but got '%v'", err)
+	}
+	if attempt.Outcome != viberplussms.NotDelivered || attempt.Sms == nil || attempt.Sms.Error == "" {
+		t.Errorf("FAIL. Expected undelivered outcome with SMS error, but got '%+v'", attempt)
+	}
+}
@@ -0,0 +1,154 @@
+// Package batch contains helpers to perform many per-id requests with bounded concurrency.
+package batch
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultConcurrency = 5
+	defaultRetryDelay  = time.Second
+)
+
+// Options configures the batch execution.
+type Options struct {
+	Concurrency       int              // Concurrency is a maximum number of simultaneous requests (DefaultConcurrency by default).
+	RequestsPerSecond float64          // RequestsPerSecond limits the rate of requests (unlimited by default).
+	MaxRetries        int              // MaxRetries is a number of retries of the request failed with retryable error.
+	RetryDelay        time.Duration    // RetryDelay is a delay before the first retry, it is doubled for every next retry.
+	IsRetryable       func(error) bool // IsRetryable returns true if the request should be retried (e.g. rate limit exceeded).
+}
+
+// Result holds the value or the error returned for the particular id.
+type Result struct {
+	Value interface{}
+	Err   error
+}
+
+// Run calls fetch for every unique id and returns results by id.
+// Duplicate ids are fetched once. fetch gets the context so it can abort the request
+// when the context is done. If the context is done before all ids are fetched,
+// remaining ids get the context error and the context error is returned as well.
+func Run(ctx context.Context, ids []int64, options Options, fetch func(ctx context.Context, id int64) (interface{}, error)) (map[int64]Result, error) {
+	unique := make([]int64, 0, len(ids))
+	seen := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	if concurrency > len(unique) {
+		concurrency = len(unique)
+	}
+
+	limiter := newLimiter(options.RequestsPerSecond)
+	defer limiter.stop()
+
+	var mu sync.Mutex
+	results := make(map[int64]Result, len(unique))
+	jobs := make(chan int64)
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				value, err := fetchWithRetries(ctx, id, options, limiter, fetch)
+				mu.Lock()
+				results[id] = Result{Value: value, Err: err}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, id := range unique {
+		select {
+		case jobs <- id:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, id := range unique {
+		if _, ok := results[id]; !ok {
+			results[id] = Result{Err: ctx.Err()}
+		}
+	}
+
+	return results, ctx.Err()
+}
+
+func fetchWithRetries(ctx context.Context, id int64, options Options, limiter *limiter, fetch func(ctx context.Context, id int64) (interface{}, error)) (interface{}, error) {
+	delay := options.RetryDelay
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		value, err := fetch(ctx, id)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		if err == nil || options.IsRetryable == nil || !options.IsRetryable(err) || attempt >= options.MaxRetries {
+			return value, err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
+
+// limiter allows one request per interval. Zero interval means no limit.
+type limiter struct {
+	ticker *time.Ticker
+}
+
+func newLimiter(requestsPerSecond float64) *limiter {
+	if requestsPerSecond <= 0 {
+		return &limiter{}
+	}
+
+	return &limiter{ticker: time.NewTicker(time.Duration(float64(time.Second) / requestsPerSecond))}
+}
+
+func (l *limiter) wait(ctx context.Context) error {
+	if l.ticker == nil {
+		return ctx.Err()
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-l.ticker.C:
+		return nil
+	}
+}
+
+func (l *limiter) stop() {
+	if l.ticker != nil {
+		l.ticker.Stop()
+	}
+}
@@ -0,0 +1,213 @@
+package batch_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/internal/batch"
+	"github.com/jarcoal/httpmock"
+)
+
+var (
+	errRateLimited = errors.New("rate limit exceeded")
+	errServer      = errors.New("server error")
+)
+
+// blocked is a status code of the response which is never received before the request is canceled.
+const blocked = 0
+
+func statusUrl(id int64) string {
+	return fmt.Sprintf("https://example.com/status/%d", id)
+}
+
+func fetchStatus(ctx context.Context, id int64) (interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, statusUrl(id), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+		body, err := ioutil.ReadAll(response.Body)
+		return string(body), err
+	case http.StatusTooManyRequests:
+		return nil, errRateLimited
+	default:
+		return nil, errServer
+	}
+}
+
+func registerResponses(id int64, codes []int) {
+	if len(codes) == 1 && codes[0] == blocked {
+		httpmock.RegisterResponder("GET", statusUrl(id), func(req *http.Request) (*http.Response, error) {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		})
+		return
+	}
+
+	responses := make([]*http.Response, len(codes))
+	for i, code := range codes {
+		responses[i] = httpmock.NewStringResponse(code, fmt.Sprintf("status of %d", id))
+	}
+	httpmock.RegisterResponder("GET", statusUrl(id), httpmock.ResponderFromMultipleResponses(responses))
+}
+
+func TestRun(t *testing.T) {
+	retryRateLimited := batch.Options{
+		MaxRetries:  2,
+		RetryDelay:  10 * time.Millisecond,
+		IsRetryable: func(err error) bool { return errors.Is(err, errRateLimited) },
+	}
+
+	var inputData = []struct {
+		name          string
+		ids           []int64
+		responses     map[int64][]int
+		options       batch.Options
+		timeout       time.Duration
+		expectedErrs  map[int64]error
+		expectedErr   error
+		expectedCalls int
+		minElapsed    time.Duration
+		maxElapsed    time.Duration
+	}{
+		{
+			name:          "all fetched once",
+			ids:           []int64{1, 2, 2, 3},
+			responses:     map[int64][]int{1: {200}, 2: {200}, 3: {200}},
+			expectedErrs:  map[int64]error{1: nil, 2: nil, 3: nil},
+			expectedCalls: 3,
+		},
+		{
+			name:          "rate limited request is retried with backoff",
+			ids:           []int64{1},
+			responses:     map[int64][]int{1: {429, 429, 200}},
+			options:       retryRateLimited,
+			expectedErrs:  map[int64]error{1: nil},
+			expectedCalls: 3,
+			minElapsed:    30 * time.Millisecond,
+		},
+		{
+			name:          "retries are exhausted",
+			ids:           []int64{1},
+			responses:     map[int64][]int{1: {429, 429, 429}},
+			options:       retryRateLimited,
+			expectedErrs:  map[int64]error{1: errRateLimited},
+			expectedCalls: 3,
+		},
+		{
+			name:          "not retryable error",
+			ids:           []int64{1},
+			responses:     map[int64][]int{1: {500}},
+			options:       retryRateLimited,
+			expectedErrs:  map[int64]error{1: errServer},
+			expectedCalls: 1,
+		},
+		{
+			name:          "partial failure",
+			ids:           []int64{1, 2, 3},
+			responses:     map[int64][]int{1: {200}, 2: {500}, 3: {429, 200}},
+			options:       retryRateLimited,
+			expectedErrs:  map[int64]error{1: nil, 2: errServer, 3: nil},
+			expectedCalls: 4,
+		},
+		{
+			name:          "requests per second",
+			ids:           []int64{1, 2, 3},
+			responses:     map[int64][]int{1: {200}, 2: {200}, 3: {200}},
+			options:       batch.Options{RequestsPerSecond: 50},
+			expectedErrs:  map[int64]error{1: nil, 2: nil, 3: nil},
+			expectedCalls: 3,
+			minElapsed:    60 * time.Millisecond,
+		},
+		{
+			name:          "canceled during backoff",
+			ids:           []int64{1},
+			responses:     map[int64][]int{1: {429, 200}},
+			options:       batch.Options{MaxRetries: 1, RetryDelay: time.Minute, IsRetryable: retryRateLimited.IsRetryable},
+			timeout:       20 * time.Millisecond,
+			expectedErrs:  map[int64]error{1: context.DeadlineExceeded},
+			expectedErr:   context.DeadlineExceeded,
+			expectedCalls: 1,
+			maxElapsed:    time.Second,
+		},
+		{
+			name:          "canceled request",
+			ids:           []int64{1, 2},
+			responses:     map[int64][]int{1: {200}, 2: {blocked}},
+			options:       batch.Options{Concurrency: 1},
+			timeout:       20 * time.Millisecond,
+			expectedErrs:  map[int64]error{1: nil, 2: context.DeadlineExceeded},
+			expectedErr:   context.DeadlineExceeded,
+			expectedCalls: 2,
+			maxElapsed:    time.Second,
+		},
+	}
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			httpmock.Reset()
+			for id, codes := range input.responses {
+				registerResponses(id, codes)
+			}
+
+			ctx := context.Background()
+			if input.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, input.timeout)
+				defer cancel()
+			}
+
+			start := time.Now()
+			results, err := batch.Run(ctx, input.ids, input.options, fetchStatus)
+			elapsed := time.Since(start)
+
+			if !errors.Is(err, input.expectedErr) {
+				t.Errorf("FAIL. Expected error '%v', but got '%v'", input.expectedErr, err)
+			}
+
+			if len(results) != len(input.expectedErrs) {
+				t.Errorf("FAIL. Expected %d results, but got '%+v'", len(input.expectedErrs), results)
+			}
+
+			for id, expectedErr := range input.expectedErrs {
+				result := results[id]
+				if !errors.Is(result.Err, expectedErr) {
+					t.Errorf("FAIL. Expected error '%v' for id %d, but got '%v'", expectedErr, id, result.Err)
+				}
+
+				expectedValue := fmt.Sprintf("status of %d", id)
+				if expectedErr == nil && result.Value != expectedValue {
+					t.Errorf("FAIL. Expected value '%s' for id %d, but got '%v'", expectedValue, id, result.Value)
+				}
+			}
+
+			if calls := httpmock.GetTotalCallCount(); calls != input.expectedCalls {
+				t.Errorf("FAIL. Expected %d requests, but got %d", input.expectedCalls, calls)
+			}
+
+			if elapsed < input.minElapsed {
+				t.Errorf("FAIL. Expected run to take at least %v, but it took %v", input.minElapsed, elapsed)
+			}
+
+			if input.maxElapsed > 0 && elapsed > input.maxElapsed {
+				t.Errorf("FAIL. Expected run to take at most %v, but it took %v", input.maxElapsed, elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Package enum contains helpers to encode integer enums as stable names.
+package enum
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Names maps enum value names to their numeric codes.
+type Names map[string]int64
+
+// Name returns the name of the code, or false if the code has no name.
+func (n Names) Name(code int64) (string, bool) {
+	for name, c := range n {
+		if c == code {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// Text returns the name of the code, or the decimal code if it has no name,
+// so unknown codes survive the round trip.
+func (n Names) Text(code int64) []byte {
+	if name, ok := n.Name(code); ok {
+		return []byte(name)
+	}
+
+	return []byte(strconv.FormatInt(code, 10))
+}
+
+// Parse returns the code of the case-insensitive name found in any of the names
+// (canonical names and aliases), or parses the decimal code.
+// bitSize and unsigned describe the underlying integer type of the enum.
+func Parse(text string, typeName string, bitSize int, unsigned bool, names ...Names) (int64, error) {
+	text = strings.TrimSpace(text)
+	for _, n := range names {
+		for name, code := range n {
+			if strings.EqualFold(name, text) {
+				return code, nil
+			}
+		}
+	}
+
+	if unsigned {
+		if code, err := strconv.ParseUint(text, 10, bitSize); err == nil {
+			return int64(code), nil
+		}
+	} else if code, err := strconv.ParseInt(text, 10, bitSize); err == nil {
+		return code, nil
+	}
+
+	return 0, fmt.Errorf("invalid %s: '%s'", typeName, text)
+}
+
+// UnmarshalJSON decodes a JSON string or number and passes its text to unmarshalText.
+func UnmarshalJSON(data []byte, unmarshalText func(text []byte) error) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	if len(data) > 0 && data[0] == '"' {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+
+		return unmarshalText([]byte(text))
+	}
+
+	return unmarshalText(data)
+}
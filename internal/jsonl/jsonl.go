@@ -0,0 +1,55 @@
+// Package jsonl contains helpers of append-only files with a JSON value per line.
+package jsonl
+
+import (
+	"bufio"
+	"io"
+	"os"
+)
+
+// Replay calls handle for every complete line of the file and returns the size of the complete lines.
+// A missing file has no lines. A line without the trailing new line is a partial write interrupted
+// by a crash, it is skipped.
+func Replay(path string, handle func(line []byte) error) (int64, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var size int64
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			return size, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if err := handle(line); err != nil {
+			return 0, err
+		}
+		size += int64(len(line))
+	}
+}
+
+// OpenAppend opens (or creates) the file for appending and truncates it to the size returned by Replay.
+func OpenAppend(path string, size int64) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	// drop the partial last line, otherwise the next line would be appended to it
+	if err := file.Truncate(size); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return file, nil
+}
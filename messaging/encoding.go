@@ -0,0 +1,42 @@
+package messaging
+
+import "github.com/IT-DecisionTelecom/decisiontelecom-go/internal/enum"
+
+var deliveryStatusNames = enum.Names{
+	"Unknown":     int64(Unknown),
+	"Accepted":    int64(Accepted),
+	"Pending":     int64(Pending),
+	"Sent":        int64(Sent),
+	"Delivered":   int64(Delivered),
+	"Undelivered": int64(Undelivered),
+	"Expired":     int64(Expired),
+	"Rejected":    int64(Rejected),
+	"Failed":      int64(Failed),
+}
+
+// ParseDeliveryStatus parses the delivery status from its name (like "Delivered") or numeric code (like "4").
+func ParseDeliveryStatus(text string) (DeliveryStatus, error) {
+	code, err := enum.Parse(text, "delivery status", 0, false, deliveryStatusNames)
+	return DeliveryStatus(code), err
+}
+
+// MarshalText implements encoding.TextMarshaler interface.
+func (s DeliveryStatus) MarshalText() ([]byte, error) {
+	return deliveryStatusNames.Text(int64(s)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler interface. It accepts names and numeric codes.
+func (s *DeliveryStatus) UnmarshalText(text []byte) error {
+	status, err := ParseDeliveryStatus(string(text))
+	if err != nil {
+		return err
+	}
+
+	*s = status
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface. It accepts names and numeric codes.
+func (s *DeliveryStatus) UnmarshalJSON(data []byte) error {
+	return enum.UnmarshalJSON(data, s.UnmarshalText)
+}
@@ -0,0 +1,43 @@
+package messaging_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+)
+
+func TestDeliveryStatusJsonRoundTrip(t *testing.T) {
+	var inputData = []struct {
+		json           string
+		expectedStatus messaging.DeliveryStatus
+		expectedJson   string
+	}{
+		{`"Delivered"`, messaging.Delivered, `"Delivered"`},
+		{`"rejected"`, messaging.Rejected, `"Rejected"`},
+		{`2`, messaging.Pending, `"Pending"`},
+		{`"42"`, messaging.DeliveryStatus(42), `"42"`},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.json, func(t *testing.T) {
+			var status messaging.DeliveryStatus
+			if err := json.Unmarshal([]byte(input.json), &status); err != nil {
+				t.Fatalf("FAIL. Unexpected error: %v", err)
+			}
+
+			if status != input.expectedStatus {
+				t.Errorf("FAIL. Expected status '%d', but got '%d'", input.expectedStatus, status)
+			}
+
+			data, _ := json.Marshal(status)
+			if string(data) != input.expectedJson {
+				t.Errorf("FAIL. Expected JSON '%s', but got '%s'", input.expectedJson, data)
+			}
+		})
+	}
+
+	if _, err := messaging.ParseDeliveryStatus("Lost"); err == nil {
+		t.Errorf("FAIL. Expected error for unknown status")
+	}
+}
@@ -0,0 +1,216 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	viberplussms "github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+// Recipient represents a receiver of the notification.
+type Recipient struct {
+	Phone string `json:"phone"` // Phone is a receiver phone number in international format.
+}
+
+// Notification represents a message content which can be sent through any channel.
+// Fields which are not supported by the channel are ignored.
+type Notification struct {
+	Sender         string        `json:"sender"`          // Sender is a message sender (phone number or alphanumeric name).
+	Text           string        `json:"text"`            // Text is a message text in the UTF8 format.
+	SmsText        string        `json:"sms_text"`        // SmsText is an alternative text of SMS messages (Text is used if empty).
+	ImageUrl       string        `json:"image_url"`       // ImageUrl is an URL of an image of Viber messages.
+	ButtonCaption  string        `json:"button_caption"`  // ButtonCaption is a button caption of Viber messages.
+	ButtonAction   string        `json:"button_action"`   // ButtonAction is an URL opened when the button of Viber messages is pressed.
+	Transactional  bool          `json:"transactional"`   // Transactional is true for transactional messages, false for promotional ones.
+	TwoWay         bool          `json:"two_way"`         // TwoWay is true if the user may reply to Viber messages.
+	CallbackUrl    string        `json:"callback_url"`    // CallbackUrl is an URL for Viber message status callbacks.
+	ValidityPeriod time.Duration `json:"validity_period"` // ValidityPeriod is a life time of Viber messages (provider default if zero).
+}
+
+// Messenger sends notifications and gets their statuses through a single channel.
+type Messenger interface {
+	// Channel returns the channel the messenger sends through.
+	Channel() Channel
+	// Send sends the notification to the recipient and returns the message id.
+	Send(recipient Recipient, notification *Notification) (int64, error)
+	// Status returns the delivery status of the sent message.
+	Status(messageId int64) (DeliveryStatus, error)
+}
+
+// SmsClient sends SMS messages and gets their statuses. It is implemented by sms.Client.
+type SmsClient interface {
+	SendMessage(message *sms.Message) (int64, error)
+	GetMessageStatus(messageId int64) (sms.MessageStatus, error)
+}
+
+// ViberClient sends Viber messages and gets their statuses. It is implemented by viber.Client.
+type ViberClient interface {
+	SendMessage(message *viber.Message) (int64, error)
+	GetMessageStatus(messageId int64) (*viber.MessageReceipt, error)
+}
+
+// ViberSmsClient sends Viber plus SMS messages and gets their statuses. It is implemented by viber/sms.Client.
+type ViberSmsClient interface {
+	SendMessage(message *viberplussms.Message) (int64, error)
+	GetMessageStatus(messageId int64) (*viberplussms.MessageReceipt, error)
+}
+
+// NewSmsMessenger creates the messenger which sends notifications as SMS messages with delivery reports.
+func NewSmsMessenger(client SmsClient) Messenger {
+	return &smsMessenger{client: client}
+}
+
+// NewViberMessenger creates the messenger which sends notifications as Viber messages.
+func NewViberMessenger(client ViberClient) Messenger {
+	return &viberMessenger{client: client}
+}
+
+// NewViberSmsMessenger creates the messenger which sends notifications as Viber plus SMS messages.
+// The status of the SMS message is reported if the SMS was sent instead of the Viber message.
+func NewViberSmsMessenger(client ViberSmsClient) Messenger {
+	return &viberSmsMessenger{client: client}
+}
+
+// NewSmsMessage creates the SMS message of the notification.
+func NewSmsMessage(recipient Recipient, notification *Notification) *sms.Message {
+	text := notification.SmsText
+	if text == "" {
+		text = notification.Text
+	}
+
+	return sms.NewMessage(recipient.Phone, notification.Sender, text, true)
+}
+
+// NewViberMessage creates the Viber message of the notification. Notifications with an image
+// or a button are sent as TextImageButton messages, two-way notifications as their two-way versions.
+func NewViberMessage(recipient Recipient, notification *Notification) *viber.Message {
+	messageType := viber.TextOnly
+	if notification.ImageUrl != "" || notification.ButtonCaption != "" || notification.ButtonAction != "" {
+		messageType = viber.TextImageButton
+	}
+	if notification.TwoWay {
+		if messageType == viber.TextOnly {
+			messageType = viber.TextOnly2Way
+		} else {
+			messageType = viber.TextImageButton2Way
+		}
+	}
+
+	sourceType := viber.Promotional
+	if notification.Transactional {
+		sourceType = viber.Transactional
+	}
+
+	return viber.NewMessage().
+		SetSender(notification.Sender).
+		SetReceiver(recipient.Phone).
+		SetMessageType(messageType).
+		SetText(notification.Text).
+		SetImageUrl(notification.ImageUrl).
+		SetButtonCaption(notification.ButtonCaption).
+		SetButtonAction(notification.ButtonAction).
+		SetSourceType(sourceType).
+		SetCallbackUrl(notification.CallbackUrl).
+		SetValidityPeriod(int(notification.ValidityPeriod / time.Second))
+}
+
+// NewViberSmsMessage creates the Viber plus SMS message of the notification.
+func NewViberSmsMessage(recipient Recipient, notification *Notification) *viberplussms.Message {
+	message := &viberplussms.Message{Message: *NewViberMessage(recipient, notification)}
+	message.SetSmsText(NewSmsMessage(recipient, notification).Text)
+	return message
+}
+
+type smsMessenger struct {
+	client SmsClient
+}
+
+func (m *smsMessenger) Channel() Channel {
+	return Sms
+}
+
+func (m *smsMessenger) Send(recipient Recipient, notification *Notification) (int64, error) {
+	if err := validate(Sms, recipient, notification); err != nil {
+		return -1, err
+	}
+
+	return m.client.SendMessage(NewSmsMessage(recipient, notification))
+}
+
+func (m *smsMessenger) Status(messageId int64) (DeliveryStatus, error) {
+	status, err := m.client.GetMessageStatus(messageId)
+	if err != nil {
+		return Unknown, err
+	}
+
+	return FromSmsStatus(status), nil
+}
+
+type viberMessenger struct {
+	client ViberClient
+}
+
+func (m *viberMessenger) Channel() Channel {
+	return Viber
+}
+
+func (m *viberMessenger) Send(recipient Recipient, notification *Notification) (int64, error) {
+	if err := validate(Viber, recipient, notification); err != nil {
+		return -1, err
+	}
+
+	return m.client.SendMessage(NewViberMessage(recipient, notification))
+}
+
+func (m *viberMessenger) Status(messageId int64) (DeliveryStatus, error) {
+	receipt, err := m.client.GetMessageStatus(messageId)
+	if err != nil {
+		return Unknown, err
+	}
+
+	return FromViberStatus(receipt.Status), nil
+}
+
+type viberSmsMessenger struct {
+	client ViberSmsClient
+}
+
+func (m *viberSmsMessenger) Channel() Channel {
+	return ViberSms
+}
+
+func (m *viberSmsMessenger) Send(recipient Recipient, notification *Notification) (int64, error) {
+	if err := validate(ViberSms, recipient, notification); err != nil {
+		return -1, err
+	}
+
+	return m.client.SendMessage(NewViberSmsMessage(recipient, notification))
+}
+
+func (m *viberSmsMessenger) Status(messageId int64) (DeliveryStatus, error) {
+	receipt, err := m.client.GetMessageStatus(messageId)
+	if err != nil {
+		return Unknown, err
+	}
+
+	return FromViberSmsReceipt(receipt), nil
+}
+
+// validate checks the notification can be sent through the channel. SMS messages fall back to Text
+// if SmsText is empty, while Viber messages always have Text.
+func validate(channel Channel, recipient Recipient, notification *Notification) error {
+	if recipient.Phone == "" {
+		return errors.New("recipient phone is required")
+	}
+	if channel == Sms && notification.Text == "" && notification.SmsText == "" {
+		return errors.New("notification text is required")
+	}
+	if channel != Sms && notification.Text == "" {
+		return fmt.Errorf("notification text is required for %s messages", channel)
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+package messaging_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	viberplussms "github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+var (
+	_ messaging.SmsClient      = sms.NewClient("<YOUR_LOGIN>", "<YOUR_PASSWORD>")
+	_ messaging.ViberClient    = viber.NewClient("<YOUR_API_KEY>")
+	_ messaging.ViberSmsClient = viberplussms.NewClient("<YOUR_API_KEY>")
+)
+
+type fakeSmsClient struct {
+	sent *sms.Message
+}
+
+func (c *fakeSmsClient) SendMessage(message *sms.Message) (int64, error) {
+	c.sent = message
+	return 31885463, nil
+}
+
+func (c *fakeSmsClient) GetMessageStatus(messageId int64) (sms.MessageStatus, error) {
+	return sms.Delivered, nil
+}
+
+type fakeViberClient struct {
+	sent *viber.Message
+}
+
+func (c *fakeViberClient) SendMessage(message *viber.Message) (int64, error) {
+	c.sent = message
+	return 429, nil
+}
+
+func (c *fakeViberClient) GetMessageStatus(messageId int64) (*viber.MessageReceipt, error) {
+	return &viber.MessageReceipt{MessageId: messageId, Status: viber.Undelivered}, nil
+}
+
+type fakeViberSmsClient struct {
+	sent *viberplussms.Message
+}
+
+func (c *fakeViberSmsClient) SendMessage(message *viberplussms.Message) (int64, error) {
+	c.sent = message
+	return 430, nil
+}
+
+func (c *fakeViberSmsClient) GetMessageStatus(messageId int64) (*viberplussms.MessageReceipt, error) {
+	return &viberplussms.MessageReceipt{MessageId: messageId, Status: viber.Undelivered, SmsMessageId: 31885464, SmsMessageStatus: viberplussms.SmsDelivered}, nil
+}
+
+func TestMessengers(t *testing.T) {
+	recipient := messaging.Recipient{Phone: "380504444444"}
+	notification := &messaging.Notification{
+		Sender:         "Sender",
+		Text:           "Your order is ready",
+		SmsText:        "Order ready",
+		ImageUrl:       "https://yourdomain.com/image.png",
+		ButtonCaption:  "Open",
+		ButtonAction:   "https://yourdomain.com",
+		Transactional:  true,
+		ValidityPeriod: time.Hour,
+	}
+
+	smsClient := &fakeSmsClient{}
+	viberClient := &fakeViberClient{}
+	viberSmsClient := &fakeViberSmsClient{}
+
+	var inputData = []struct {
+		messenger         messaging.Messenger
+		expectedChannel   messaging.Channel
+		expectedMessageId int64
+		expectedStatus    messaging.DeliveryStatus
+	}{
+		{messaging.NewSmsMessenger(smsClient), messaging.Sms, 31885463, messaging.Delivered},
+		{messaging.NewViberMessenger(viberClient), messaging.Viber, 429, messaging.Undelivered},
+		{messaging.NewViberSmsMessenger(viberSmsClient), messaging.ViberSms, 430, messaging.Delivered},
+	}
+
+	for _, input := range inputData {
+		t.Run(string(input.expectedChannel), func(t *testing.T) {
+			if input.messenger.Channel() != input.expectedChannel {
+				t.Errorf("FAIL. Expected channel '%s', but got '%s'", input.expectedChannel, input.messenger.Channel())
+			}
+
+			messageId, err := input.messenger.Send(recipient, notification)
+			if err != nil {
+				t.Fatalf("FAIL. Unexpected error '%v'", err)
+			}
+			if messageId != input.expectedMessageId {
+				t.Errorf("FAIL. Expected messageId '%d', but got '%d'", input.expectedMessageId, messageId)
+			}
+
+			status, err := input.messenger.Status(messageId)
+			if err != nil {
+				t.Fatalf("FAIL. Unexpected error '%v'", err)
+			}
+			if status != input.expectedStatus {
+				t.Errorf("FAIL. Expected status '%s', but got '%s'", input.expectedStatus, status)
+			}
+
+			if _, err := input.messenger.Send(messaging.Recipient{}, notification); err == nil {
+				t.Errorf("FAIL. Expected error for recipient without phone")
+			}
+		})
+	}
+
+	if smsClient.sent.ReceiverPhone != "380504444444" || smsClient.sent.Sender != "Sender" || smsClient.sent.Text != "Order ready" || !smsClient.sent.Delivery {
+		t.Errorf("FAIL. Unexpected SMS message '%+v'", smsClient.sent)
+	}
+
+	viberMessage := viberClient.sent
+	if viberMessage.Receiver != "380504444444" || viberMessage.MessageType != viber.TextImageButton || viberMessage.SourceType != viber.Transactional ||
+		viberMessage.ValidityPeriod != 3600 || viberMessage.ButtonCaption != "Open" {
+		t.Errorf("FAIL. Unexpected Viber message '%+v'", viberMessage)
+	}
+
+	if viberSmsClient.sent.SmsText != "Order ready" || viberSmsClient.sent.Text != "Your order is ready" {
+		t.Errorf("FAIL. Unexpected Viber plus SMS message '%+v'", viberSmsClient.sent)
+	}
+}
+
+func TestSendRequiresText(t *testing.T) {
+	recipient := messaging.Recipient{Phone: "380504444444"}
+	smsMessenger := messaging.NewSmsMessenger(&fakeSmsClient{})
+	viberMessenger := messaging.NewViberMessenger(&fakeViberClient{})
+	viberSmsMessenger := messaging.NewViberSmsMessenger(&fakeViberSmsClient{})
+
+	var inputData = []struct {
+		name          string
+		messenger     messaging.Messenger
+		notification  messaging.Notification
+		expectedError bool
+	}{
+		{"SMS with text", smsMessenger, messaging.Notification{Text: "Your order is ready"}, false},
+		{"SMS with SMS text", smsMessenger, messaging.Notification{SmsText: "Order ready"}, false},
+		{"SMS without text", smsMessenger, messaging.Notification{}, true},
+		{"Viber with text", viberMessenger, messaging.Notification{Text: "Your order is ready"}, false},
+		{"Viber with SMS text only", viberMessenger, messaging.Notification{SmsText: "Order ready"}, true},
+		{"Viber plus SMS with text", viberSmsMessenger, messaging.Notification{Text: "Your order is ready"}, false},
+		{"Viber plus SMS with SMS text only", viberSmsMessenger, messaging.Notification{SmsText: "Order ready"}, true},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			_, err := input.messenger.Send(recipient, &input.notification)
+			if (err != nil) != input.expectedError {
+				t.Errorf("FAIL. Expected error %t, but got '%v'", input.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestNewViberMessageType(t *testing.T) {
+	var inputData = []struct {
+		name         string
+		notification messaging.Notification
+		expectedType viber.MessageType
+	}{
+		{"text", messaging.Notification{Text: "Hi"}, viber.TextOnly},
+		{"image", messaging.Notification{Text: "Hi", ImageUrl: "https://yourdomain.com/image.png"}, viber.TextImageButton},
+		{"two-way text", messaging.Notification{Text: "Hi", TwoWay: true}, viber.TextOnly2Way},
+		{"two-way button", messaging.Notification{Text: "Hi", ButtonCaption: "Open", TwoWay: true}, viber.TextImageButton2Way},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			message := messaging.NewViberMessage(messaging.Recipient{Phone: "380504444444"}, &input.notification)
+			if message.MessageType != input.expectedType {
+				t.Errorf("FAIL. Expected message type '%s', but got '%s'", input.expectedType, message.MessageType)
+			}
+			if message.SourceType != viber.Promotional {
+				t.Errorf("FAIL. Expected promotional message, but got '%s'", message.SourceType)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+// Package messaging contains channel-neutral types shared by SMS, Viber and Viber plus SMS messages.
+package messaging
+
+import (
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	viberplussms "github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+// Channel represents a channel the message is sent through.
+type Channel string
+
+const (
+	Sms      Channel = "sms"       // Sms is a channel of messages sent by sms.Client.
+	Viber    Channel = "viber"     // Viber is a channel of messages sent by viber.Client.
+	ViberSms Channel = "viber_sms" // ViberSms is a channel of messages sent by viber/sms.Client.
+)
+
+// DeliveryStatus represents a message delivery status which is the same for all channels.
+type DeliveryStatus int
+
+const (
+	Unknown     DeliveryStatus = iota // Unknown means the status is not known yet.
+	Accepted                          // Accepted means the message was accepted by the provider, but not sent yet.
+	Pending                           // Pending means the message waits to be sent.
+	Sent                              // Sent means the message was sent, but not delivered yet.
+	Delivered                         // Delivered means the message was delivered to the receiver.
+	Undelivered                       // Undelivered means the message could not be delivered to the receiver.
+	Expired                           // Expired means the message was not delivered during its validity period.
+	Rejected                          // Rejected means the message was rejected by the provider or the receiver.
+	Failed                            // Failed means an error occurred while sending the message.
+)
+
+// String returns the delivery status description.
+func (s DeliveryStatus) String() string {
+	switch s {
+	case Unknown:
+		return "Unknown"
+	case Accepted:
+		return "Accepted"
+	case Pending:
+		return "Pending"
+	case Sent:
+		return "Sent"
+	case Delivered:
+		return "Delivered"
+	case Undelivered:
+		return "Undelivered"
+	case Expired:
+		return "Expired"
+	case Rejected:
+		return "Rejected"
+	case Failed:
+		return "Failed"
+	default:
+		return "Invalid status"
+	}
+}
+
+// IsTerminal returns true if the status will not change anymore.
+func (s DeliveryStatus) IsTerminal() bool {
+	return s.IsSuccess() || s.IsFailure()
+}
+
+// IsSuccess returns true if the message was delivered.
+func (s DeliveryStatus) IsSuccess() bool {
+	return s == Delivered
+}
+
+// IsFailure returns true if the message will never be delivered.
+func (s DeliveryStatus) IsFailure() bool {
+	switch s {
+	case Undelivered, Expired, Rejected, Failed:
+		return true
+	default:
+		return false
+	}
+}
+
+// FromSmsStatus converts the SMS message status to the delivery status.
+func FromSmsStatus(status sms.MessageStatus) DeliveryStatus {
+	switch status {
+	case sms.Accepted:
+		return Accepted
+	case sms.Delivered:
+		return Delivered
+	case sms.Expired:
+		return Expired
+	case sms.Undeliverable:
+		return Undelivered
+	default:
+		return Unknown
+	}
+}
+
+// FromViberStatus converts the Viber message status to the delivery status.
+func FromViberStatus(status viber.MessageStatus) DeliveryStatus {
+	switch status {
+	case viber.Sent:
+		return Sent
+	case viber.Delivered:
+		return Delivered
+	case viber.ErrorStatus:
+		return Failed
+	case viber.Rejected:
+		return Rejected
+	case viber.Undelivered:
+		return Undelivered
+	case viber.Pending:
+		return Pending
+	default:
+		return Unknown
+	}
+}
+
+// FromViberSmsStatus converts the status of the SMS part of the Viber plus SMS message to the delivery status.
+func FromViberSmsStatus(status viberplussms.SmsMessageStatus) DeliveryStatus {
+	switch status {
+	case viberplussms.SmsDelivered:
+		return Delivered
+	case viberplussms.SmsExpired:
+		return Expired
+	case viberplussms.SmsUndeliverable:
+		return Undelivered
+	default:
+		return Unknown
+	}
+}
+
+// FromViberSmsReceipt returns the delivery status of the Viber plus SMS message as a whole.
+// If the Viber message was not delivered and the SMS message was sent instead,
+// the status of the SMS message is returned (Sent while it is not known yet).
+//
+// The failed Viber message without the SMS message is Sent as well, since the SMS message may not be attached
+// to the receipt yet. Promotional messages never get it, so they stay Sent until tracking times out.
+func FromViberSmsReceipt(receipt *viberplussms.MessageReceipt) DeliveryStatus {
+	status := FromViberStatus(receipt.Status)
+	if status == Delivered {
+		return status
+	}
+	if receipt.SmsMessageId == 0 {
+		if status.IsFailure() {
+			return Sent
+		}
+		return status
+	}
+
+	if smsStatus := FromViberSmsStatus(receipt.SmsMessageStatus); smsStatus != Unknown {
+		return smsStatus
+	}
+
+	return Sent
+}
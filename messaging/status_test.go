@@ -0,0 +1,102 @@
+package messaging_test
+
+import (
+	"testing"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	viberplussms "github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+func TestDeliveryStatusConversions(t *testing.T) {
+	var inputData = []struct {
+		name           string
+		status         messaging.DeliveryStatus
+		expectedStatus messaging.DeliveryStatus
+	}{
+		{"SMS Unknown", messaging.FromSmsStatus(sms.Unknown), messaging.Unknown},
+		{"SMS Accepted", messaging.FromSmsStatus(sms.Accepted), messaging.Accepted},
+		{"SMS Delivered", messaging.FromSmsStatus(sms.Delivered), messaging.Delivered},
+		{"SMS Expired", messaging.FromSmsStatus(sms.Expired), messaging.Expired},
+		{"SMS Undeliverable", messaging.FromSmsStatus(sms.Undeliverable), messaging.Undelivered},
+		{"SMS invalid", messaging.FromSmsStatus(sms.MessageStatus(-1)), messaging.Unknown},
+		{"Viber Sent", messaging.FromViberStatus(viber.Sent), messaging.Sent},
+		{"Viber Delivered", messaging.FromViberStatus(viber.Delivered), messaging.Delivered},
+		{"Viber Error", messaging.FromViberStatus(viber.ErrorStatus), messaging.Failed},
+		{"Viber Rejected", messaging.FromViberStatus(viber.Rejected), messaging.Rejected},
+		{"Viber Undelivered", messaging.FromViberStatus(viber.Undelivered), messaging.Undelivered},
+		{"Viber Pending", messaging.FromViberStatus(viber.Pending), messaging.Pending},
+		{"Viber Unknown", messaging.FromViberStatus(viber.Unknown), messaging.Unknown},
+		{"Viber plus SMS Delivered", messaging.FromViberSmsStatus(viberplussms.SmsDelivered), messaging.Delivered},
+		{"Viber plus SMS Expired", messaging.FromViberSmsStatus(viberplussms.SmsExpired), messaging.Expired},
+		{"Viber plus SMS Undeliverable", messaging.FromViberSmsStatus(viberplussms.SmsUndeliverable), messaging.Undelivered},
+		{
+			"Viber plus SMS receipt delivered through Viber",
+			messaging.FromViberSmsReceipt(&viberplussms.MessageReceipt{Status: viber.Delivered}),
+			messaging.Delivered,
+		},
+		{
+			"Viber plus SMS receipt sending through Viber",
+			messaging.FromViberSmsReceipt(&viberplussms.MessageReceipt{Status: viber.Pending}),
+			messaging.Pending,
+		},
+		{
+			"Viber plus SMS receipt before SMS is attached",
+			messaging.FromViberSmsReceipt(&viberplussms.MessageReceipt{Status: viber.Undelivered}),
+			messaging.Sent,
+		},
+		{
+			"Viber plus SMS receipt waiting for SMS",
+			messaging.FromViberSmsReceipt(&viberplussms.MessageReceipt{Status: viber.Undelivered, SmsMessageId: 22}),
+			messaging.Sent,
+		},
+		{
+			"Viber plus SMS receipt delivered through SMS",
+			messaging.FromViberSmsReceipt(&viberplussms.MessageReceipt{Status: viber.Undelivered, SmsMessageId: 22, SmsMessageStatus: viberplussms.SmsDelivered}),
+			messaging.Delivered,
+		},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			if input.status != input.expectedStatus {
+				t.Errorf("FAIL. Expected status '%s', but got '%s'", input.expectedStatus, input.status)
+			}
+		})
+	}
+}
+
+func TestDeliveryStatusClassification(t *testing.T) {
+	var inputData = []struct {
+		status           messaging.DeliveryStatus
+		expectedName     string
+		expectedTerminal bool
+		expectedSuccess  bool
+		expectedFailure  bool
+	}{
+		{messaging.Unknown, "Unknown", false, false, false},
+		{messaging.Accepted, "Accepted", false, false, false},
+		{messaging.Pending, "Pending", false, false, false},
+		{messaging.Sent, "Sent", false, false, false},
+		{messaging.Delivered, "Delivered", true, true, false},
+		{messaging.Undelivered, "Undelivered", true, false, true},
+		{messaging.Expired, "Expired", true, false, true},
+		{messaging.Rejected, "Rejected", true, false, true},
+		{messaging.Failed, "Failed", true, false, true},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.expectedName, func(t *testing.T) {
+			if input.status.String() != input.expectedName {
+				t.Errorf("FAIL. Expected name '%s', but got '%s'", input.expectedName, input.status.String())
+			}
+
+			if input.status.IsTerminal() != input.expectedTerminal ||
+				input.status.IsSuccess() != input.expectedSuccess ||
+				input.status.IsFailure() != input.expectedFailure {
+				t.Errorf("FAIL. Unexpected classification of status '%s'", input.status)
+			}
+		})
+	}
+}
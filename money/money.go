@@ -0,0 +1,333 @@
+// Package money contains an exact decimal amount of money with its currency.
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Decimals is the number of decimal places amounts are kept with.
+const Decimals = 9
+
+const scale = 1_000_000_000 // scale is 10^Decimals.
+
+// ErrOverflow is returned when the amount doesn't fit the supported range (about ±9.2 billion).
+var ErrOverflow = errors.New("money: amount out of range")
+
+// CurrencyMismatchError is returned by operations on amounts of different currencies.
+type CurrencyMismatchError struct {
+	Left  string // Left is the currency of the receiver.
+	Right string // Right is the currency of the argument.
+}
+
+// Error implements error interface.
+func (e CurrencyMismatchError) Error() string {
+	return fmt.Sprintf("money: currency mismatch %s and %s", e.Left, e.Right)
+}
+
+// Money represents an exact decimal amount of money with up to 9 decimal places. The zero value is zero
+// without currency, amounts without currency may be combined with amounts of any currency.
+type Money struct {
+	nanos    int64
+	currency string
+}
+
+// New creates the amount from its integer part and billionths (nanos) of the currency unit.
+// Nanos must have the same sign as units.
+func New(units int64, nanos int64, currency string) (Money, error) {
+	if units > math.MaxInt64/scale || units < math.MinInt64/scale {
+		return Money{}, ErrOverflow
+	}
+
+	return add(Money{nanos: units * scale, currency: currency}, Money{nanos: nanos})
+}
+
+// FromNanos creates the amount from billionths of the currency unit.
+func FromNanos(nanos int64, currency string) Money {
+	return Money{nanos: nanos, currency: currency}
+}
+
+// Zero returns zero amount of the currency.
+func Zero(currency string) Money {
+	return Money{currency: currency}
+}
+
+// Parse parses the decimal amount like "-791.8391870", as returned by the provider.
+// Digits beyond 9 decimal places must be zeros, so the amount is never rounded.
+func Parse(amount string, currency string) (Money, error) {
+	text := strings.TrimSpace(amount)
+	negative := strings.HasPrefix(text, "-")
+	if negative || strings.HasPrefix(text, "+") {
+		text = text[1:]
+	}
+
+	integer, fraction := text, ""
+	if i := strings.IndexByte(text, '.'); i >= 0 {
+		integer, fraction = text[:i], text[i+1:]
+	}
+	if integer == "" && fraction == "" || !isDigits(integer) || !isDigits(fraction) {
+		return Money{}, fmt.Errorf("money: invalid amount %q", amount)
+	}
+
+	if len(fraction) > Decimals {
+		if strings.TrimRight(fraction[Decimals:], "0") != "" {
+			return Money{}, fmt.Errorf("money: amount %q has more than %d decimal places", amount, Decimals)
+		}
+		fraction = fraction[:Decimals]
+	}
+	fraction += strings.Repeat("0", Decimals-len(fraction))
+
+	digits := strings.TrimLeft(integer+fraction, "0")
+	nanos := int64(0)
+	if digits != "" {
+		var err error
+		if nanos, err = strconv.ParseInt(digits, 10, 64); err != nil {
+			return Money{}, ErrOverflow
+		}
+	}
+	if negative {
+		nanos = -nanos
+	}
+
+	return Money{nanos: nanos, currency: currency}, nil
+}
+
+// MustParse is like Parse but panics if the amount is invalid. It simplifies initialization of constants.
+func MustParse(amount string, currency string) Money {
+	m, err := Parse(amount, currency)
+	if err != nil {
+		panic(err)
+	}
+
+	return m
+}
+
+// Currency returns the currency code.
+func (m Money) Currency() string {
+	return m.currency
+}
+
+// WithCurrency returns the same amount in the currency.
+func (m Money) WithCurrency(currency string) Money {
+	return Money{nanos: m.nanos, currency: currency}
+}
+
+// Nanos returns the amount in billionths of the currency unit.
+func (m Money) Nanos() int64 {
+	return m.nanos
+}
+
+// Float64 returns the approximate amount for display and statistics.
+func (m Money) Float64() float64 {
+	return float64(m.nanos) / scale
+}
+
+// Sign returns -1, 0 or 1 depending on the sign of the amount.
+func (m Money) Sign() int {
+	switch {
+	case m.nanos < 0:
+		return -1
+	case m.nanos > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// IsZero returns true if the amount is zero.
+func (m Money) IsZero() bool {
+	return m.nanos == 0
+}
+
+// Cmp compares amounts and returns -1, 0 or 1. Currencies are not compared.
+func (m Money) Cmp(other Money) int {
+	switch {
+	case m.nanos < other.nanos:
+		return -1
+	case m.nanos > other.nanos:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Neg returns the negated amount.
+func (m Money) Neg() Money {
+	return Money{nanos: -m.nanos, currency: m.currency}
+}
+
+// Add returns the sum of the amounts.
+func (m Money) Add(other Money) (Money, error) {
+	return add(m, other)
+}
+
+// Sub returns the difference of the amounts.
+func (m Money) Sub(other Money) (Money, error) {
+	if other.nanos == math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+
+	return add(m, other.Neg())
+}
+
+// Mul returns the amount multiplied by the integer, e.g. the price of a segment by the number of segments.
+func (m Money) Mul(n int64) (Money, error) {
+	if m.nanos == 0 || n == 0 {
+		return Money{currency: m.currency}, nil
+	}
+
+	product := m.nanos * n
+	if product/n != m.nanos || (m.nanos == -1 && n == math.MinInt64) || (n == -1 && m.nanos == math.MinInt64) {
+		return Money{}, ErrOverflow
+	}
+
+	return Money{nanos: product, currency: m.currency}, nil
+}
+
+// MulFloat returns the amount multiplied by the factor and rounded to 9 decimal places.
+// It is meant for estimates (like an expected share of messages), exact amounts should use Mul.
+func (m Money) MulFloat(factor float64) (Money, error) {
+	product := math.Round(float64(m.nanos) * factor)
+	if math.IsNaN(product) || product >= math.MaxInt64 || product < math.MinInt64 {
+		return Money{}, ErrOverflow
+	}
+
+	return Money{nanos: int64(product), currency: m.currency}, nil
+}
+
+// Amount returns the decimal amount without trailing zeros, like "-791.839187".
+func (m Money) Amount() string {
+	return strings.TrimSuffix(strings.TrimRight(m.format(Decimals), "0"), ".")
+}
+
+// Format returns the decimal amount rounded half away from zero to the number of decimal places, like "-791.84".
+func (m Money) Format(decimals int) string {
+	if decimals < 0 {
+		decimals = 0
+	}
+	if decimals >= Decimals {
+		return m.format(Decimals) + strings.Repeat("0", decimals-Decimals)
+	}
+
+	unit := int64(1)
+	for i := decimals; i < Decimals; i++ {
+		unit *= 10
+	}
+
+	// round the absolute value in uint64, so the minimum amount doesn't overflow
+	abs := uint64(m.nanos)
+	if m.nanos < 0 {
+		abs = uint64(-(m.nanos + 1)) + 1
+	}
+	rounded := (abs + uint64(unit)/2) / uint64(unit) * uint64(unit)
+
+	return formatNanos(rounded, m.nanos < 0 && rounded != 0, decimals)
+}
+
+// String returns the amount with its currency, like "-791.839187 EUR".
+func (m Money) String() string {
+	if m.currency == "" {
+		return m.Amount()
+	}
+
+	return m.Amount() + " " + m.currency
+}
+
+type moneyJson struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON implements json.Marshaler interface. The amount is written as a string, so it is not rounded by readers.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJson{Amount: m.Amount(), Currency: m.currency})
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface. It accepts an object with amount and currency,
+// or an amount alone as a string or a number, which is parsed exactly.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	text := strings.TrimSpace(string(data))
+	switch {
+	case text == "null":
+		return nil
+	case strings.HasPrefix(text, "{"):
+		var value moneyJson
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		parsed, err := Parse(value.Amount, value.Currency)
+		if err != nil {
+			return err
+		}
+		*m = parsed
+		return nil
+	case strings.HasPrefix(text, `"`):
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+	}
+
+	parsed, err := Parse(text, m.currency)
+	if err != nil {
+		return err
+	}
+
+	*m = parsed
+	return nil
+}
+
+func (m Money) format(decimals int) string {
+	abs := uint64(m.nanos)
+	if m.nanos < 0 {
+		abs = uint64(-(m.nanos + 1)) + 1
+	}
+
+	return formatNanos(abs, m.nanos < 0, decimals)
+}
+
+// formatNanos formats the absolute amount in nanos with the number of decimal places (up to 9).
+func formatNanos(abs uint64, negative bool, decimals int) string {
+	integer := strconv.FormatUint(abs/scale, 10)
+	fraction := fmt.Sprintf("%09d", abs%scale)[:decimals]
+
+	sign := ""
+	if negative {
+		sign = "-"
+	}
+	if decimals == 0 {
+		return sign + integer
+	}
+
+	return sign + integer + "." + fraction
+}
+
+func add(a Money, b Money) (Money, error) {
+	currency := a.currency
+	switch {
+	case currency == "":
+		currency = b.currency
+	case b.currency != "" && b.currency != currency:
+		return Money{}, CurrencyMismatchError{Left: a.currency, Right: b.currency}
+	}
+
+	sum := a.nanos + b.nanos
+	if (b.nanos > 0 && sum < a.nanos) || (b.nanos < 0 && sum > a.nanos) {
+		return Money{}, ErrOverflow
+	}
+
+	return Money{nanos: sum, currency: currency}, nil
+}
+
+func isDigits(text string) bool {
+	for _, r := range text {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,164 @@
+package money_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/money"
+)
+
+func TestParse(t *testing.T) {
+	var inputData = []struct {
+		amount         string
+		expectedNanos  int64
+		expectedAmount string
+		expectedError  bool
+	}{
+		{"-791.8391870", -791839187000, "-791.839187", false},
+		{"1000", 1000000000000, "1000", false},
+		{"348.879089", 348879089000, "348.879089", false},
+		{"+0.1", 100000000, "0.1", false},
+		{".5", 500000000, "0.5", false},
+		{"5.", 5000000000, "5", false},
+		{"-0", 0, "0", false},
+		{"0.123456789000", 123456789, "0.123456789", false},
+		{"0.1234567891", 0, "", true},
+		{"9223372036.854775807", math.MaxInt64, "9223372036.854775807", false},
+		{"9223372037", 0, "", true},
+		{"", 0, "", true},
+		{".", 0, "", true},
+		{"1e3", 0, "", true},
+		{"12,5", 0, "", true},
+		{"--1", 0, "", true},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.amount, func(t *testing.T) {
+			m, err := money.Parse(input.amount, "EUR")
+			if (err != nil) != input.expectedError {
+				t.Fatalf("FAIL. Expected error: %t, but got '%v'", input.expectedError, err)
+			}
+			if err != nil {
+				return
+			}
+
+			if m.Nanos() != input.expectedNanos || m.Amount() != input.expectedAmount || m.Currency() != "EUR" {
+				t.Errorf("FAIL. Expected %d (%s), but got %d (%s)", input.expectedNanos, input.expectedAmount, m.Nanos(), m.Amount())
+			}
+		})
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a := money.MustParse("0.1", "EUR")
+	b := money.MustParse("0.2", "EUR")
+
+	sum, err := a.Add(b)
+	if err != nil || sum.Cmp(money.MustParse("0.3", "EUR")) != 0 || sum.String() != "0.3 EUR" {
+		t.Errorf("FAIL. Expected 0.3 EUR, but got '%s' (%v)", sum, err)
+	}
+
+	difference, err := a.Sub(b)
+	if err != nil || difference.Amount() != "-0.1" || difference.Sign() != -1 {
+		t.Errorf("FAIL. Expected -0.1, but got '%s' (%v)", difference, err)
+	}
+
+	product, err := money.MustParse("0.0215", "EUR").Mul(3)
+	if err != nil || product.Amount() != "0.0645" {
+		t.Errorf("FAIL. Expected 0.0645, but got '%s' (%v)", product, err)
+	}
+
+	share, err := money.MustParse("0.05", "EUR").MulFloat(0.2)
+	if err != nil || share.Amount() != "0.01" {
+		t.Errorf("FAIL. Expected 0.01, but got '%s' (%v)", share, err)
+	}
+
+	if _, err := a.Add(money.MustParse("1", "USD")); err == nil {
+		t.Errorf("FAIL. Expected currency mismatch error")
+	}
+	var zero money.Money
+	if total, err := zero.Add(a); err != nil || total.Currency() != "EUR" {
+		t.Errorf("FAIL. Expected the zero value to adopt the currency, but got '%s' (%v)", total, err)
+	}
+
+	max := money.FromNanos(math.MaxInt64, "EUR")
+	if _, err := max.Add(money.FromNanos(1, "EUR")); err != money.ErrOverflow {
+		t.Errorf("FAIL. Expected error '%v', but got '%v'", money.ErrOverflow, err)
+	}
+	if _, err := max.Mul(2); err != money.ErrOverflow {
+		t.Errorf("FAIL. Expected error '%v', but got '%v'", money.ErrOverflow, err)
+	}
+
+	if m, err := money.New(-12, -500000000, "EUR"); err != nil || m.Amount() != "-12.5" {
+		t.Errorf("FAIL. Expected -12.5, but got '%s' (%v)", m, err)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	var inputData = []struct {
+		amount   string
+		decimals int
+		expected string
+	}{
+		{"-791.8391870", 2, "-791.84"},
+		{"2.345", 2, "2.35"},
+		{"-2.345", 2, "-2.35"},
+		{"2.344", 2, "2.34"},
+		{"0.004", 2, "0.00"},
+		{"-0.004", 2, "0.00"},
+		{"1000", 0, "1000"},
+		{"999.5", 0, "1000"},
+		{"1.5", 4, "1.5000"},
+		{"1.5", 10, "1.5000000000"},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.amount, func(t *testing.T) {
+			actual := money.MustParse(input.amount, "").Format(input.decimals)
+			if actual != input.expected {
+				t.Errorf("FAIL. Expected '%s', but got '%s'", input.expected, actual)
+			}
+		})
+	}
+
+	if actual := money.FromNanos(math.MinInt64, "").Format(2); actual != "-9223372036.85" {
+		t.Errorf("FAIL. Expected minimum amount formatted, but got '%s'", actual)
+	}
+}
+
+func TestMoneyJson(t *testing.T) {
+	m := money.MustParse("-791.839187", "EUR")
+	data, err := json.Marshal(m)
+	if err != nil || string(data) != `{"amount":"-791.839187","currency":"EUR"}` {
+		t.Errorf("FAIL. Unexpected json '%s' (%v)", data, err)
+	}
+
+	var inputData = []struct {
+		data             string
+		expectedAmount   string
+		expectedCurrency string
+	}{
+		{`{"amount":"-791.839187","currency":"EUR"}`, "-791.839187", "EUR"},
+		{`"0.0215"`, "0.0215", ""},
+		{`0.1`, "0.1", ""},
+		{`1000`, "1000", ""},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.data, func(t *testing.T) {
+			var actual money.Money
+			if err := json.Unmarshal([]byte(input.data), &actual); err != nil {
+				t.Fatalf("FAIL. Unexpected error: %v", err)
+			}
+			if actual.Amount() != input.expectedAmount || actual.Currency() != input.expectedCurrency {
+				t.Errorf("FAIL. Expected '%s %s', but got '%s'", input.expectedAmount, input.expectedCurrency, actual)
+			}
+		})
+	}
+
+	var invalid money.Money
+	if err := json.Unmarshal([]byte(`"abc"`), &invalid); err == nil {
+		t.Errorf("FAIL. Expected error of the invalid amount")
+	}
+}
@@ -0,0 +1,120 @@
+package outbox
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	viberplussms "github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+// State represents a state of the outbox entry.
+type State int
+
+const (
+	Pending  State = iota // Pending means the entry waits to be sent.
+	InFlight              // InFlight means the entry is being sent right now.
+	Sent                  // Sent means the message was accepted by the provider.
+	Failed                // Failed means the message could not be sent and will not be retried.
+)
+
+// String returns the entry state description.
+func (s State) String() string {
+	switch s {
+	case Pending:
+		return "Pending"
+	case InFlight:
+		return "InFlight"
+	case Sent:
+		return "Sent"
+	case Failed:
+		return "Failed"
+	default:
+		return "Invalid state"
+	}
+}
+
+// Lane represents a priority lane of the outbox entry.
+type Lane int
+
+const (
+	PromotionalLane   Lane = iota // PromotionalLane is used for marketing and other bulk traffic.
+	TransactionalLane             // TransactionalLane is used for urgent traffic like password resets, it preempts promotional backlog.
+)
+
+const laneCount = 2
+
+// String returns the lane description.
+func (l Lane) String() string {
+	switch l {
+	case PromotionalLane:
+		return "Promotional"
+	case TransactionalLane:
+		return "Transactional"
+	default:
+		return "Invalid lane"
+	}
+}
+
+func (l Lane) index() int {
+	if l == TransactionalLane {
+		return int(TransactionalLane)
+	}
+
+	return int(PromotionalLane)
+}
+
+// LaneOf returns the default lane of the entry: Viber and Viber plus SMS messages with
+// the Transactional source type and SMS messages which require the delivery receipt
+// are transactional, all other messages are promotional.
+func LaneOf(entry *Entry) Lane {
+	switch {
+	case entry.Viber != nil && entry.Viber.SourceType == viber.Transactional:
+		return TransactionalLane
+	case entry.ViberSms != nil && entry.ViberSms.SourceType == viber.Transactional:
+		return TransactionalLane
+	case entry.Sms != nil && entry.Sms.Delivery:
+		return TransactionalLane
+	default:
+		return PromotionalLane
+	}
+}
+
+// Entry represents a message persisted in the outbox.
+// Exactly one of Sms, Viber and ViberSms message fields is set.
+type Entry struct {
+	Id            string                `json:"id"`                  // Id is an outbox entry id.
+	Sms           *sms.Message          `json:"sms,omitempty"`       // Sms is an SMS message to send.
+	Viber         *viber.Message        `json:"viber,omitempty"`     // Viber is a Viber message to send.
+	ViberSms      *viberplussms.Message `json:"viber_sms,omitempty"` // ViberSms is a Viber plus SMS message to send.
+	Lane          Lane                  `json:"lane"`                // Lane is a priority lane of the entry.
+	State         State                 `json:"state"`               // State is a current entry state.
+	Attempts      int                   `json:"attempts"`            // Attempts is a number of send attempts made so far.
+	MessageId     int64                 `json:"message_id"`          // MessageId is an id returned by the provider once the message is sent.
+	LastError     string                `json:"last_error"`          // LastError is a description of the last send error.
+	CreatedAt     time.Time             `json:"created_at"`          // CreatedAt is a time when the entry was enqueued.
+	UpdatedAt     time.Time             `json:"updated_at"`          // UpdatedAt is a time of the last entry update.
+	NextAttemptAt time.Time             `json:"next_attempt_at"`     // NextAttemptAt is a time of the next send attempt.
+}
+
+// IsFinal returns true if the entry will not be processed anymore.
+func (e *Entry) IsFinal() bool {
+	return e.State == Sent || e.State == Failed
+}
+
+func (e *Entry) clone() *Entry {
+	c := *e
+	return &c
+}
+
+func newEntryId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// fall back to the time based id, it is still unique enough within a single outbox
+		return time.Now().UTC().Format("20060102150405.000000000")
+	}
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,169 @@
+package outbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/internal/jsonl"
+)
+
+// FileStore is a Store which appends every entry change to a file as a JSON line.
+// The latest line of an entry wins, so the file can be replayed after a crash.
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	entries map[string]*Entry
+	Sync    bool // Sync should be true if every write must be flushed to disk before Save returns.
+}
+
+// OpenFileStore opens (or creates) the file store at the given path and replays its content.
+func OpenFileStore(path string) (*FileStore, error) {
+	entries, size, err := replayFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := jsonl.OpenAppend(path, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileStore{path: path, file: file, entries: entries, Sync: true}, nil
+}
+
+// Save implements Store interface.
+func (s *FileStore) Save(entry *Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return fmt.Errorf("outbox file store '%s' is closed", s.path)
+	}
+
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	if s.Sync {
+		if err := s.file.Sync(); err != nil {
+			return err
+		}
+	}
+
+	s.entries[entry.Id] = entry.clone()
+	return nil
+}
+
+// Get implements Store interface.
+func (s *FileStore) Get(id string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return nil, fmt.Errorf("outbox entry '%s' was not found", id)
+	}
+
+	return entry.clone(), nil
+}
+
+// Unfinished implements Store interface.
+func (s *FileStore) Unfinished() ([]*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return unfinished(s.entries), nil
+}
+
+// Compact rewrites the file so it contains only unfinished entries.
+func (s *FileStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return fmt.Errorf("outbox file store '%s' is closed", s.path)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	// the current file stays open and in use until the compacted one replaces it
+	fail := func(err error) error {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	kept := make(map[string]*Entry)
+	writer := bufio.NewWriter(tmp)
+	for _, entry := range unfinished(s.entries) {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return fail(err)
+		}
+		if _, err := writer.Write(append(line, '\n')); err != nil {
+			return fail(err)
+		}
+		kept[entry.Id] = entry
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fail(err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fail(err)
+	}
+
+	// the compacted file is written up to its end, so it is appended to from now on
+	s.file.Close()
+	s.file = tmp
+	s.entries = kept
+	return nil
+}
+
+// Close closes the underlying file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+// replayFile returns the entries saved to the file and the size of its complete lines.
+func replayFile(path string) (map[string]*Entry, int64, error) {
+	entries := make(map[string]*Entry)
+	size, err := jsonl.Replay(path, func(line []byte) error {
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("invalid outbox file '%s': %v", path, err)
+		}
+
+		entries[entry.Id] = &entry
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return entries, size, nil
+}
@@ -0,0 +1,504 @@
+// Package outbox contains a durable outbox which persists messages before they are sent
+// and sends them in the background with retries.
+//
+// Delivery is at-least-once. Entries which were in flight when the process stopped are reconciled
+// with the message store on restart: an entry whose message was recorded is marked as sent.
+// If the process dies after the provider accepted a message but before its record was saved,
+// or no message store is set, the message is sent again after the restart and may be duplicated.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/store"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	viberplussms "github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+// OutboxEntryIdKey is a metadata key of the outbox entry id in the recorded messages.
+const OutboxEntryIdKey = "outbox_entry_id"
+
+const (
+	defaultWorkers     = 4
+	defaultMaxAttempts = 5
+	defaultRetryDelay  = time.Second
+	maxRetryDelay      = 5 * time.Minute
+
+	defaultTransactionalWeight = 10
+	defaultPromotionalWeight   = 1
+)
+
+// SmsSender sends SMS messages. It is implemented by sms.Client.
+type SmsSender interface {
+	SendMessage(message *sms.Message) (int64, error)
+}
+
+// ViberSender sends Viber messages. It is implemented by viber.Client.
+type ViberSender interface {
+	SendMessage(message *viber.Message) (int64, error)
+}
+
+// ViberSmsSender sends Viber plus SMS messages. It is implemented by viber/sms.Client.
+type ViberSmsSender interface {
+	SendMessage(message *viberplussms.Message) (int64, error)
+}
+
+// Outbox persists enqueued messages to the store and sends them using background workers.
+type Outbox struct {
+	Workers     int                // Workers is a number of background workers (4 by default).
+	MaxAttempts int                // MaxAttempts is a maximum number of send attempts per entry (5 by default).
+	RetryDelay  time.Duration      // RetryDelay is a delay before the first retry, it is doubled for every next retry (1 second by default).
+	IsRetryable func(error) bool   // IsRetryable decides whether a send error is temporary (IsRetryableError by default).
+	OnSent      func(entry *Entry) // OnSent is called after the entry message was sent.
+	OnFailed    func(entry *Entry) // OnFailed is called after the entry failed permanently.
+	// OnError is called when the entry state or the message record could not be saved after the send attempt.
+	// The entry keeps the previously saved state in the store then, e.g. a sent entry may be recovered as in flight.
+	OnError func(entry *Entry, err error)
+	Now     func() time.Time // Now returns current time (time.Now by default).
+
+	// TransactionalWeight and PromotionalWeight set how many entries of each lane are sent
+	// in turn while both lanes have backlog (10 and 1 by default).
+	TransactionalWeight int
+	PromotionalWeight   int
+	// MaxPromotionalWait protects promotional entries from starvation: an entry which waited
+	// in the queue longer than this is sent next (no limit by default).
+	MaxPromotionalWait time.Duration
+	// Classify returns the lane of the enqueued entry (LaneOf by default).
+	Classify func(entry *Entry) Lane
+
+	store          Store
+	smsClient      SmsSender
+	viberClient    ViberSender
+	viberSmsClient ViberSmsSender
+	messages       store.MessageStore
+
+	queue   *queue
+	mu      sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+	timers  map[string]*time.Timer
+	running bool
+}
+
+// New creates new outbox which persists entries to the given store.
+func New(store Store) *Outbox {
+	return &Outbox{
+		store:  store,
+		queue:  newQueue(),
+		timers: make(map[string]*time.Timer),
+	}
+}
+
+// SetSmsClient sets the client used to send SMS messages.
+func (o *Outbox) SetSmsClient(client SmsSender) *Outbox {
+	o.smsClient = client
+	return o
+}
+
+// SetViberClient sets the client used to send Viber messages.
+func (o *Outbox) SetViberClient(client ViberSender) *Outbox {
+	o.viberClient = client
+	return o
+}
+
+// SetViberSmsClient sets the client used to send Viber plus SMS messages.
+func (o *Outbox) SetViberSmsClient(client ViberSmsSender) *Outbox {
+	o.viberSmsClient = client
+	return o
+}
+
+// SetMessageStore sets the store where sent messages are recorded. The outbox entry id
+// is saved to the record metadata under the OutboxEntryIdKey key.
+func (o *Outbox) SetMessageStore(messages store.MessageStore) *Outbox {
+	o.messages = messages
+	return o
+}
+
+// EnqueueSms persists the SMS message and schedules it for sending. It returns the outbox entry id.
+func (o *Outbox) EnqueueSms(message *sms.Message) (string, error) {
+	if o.smsClient == nil {
+		return "", errors.New("outbox: SMS client is not set")
+	}
+
+	return o.enqueue(&Entry{Sms: message})
+}
+
+// EnqueueViber persists the Viber message and schedules it for sending. It returns the outbox entry id.
+func (o *Outbox) EnqueueViber(message *viber.Message) (string, error) {
+	if o.viberClient == nil {
+		return "", errors.New("outbox: Viber client is not set")
+	}
+
+	return o.enqueue(&Entry{Viber: message})
+}
+
+// EnqueueViberSms persists the Viber plus SMS message and schedules it for sending. It returns the outbox entry id.
+func (o *Outbox) EnqueueViberSms(message *viberplussms.Message) (string, error) {
+	if o.viberSmsClient == nil {
+		return "", errors.New("outbox: Viber plus SMS client is not set")
+	}
+
+	return o.enqueue(&Entry{ViberSms: message})
+}
+
+// Get returns the current state of the outbox entry.
+func (o *Outbox) Get(id string) (*Entry, error) {
+	return o.store.Get(id)
+}
+
+// Start recovers unfinished entries from the store and starts background workers.
+// Entries which were in flight when the process stopped are marked as sent if their messages
+// were recorded to the message store, otherwise they are sent again.
+func (o *Outbox) Start(ctx context.Context) error {
+	o.mu.Lock()
+	if o.running {
+		o.mu.Unlock()
+		return errors.New("outbox: already started")
+	}
+	o.ctx, o.cancel = context.WithCancel(ctx)
+	o.running = true
+	o.mu.Unlock()
+
+	o.queue.configure(o.TransactionalWeight, o.PromotionalWeight, o.MaxPromotionalWait, o.now)
+
+	if err := o.recover(); err != nil {
+		o.Stop()
+		return err
+	}
+
+	workers := o.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+
+	for i := 0; i < workers; i++ {
+		o.wg.Add(1)
+		go o.work()
+	}
+
+	return nil
+}
+
+// Stop stops background workers and waits until messages being sent are processed.
+// Entries waiting for the retry stay pending in the store and are recovered by the next Start.
+func (o *Outbox) Stop() {
+	o.mu.Lock()
+	if !o.running {
+		o.mu.Unlock()
+		return
+	}
+	o.running = false
+	o.cancel()
+	for id, timer := range o.timers {
+		timer.Stop()
+		delete(o.timers, id)
+	}
+	o.mu.Unlock()
+
+	o.wg.Wait()
+	o.queue.clear()
+}
+
+// Pause stops sending queued entries, e.g. when funds run out. Entries are still enqueued and persisted,
+// messages being sent are processed. The outbox stays paused after Stop and Start.
+func (o *Outbox) Pause() {
+	o.queue.setPaused(true)
+}
+
+// Resume resumes sending queued entries after Pause.
+func (o *Outbox) Resume() {
+	o.queue.setPaused(false)
+}
+
+// IsPaused returns true if sending is paused.
+func (o *Outbox) IsPaused() bool {
+	return o.queue.isPaused()
+}
+
+func (o *Outbox) enqueue(entry *Entry) (string, error) {
+	now := o.now()
+	entry.Id = newEntryId()
+	entry.State = Pending
+	entry.CreatedAt = now
+	entry.UpdatedAt = now
+	entry.NextAttemptAt = now
+	if o.Classify != nil {
+		entry.Lane = o.Classify(entry)
+	} else {
+		entry.Lane = LaneOf(entry)
+	}
+
+	if err := o.store.Save(entry); err != nil {
+		return "", err
+	}
+
+	o.queue.push(entry)
+	return entry.Id, nil
+}
+
+func (o *Outbox) recover() error {
+	entries, err := o.store.Unfinished()
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if entry.State == InFlight {
+			messageId, sent, err := o.reconcile(entry)
+			if err != nil {
+				return err
+			}
+
+			entry.UpdatedAt = o.now()
+			if sent {
+				// the provider accepted the message, only the entry state was not saved
+				entry.State = Sent
+				entry.MessageId = messageId
+				entry.LastError = ""
+				if err := o.store.Save(entry); err != nil {
+					return err
+				}
+				if o.OnSent != nil {
+					o.OnSent(entry.clone())
+				}
+				continue
+			}
+
+			entry.State = Pending
+			entry.LastError = "send was interrupted, retrying"
+			if err := o.store.Save(entry); err != nil {
+				return err
+			}
+		}
+
+		o.schedule(entry)
+	}
+
+	return nil
+}
+
+func (o *Outbox) work() {
+	defer o.wg.Done()
+
+	for {
+		entry, ok := o.queue.pop(o.ctx)
+		if !ok {
+			return
+		}
+
+		o.process(entry)
+	}
+}
+
+func (o *Outbox) process(entry *Entry) {
+	entry.State = InFlight
+	entry.Attempts++
+	entry.UpdatedAt = o.now()
+	if err := o.store.Save(entry); err != nil {
+		// the entry cannot be tracked without the store, try again later
+		entry.State = Pending
+		entry.Attempts--
+		o.retryLater(entry, err)
+		return
+	}
+
+	messageId, err := o.send(entry)
+	entry.UpdatedAt = o.now()
+
+	if err == nil {
+		entry.State = Sent
+		entry.MessageId = messageId
+		entry.LastError = ""
+		o.recordMessage(entry)
+		o.save(entry)
+		if o.OnSent != nil {
+			o.OnSent(entry.clone())
+		}
+		return
+	}
+
+	entry.LastError = err.Error()
+	if o.isRetryable(err) && entry.Attempts < o.maxAttempts() {
+		entry.State = Pending
+		entry.NextAttemptAt = entry.UpdatedAt.Add(o.retryDelay(entry.Attempts))
+		o.save(entry)
+		o.schedule(entry)
+		return
+	}
+
+	entry.State = Failed
+	o.save(entry)
+	if o.OnFailed != nil {
+		o.OnFailed(entry.clone())
+	}
+}
+
+// reconcile looks up the message of the in-flight entry in the message store. It returns the message id
+// and true if the message was recorded, i.e. the provider accepted it before the process stopped.
+func (o *Outbox) reconcile(entry *Entry) (int64, bool, error) {
+	record := newRecord(entry)
+	if o.messages == nil || record == nil {
+		return 0, false, nil
+	}
+
+	// the record is saved after the entry became in flight, so older messages are skipped
+	records, err := o.messages.Find(store.Query{Channel: record.Channel, Recipient: record.Recipient, From: entry.UpdatedAt})
+	if err != nil {
+		return 0, false, err
+	}
+
+	for _, r := range records {
+		if r.Metadata[OutboxEntryIdKey] == entry.Id {
+			return r.MessageId, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+func (o *Outbox) recordMessage(entry *Entry) {
+	record := newRecord(entry)
+	if o.messages == nil || record == nil {
+		return
+	}
+
+	record.SentAt = entry.UpdatedAt
+	record.Metadata = map[string]string{OutboxEntryIdKey: entry.Id}
+	// the message was sent already, the entry is the source of truth if the record is not saved
+	if err := o.messages.SaveMessage(record); err != nil {
+		o.reportError(entry, err)
+	}
+}
+
+// save saves the entry state changed after the send attempt and reports the error, as the attempt can't be undone.
+func (o *Outbox) save(entry *Entry) {
+	if err := o.store.Save(entry); err != nil {
+		o.reportError(entry, err)
+	}
+}
+
+func (o *Outbox) reportError(entry *Entry, err error) {
+	if o.OnError != nil {
+		o.OnError(entry.clone(), err)
+	}
+}
+
+func newRecord(entry *Entry) *store.MessageRecord {
+	switch {
+	case entry.Sms != nil:
+		return store.NewSmsRecord(entry.MessageId, entry.Sms)
+	case entry.Viber != nil:
+		return store.NewViberRecord(entry.MessageId, entry.Viber)
+	case entry.ViberSms != nil:
+		return store.NewViberSmsRecord(entry.MessageId, entry.ViberSms)
+	default:
+		return nil
+	}
+}
+
+func (o *Outbox) send(entry *Entry) (int64, error) {
+	switch {
+	case entry.Sms != nil && o.smsClient != nil:
+		return o.smsClient.SendMessage(entry.Sms)
+	case entry.Viber != nil && o.viberClient != nil:
+		return o.viberClient.SendMessage(entry.Viber)
+	case entry.ViberSms != nil && o.viberSmsClient != nil:
+		return o.viberSmsClient.SendMessage(entry.ViberSms)
+	default:
+		return -1, fmt.Errorf("outbox: no client configured for entry '%s'", entry.Id)
+	}
+}
+
+func (o *Outbox) retryLater(entry *Entry, err error) {
+	entry.LastError = err.Error()
+	entry.NextAttemptAt = o.now().Add(o.retryDelay(1))
+	o.schedule(entry)
+}
+
+// schedule pushes the entry to the queue once its next attempt time comes.
+func (o *Outbox) schedule(entry *Entry) {
+	delay := entry.NextAttemptAt.Sub(o.now())
+	if delay <= 0 {
+		o.queue.push(entry)
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if !o.running {
+		return
+	}
+
+	o.timers[entry.Id] = time.AfterFunc(delay, func() {
+		o.mu.Lock()
+		delete(o.timers, entry.Id)
+		running := o.running
+		o.mu.Unlock()
+
+		if running {
+			o.queue.push(entry)
+		}
+	})
+}
+
+func (o *Outbox) isRetryable(err error) bool {
+	if o.IsRetryable != nil {
+		return o.IsRetryable(err)
+	}
+
+	return IsRetryableError(err)
+}
+
+func (o *Outbox) maxAttempts() int {
+	if o.MaxAttempts <= 0 {
+		return defaultMaxAttempts
+	}
+
+	return o.MaxAttempts
+}
+
+func (o *Outbox) retryDelay(attempt int) time.Duration {
+	delay := o.RetryDelay
+	if delay <= 0 {
+		delay = defaultRetryDelay
+	}
+
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+
+	return delay
+}
+
+func (o *Outbox) now() time.Time {
+	if o.Now != nil {
+		return o.Now()
+	}
+
+	return time.Now()
+}
+
+// IsRetryableError returns true if the send error is temporary.
+// SMS and Viber errors caused by the message content or account settings are permanent,
+// while connection errors, rate limits and server errors are retried.
+func IsRetryableError(err error) bool {
+	switch e := err.(type) {
+	case sms.Error:
+		return false
+	case viber.Error:
+		return e.Status == 429 || e.Status >= 500
+	default:
+		return true
+	}
+}
@@ -0,0 +1,521 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/outbox"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/store"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+)
+
+type fakeSmsSender struct {
+	mu     sync.Mutex
+	errors []error
+	sent   []*sms.Message
+	nextId int64
+}
+
+func newFakeSmsSender(errors ...error) *fakeSmsSender {
+	return &fakeSmsSender{errors: errors, nextId: 100}
+}
+
+func (s *fakeSmsSender) SendMessage(message *sms.Message) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.errors) > 0 {
+		err := s.errors[0]
+		s.errors = s.errors[1:]
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	s.sent = append(s.sent, message)
+	s.nextId++
+	return s.nextId, nil
+}
+
+type fakeViberSender struct {
+	sent chan *viber.Message
+}
+
+func (s *fakeViberSender) SendMessage(message *viber.Message) (int64, error) {
+	s.sent <- message
+	return 429, nil
+}
+
+func waitForEntry(t *testing.T, box *outbox.Outbox, id string, state outbox.State) *outbox.Entry {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		entry, err := box.Get(id)
+		if err != nil {
+			t.Fatalf("FAIL. Unexpected error '%v'", err)
+		}
+		if entry.State == state {
+			return entry
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatalf("FAIL. Entry '%s' did not reach state '%s'", id, state)
+	return nil
+}
+
+func TestSendEnqueuedMessages(t *testing.T) {
+	var inputData = []struct {
+		name              string
+		errors            []error
+		expectedState     outbox.State
+		expectedAttempts  int
+		expectedMessageId int64
+	}{
+		{"sent at first attempt", nil, outbox.Sent, 1, 101},
+		{"retried after connection error", []error{errors.New("connection reset")}, outbox.Sent, 2, 101},
+		{"failed because of SMS error", []error{sms.Error{Code: sms.InvalidNumber}}, outbox.Failed, 1, 0},
+		{"failed after max attempts", []error{errors.New("timeout"), errors.New("timeout"), errors.New("timeout")}, outbox.Failed, 3, 0},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			sender := newFakeSmsSender(input.errors...)
+			box := outbox.New(outbox.NewMemoryStore()).SetSmsClient(sender)
+			box.MaxAttempts = 3
+			box.RetryDelay = time.Millisecond
+
+			if err := box.Start(context.Background()); err != nil {
+				t.Fatalf("FAIL. Unexpected error '%v'", err)
+			}
+			defer box.Stop()
+
+			id, err := box.EnqueueSms(sms.NewMessage("380504444444", "380505555555", "Test sms", true))
+			if err != nil {
+				t.Fatalf("FAIL. Unexpected error '%v'", err)
+			}
+
+			entry := waitForEntry(t, box, id, input.expectedState)
+			if entry.Attempts != input.expectedAttempts {
+				t.Errorf("FAIL. Expected attempts '%d', but got '%d'", input.expectedAttempts, entry.Attempts)
+			}
+
+			if entry.MessageId != input.expectedMessageId {
+				t.Errorf("FAIL. Expected messageId '%d', but got '%d'", input.expectedMessageId, entry.MessageId)
+			}
+		})
+	}
+}
+
+func TestRecordSentMessages(t *testing.T) {
+	messages := store.NewMemoryStore()
+	box := outbox.New(outbox.NewMemoryStore()).SetSmsClient(newFakeSmsSender()).SetMessageStore(messages)
+
+	if err := box.Start(context.Background()); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+	defer box.Stop()
+
+	id, err := box.EnqueueSms(sms.NewMessage("380504444444", "380505555555", "Test sms", true))
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+	entry := waitForEntry(t, box, id, outbox.Sent)
+
+	record, err := messages.Get(messaging.Sms, entry.MessageId)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	if record.Recipient != "380504444444" || record.Sender != "380505555555" || record.Metadata[outbox.OutboxEntryIdKey] != id {
+		t.Errorf("FAIL. Unexpected record '%+v'", record)
+	}
+}
+
+// failingStore fails to save attempted entries in the failing state.
+type failingStore struct {
+	*outbox.MemoryStore
+	failing outbox.State
+}
+
+func (s *failingStore) Save(entry *outbox.Entry) error {
+	if entry.State == s.failing && entry.Attempts > 0 {
+		return errors.New("disk is full")
+	}
+
+	return s.MemoryStore.Save(entry)
+}
+
+func TestReportSaveErrors(t *testing.T) {
+	var inputData = []struct {
+		name        string
+		failing     outbox.State
+		sendErrors  []error
+		maxAttempts int
+	}{
+		{"sent", outbox.Sent, nil, 1},
+		{"failed", outbox.Failed, []error{sms.Error{Code: sms.InvalidNumber}}, 1},
+		{"retry", outbox.Pending, []error{errors.New("timeout")}, 2},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			errs := make(chan error, 10)
+			box := outbox.New(&failingStore{MemoryStore: outbox.NewMemoryStore(), failing: input.failing}).
+				SetSmsClient(newFakeSmsSender(input.sendErrors...))
+			box.MaxAttempts = input.maxAttempts
+			box.RetryDelay = time.Millisecond
+			box.OnError = func(entry *outbox.Entry, err error) {
+				if entry.State == input.failing {
+					errs <- err
+				}
+			}
+
+			if err := box.Start(context.Background()); err != nil {
+				t.Fatalf("FAIL. Unexpected error '%v'", err)
+			}
+			defer box.Stop()
+
+			if _, err := box.EnqueueSms(sms.NewMessage("380504444444", "380505555555", "Test sms", true)); err != nil {
+				t.Fatalf("FAIL. Unexpected error '%v'", err)
+			}
+
+			select {
+			case err := <-errs:
+				if err.Error() != "disk is full" {
+					t.Errorf("FAIL. Expected error 'disk is full', but got '%v'", err)
+				}
+			case <-time.After(2 * time.Second):
+				t.Errorf("FAIL. Expected the save error to be reported")
+			}
+		})
+	}
+}
+
+func TestPauseAndResume(t *testing.T) {
+	sender := newFakeSmsSender()
+	box := outbox.New(outbox.NewMemoryStore()).SetSmsClient(sender)
+	box.Pause()
+
+	if err := box.Start(context.Background()); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+	defer box.Stop()
+
+	id, err := box.EnqueueSms(sms.NewMessage("380504444444", "380505555555", "Test sms", true))
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if entry, _ := box.Get(id); entry.State != outbox.Pending || !box.IsPaused() {
+		t.Fatalf("FAIL. Expected the entry to stay pending while paused, but got '%s'", entry.State)
+	}
+
+	box.Resume()
+	waitForEntry(t, box, id, outbox.Sent)
+	if box.IsPaused() {
+		t.Errorf("FAIL. Expected the outbox to be resumed")
+	}
+}
+
+func TestEnqueueWithoutClient(t *testing.T) {
+	box := outbox.New(outbox.NewMemoryStore()).SetSmsClient(newFakeSmsSender())
+
+	if _, err := box.EnqueueViber(viber.NewMessage()); err == nil {
+		t.Errorf("FAIL. Expected error, but got nil")
+	}
+}
+
+func TestRecoverUnfinishedEntries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.log")
+
+	// simulate the process which accepted two messages and died while sending them
+	store, err := outbox.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	now := time.Now()
+	entries := []*outbox.Entry{
+		{Id: "pending", Viber: viber.NewMessage().SetText("pending"), State: outbox.Pending, CreatedAt: now},
+		{Id: "in-flight", Viber: viber.NewMessage().SetText("in-flight"), State: outbox.InFlight, Attempts: 1, CreatedAt: now.Add(time.Millisecond)},
+		{Id: "sent", Viber: viber.NewMessage().SetText("sent"), State: outbox.Sent, Attempts: 1, MessageId: 41, CreatedAt: now.Add(3 * time.Millisecond)},
+	}
+	for _, entry := range entries {
+		if err := store.Save(entry); err != nil {
+			t.Fatalf("FAIL. Unexpected error '%v'", err)
+		}
+	}
+	store.Close()
+
+	store, err = outbox.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+	defer store.Close()
+
+	sender := &fakeViberSender{sent: make(chan *viber.Message, 10)}
+	box := outbox.New(store).SetViberClient(sender)
+	if err := box.Start(context.Background()); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+	defer box.Stop()
+
+	waitForEntry(t, box, "pending", outbox.Sent)
+	waitForEntry(t, box, "in-flight", outbox.Sent)
+
+	close(sender.sent)
+	var texts []string
+	for message := range sender.sent {
+		texts = append(texts, message.Text)
+	}
+	sort.Strings(texts)
+
+	if len(texts) != 2 || texts[0] != "in-flight" || texts[1] != "pending" {
+		t.Errorf("FAIL. Expected resent messages '[in-flight pending]', but got '%v'", texts)
+	}
+}
+
+func TestReconcileInFlightEntries(t *testing.T) {
+	// simulate the process which died while sending two messages: the provider accepted
+	// the recorded one, while it is not known whether the other one was accepted
+	now := time.Now()
+	entries := outbox.NewMemoryStore()
+	for _, entry := range []*outbox.Entry{
+		{Id: "recorded", Sms: sms.NewMessage("380504444444", "", "recorded", true), State: outbox.InFlight, Attempts: 1, CreatedAt: now, UpdatedAt: now},
+		{Id: "not-recorded", Sms: sms.NewMessage("380504444444", "", "not recorded", true), State: outbox.InFlight, Attempts: 1, CreatedAt: now, UpdatedAt: now},
+	} {
+		entries.Save(entry)
+	}
+
+	messages := store.NewMemoryStore()
+	record := store.NewSmsRecord(77, sms.NewMessage("380504444444", "", "recorded", true))
+	record.SentAt = now.Add(time.Millisecond)
+	record.Metadata = map[string]string{outbox.OutboxEntryIdKey: "recorded"}
+	if err := messages.SaveMessage(record); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	sender := newFakeSmsSender()
+	box := outbox.New(entries).SetSmsClient(sender).SetMessageStore(messages)
+	if err := box.Start(context.Background()); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+	defer box.Stop()
+
+	if entry := waitForEntry(t, box, "recorded", outbox.Sent); entry.MessageId != 77 {
+		t.Errorf("FAIL. Expected message id '77', but got '%d'", entry.MessageId)
+	}
+
+	// the message may have been accepted before the process died, so it may be duplicated
+	waitForEntry(t, box, "not-recorded", outbox.Sent)
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+	if len(sender.sent) != 1 || sender.sent[0].Text != "not recorded" {
+		t.Errorf("FAIL. Expected only the not recorded message to be sent again, but got %d messages", len(sender.sent))
+	}
+}
+
+func TestFileStorePartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.log")
+
+	store, err := outbox.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+	store.Save(&outbox.Entry{Id: "first", Viber: viber.NewMessage().SetText("first"), State: outbox.Pending})
+	store.Close()
+
+	// simulate a write interrupted by a crash
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	file.WriteString(`{"id":"partial","state"`)
+	file.Close()
+
+	store, err = outbox.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+	if _, err := store.Get("partial"); err == nil {
+		t.Errorf("FAIL. Expected the partial entry to be skipped")
+	}
+	if err := store.Save(&outbox.Entry{Id: "second", Viber: viber.NewMessage().SetText("second"), State: outbox.Pending}); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+	store.Close()
+
+	store, err = outbox.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("FAIL. Expected entries after the partial line to be readable, but got error '%v'", err)
+	}
+	defer store.Close()
+
+	for _, id := range []string{"first", "second"} {
+		if _, err := store.Get(id); err != nil {
+			t.Errorf("FAIL. Unexpected error '%v'", err)
+		}
+	}
+}
+
+type gatedViberSender struct {
+	mu      sync.Mutex
+	texts   []string
+	started chan struct{}
+	release chan struct{}
+}
+
+func (s *gatedViberSender) SendMessage(message *viber.Message) (int64, error) {
+	s.mu.Lock()
+	s.texts = append(s.texts, message.Text)
+	s.mu.Unlock()
+
+	s.started <- struct{}{}
+	<-s.release
+	return 429, nil
+}
+
+func TestFileStoreCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "outbox.log")
+	store, err := outbox.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	now := time.Now()
+	for _, entry := range []*outbox.Entry{
+		{Id: "sent", Viber: viber.NewMessage(), State: outbox.Sent, CreatedAt: now},
+		{Id: "pending", Viber: viber.NewMessage(), State: outbox.Pending, CreatedAt: now},
+	} {
+		if err := store.Save(entry); err != nil {
+			t.Fatalf("FAIL. Unexpected error '%v'", err)
+		}
+	}
+
+	if err := store.Compact(); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+	if err := store.Save(&outbox.Entry{Id: "after", Viber: viber.NewMessage(), State: outbox.Pending, CreatedAt: now}); err != nil {
+		t.Fatalf("FAIL. Unexpected error after compaction '%v'", err)
+	}
+	store.Close()
+
+	store, err = outbox.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+	defer store.Close()
+
+	if _, err := store.Get("sent"); err == nil {
+		t.Errorf("FAIL. Expected the sent entry to be compacted")
+	}
+	for _, id := range []string{"pending", "after"} {
+		if _, err := store.Get(id); err != nil {
+			t.Errorf("FAIL. Unexpected error '%v'", err)
+		}
+	}
+}
+
+func TestPriorityLanes(t *testing.T) {
+	var inputData = []struct {
+		name                string
+		transactionalWeight int
+		promotionalWeight   int
+		maxPromotionalWait  time.Duration
+		expectedOrder       string
+	}{
+		{"transactional preempts promotional", 0, 0, 0, "blocker,t1,t2,t3,p1,p2"},
+		{"equal weights", 1, 1, 0, "blocker,t1,t2,p1,t3,p2"},
+		{"starvation protection", 0, 0, time.Minute, "blocker,p1,p2,t1,t2,t3"},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			var mu sync.Mutex
+			now := time.Now()
+			clock := func() time.Time {
+				mu.Lock()
+				defer mu.Unlock()
+				return now
+			}
+
+			sender := &gatedViberSender{started: make(chan struct{}, 10), release: make(chan struct{})}
+			box := outbox.New(outbox.NewMemoryStore()).SetViberClient(sender)
+			box.Workers = 1
+			box.Now = clock
+			box.TransactionalWeight = input.transactionalWeight
+			box.PromotionalWeight = input.promotionalWeight
+			box.MaxPromotionalWait = input.maxPromotionalWait
+
+			if err := box.Start(context.Background()); err != nil {
+				t.Fatalf("FAIL. Unexpected error '%v'", err)
+			}
+			defer box.Stop()
+
+			enqueue := func(text string, sourceType viber.MessageSourceType) string {
+				id, err := box.EnqueueViber(viber.NewMessage().SetText(text).SetSourceType(sourceType))
+				if err != nil {
+					t.Fatalf("FAIL. Unexpected error '%v'", err)
+				}
+				return id
+			}
+
+			// keep the only worker busy while the backlog is built
+			enqueue("blocker", viber.Promotional)
+			<-sender.started
+
+			enqueue("p1", viber.Promotional)
+			enqueue("p2", viber.Promotional)
+
+			mu.Lock()
+			now = now.Add(2 * time.Minute)
+			mu.Unlock()
+
+			enqueue("t1", viber.Transactional)
+			enqueue("t2", viber.Transactional)
+			last := enqueue("t3", viber.Transactional)
+
+			close(sender.release)
+			waitForEntry(t, box, last, outbox.Sent)
+			for i := 0; i < 5; i++ {
+				<-sender.started
+			}
+
+			sender.mu.Lock()
+			order := strings.Join(sender.texts, ",")
+			sender.mu.Unlock()
+
+			if order != input.expectedOrder {
+				t.Errorf("FAIL. Expected send order '%s', but got '%s'", input.expectedOrder, order)
+			}
+		})
+	}
+}
+
+func TestLaneOf(t *testing.T) {
+	var inputData = []struct {
+		name         string
+		entry        *outbox.Entry
+		expectedLane outbox.Lane
+	}{
+		{"transactional Viber", &outbox.Entry{Viber: viber.NewMessage().SetSourceType(viber.Transactional)}, outbox.TransactionalLane},
+		{"promotional Viber", &outbox.Entry{Viber: viber.NewMessage().SetSourceType(viber.Promotional)}, outbox.PromotionalLane},
+		{"SMS with delivery receipt", &outbox.Entry{Sms: sms.NewMessage("", "", "", true)}, outbox.TransactionalLane},
+		{"SMS without delivery receipt", &outbox.Entry{Sms: sms.NewMessage("", "", "", false)}, outbox.PromotionalLane},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			if lane := outbox.LaneOf(input.entry); lane != input.expectedLane {
+				t.Errorf("FAIL. Expected lane '%s', but got '%s'", input.expectedLane, lane)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package outbox
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// queue is a queue of entries ready to be sent, split into transactional and promotional lanes.
+// An entry is queued at most once, so recovered entries don't duplicate the enqueued ones.
+//
+// Lanes are served by weighted round-robin: every round serves up to transactionalWeight
+// transactional entries and then up to promotionalWeight promotional ones. A promotional
+// entry which waited longer than maxPromotionalWait is served next regardless of the round.
+type queue struct {
+	mu     sync.Mutex
+	lanes  [laneCount][]queueItem
+	queued map[string]bool
+	served [laneCount]int
+	signal chan struct{}
+	paused bool
+
+	transactionalWeight int
+	promotionalWeight   int
+	maxPromotionalWait  time.Duration
+	now                 func() time.Time
+}
+
+type queueItem struct {
+	entry    *Entry
+	queuedAt time.Time
+}
+
+func newQueue() *queue {
+	return &queue{
+		queued:              make(map[string]bool),
+		signal:              make(chan struct{}, 1),
+		transactionalWeight: defaultTransactionalWeight,
+		promotionalWeight:   defaultPromotionalWeight,
+		now:                 time.Now,
+	}
+}
+
+// configure sets lane weights and starvation protection. Non positive values keep defaults.
+func (q *queue) configure(transactionalWeight int, promotionalWeight int, maxPromotionalWait time.Duration, now func() time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if transactionalWeight > 0 {
+		q.transactionalWeight = transactionalWeight
+	}
+	if promotionalWeight > 0 {
+		q.promotionalWeight = promotionalWeight
+	}
+	q.maxPromotionalWait = maxPromotionalWait
+	q.now = now
+}
+
+func (q *queue) push(entry *Entry) {
+	q.mu.Lock()
+	if q.queued[entry.Id] {
+		q.mu.Unlock()
+		return
+	}
+	q.queued[entry.Id] = true
+	lane := entry.Lane.index()
+	q.lanes[lane] = append(q.lanes[lane], queueItem{entry: entry, queuedAt: q.now()})
+	q.mu.Unlock()
+
+	q.notify()
+}
+
+// pop blocks until an entry is available or the context is done.
+func (q *queue) pop(ctx context.Context) (*Entry, bool) {
+	for {
+		if ctx.Err() != nil {
+			return nil, false
+		}
+
+		q.mu.Lock()
+		if lane, ok := q.nextLane(); ok {
+			entry := q.lanes[lane][0].entry
+			q.lanes[lane][0] = queueItem{}
+			q.lanes[lane] = q.lanes[lane][1:]
+			q.served[lane]++
+			delete(q.queued, entry.Id)
+			remaining := len(q.queued)
+			q.mu.Unlock()
+
+			if remaining > 0 {
+				// wake up the next waiting worker
+				q.notify()
+			}
+
+			return entry, true
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-q.signal:
+		}
+	}
+}
+
+// nextLane returns the lane the next entry should be taken from. Must be called with the mutex held.
+func (q *queue) nextLane() (int, bool) {
+	transactional := TransactionalLane.index()
+	promotional := PromotionalLane.index()
+
+	hasTransactional := len(q.lanes[transactional]) > 0
+	hasPromotional := len(q.lanes[promotional]) > 0
+
+	switch {
+	case q.paused || !hasTransactional && !hasPromotional:
+		return 0, false
+	case !hasPromotional:
+		return transactional, true
+	case !hasTransactional:
+		return promotional, true
+	}
+
+	if q.maxPromotionalWait > 0 && q.now().Sub(q.lanes[promotional][0].queuedAt) >= q.maxPromotionalWait {
+		return promotional, true
+	}
+
+	if q.served[transactional] >= q.transactionalWeight && q.served[promotional] >= q.promotionalWeight {
+		q.served = [laneCount]int{}
+	}
+
+	if q.served[transactional] < q.transactionalWeight {
+		return transactional, true
+	}
+
+	return promotional, true
+}
+
+// setPaused stops or resumes handing out entries, queued entries are kept.
+func (q *queue) setPaused(paused bool) {
+	q.mu.Lock()
+	q.paused = paused
+	q.mu.Unlock()
+
+	if !paused {
+		q.notify()
+	}
+}
+
+func (q *queue) isPaused() bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.paused
+}
+
+func (q *queue) clear() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.lanes = [laneCount][]queueItem{}
+	q.queued = make(map[string]bool)
+	q.served = [laneCount]int{}
+}
+
+func (q *queue) notify() {
+	select {
+	case q.signal <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,74 @@
+package outbox
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Store persists outbox entries.
+type Store interface {
+	// Save inserts the entry or replaces the previously saved entry with the same id.
+	Save(entry *Entry) error
+	// Get returns the entry with the given id.
+	Get(id string) (*Entry, error)
+	// Unfinished returns all entries which are not sent or failed yet, ordered by creation time.
+	Unfinished() ([]*Entry, error)
+}
+
+// MemoryStore is a Store which keeps entries in memory.
+// It does not survive process restarts and is mostly useful for tests.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*Entry
+}
+
+// NewMemoryStore creates new in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*Entry)}
+}
+
+// Save implements Store interface.
+func (s *MemoryStore) Save(entry *Entry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[entry.Id] = entry.clone()
+	return nil
+}
+
+// Get implements Store interface.
+func (s *MemoryStore) Get(id string) (*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return nil, fmt.Errorf("outbox entry '%s' was not found", id)
+	}
+
+	return entry.clone(), nil
+}
+
+// Unfinished implements Store interface.
+func (s *MemoryStore) Unfinished() ([]*Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return unfinished(s.entries), nil
+}
+
+func unfinished(entries map[string]*Entry) []*Entry {
+	var result []*Entry
+	for _, entry := range entries {
+		if !entry.IsFinal() {
+			result = append(result, entry.clone())
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+
+	return result
+}
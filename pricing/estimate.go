@@ -0,0 +1,245 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/country"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/money"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	viberplussms "github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+var (
+	// ErrNoChannel is returned when no allowed channel has a price for the notification.
+	ErrNoChannel = errors.New("no priced channel satisfies the constraints")
+	// ErrMixedCurrencies is returned when the allowed channels are priced in different currencies, so their costs can't be compared.
+	ErrMixedCurrencies = errors.New("channels are priced in different currencies")
+	// ErrNoPrice is returned when the price table has no price for the channel and the country.
+	ErrNoPrice = errors.New("no price")
+)
+
+// Estimate represents a cost of the message.
+type Estimate struct {
+	Channel   messaging.Channel `json:"channel"`    // Channel of the message.
+	Country   string            `json:"country"`    // Country of the receiver (empty if not known).
+	Segments  int               `json:"segments"`   // Segments is a number of SMS segments (zero for Viber messages).
+	Amount    money.Money       `json:"amount"`     // Amount is an expected cost of the message.
+	MaxAmount money.Money       `json:"max_amount"` // MaxAmount is a cost of the message if the SMS fallback is sent.
+}
+
+// Estimator estimates costs of messages by the price table.
+type Estimator struct {
+	// FallbackRate is an expected share of Viber plus SMS messages sent as SMS (from 0 to 1, zero by default).
+	FallbackRate float64
+
+	table *Table
+}
+
+// NewEstimator creates new Estimator with the price table.
+func NewEstimator(table *Table) *Estimator {
+	return &Estimator{table: table}
+}
+
+// EstimateSms returns the cost of the SMS message of the type, which is the segment price multiplied by the number of segments.
+func (e *Estimator) EstimateSms(message *sms.Message, messageType MessageType) (Estimate, error) {
+	code, _ := country.FromPhone(message.ReceiverPhone)
+	return e.estimateSms(code, messageType, message.Text)
+}
+
+// EstimateViber returns the cost of the Viber message.
+func (e *Estimator) EstimateViber(message *viber.Message) (Estimate, error) {
+	code, _ := country.FromPhone(message.Receiver)
+	price, err := e.lookup(code, messaging.Viber, messageTypeOf(message.SourceType))
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	return Estimate{Channel: messaging.Viber, Country: code, Amount: price.Amount, MaxAmount: price.Amount}, nil
+}
+
+// EstimateViberSms returns the cost of the Viber plus SMS message. The expected amount includes the SMS message
+// cost multiplied by FallbackRate, the maximum amount includes the whole SMS message cost.
+// Promotional messages are never sent as SMS, so they cost the Viber price only.
+func (e *Estimator) EstimateViberSms(message *viberplussms.Message) (Estimate, error) {
+	code, _ := country.FromPhone(message.Receiver)
+	messageType := messageTypeOf(message.SourceType)
+	price, err := e.lookup(code, messaging.ViberSms, messageType)
+	if err != nil {
+		return Estimate{}, err
+	}
+	if messageType == Promotional {
+		return Estimate{Channel: messaging.ViberSms, Country: code, Amount: price.Amount, MaxAmount: price.Amount}, nil
+	}
+
+	smsEstimate, err := e.estimateSms(code, messageType, message.SmsText)
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	maxAmount, err := price.Amount.Add(smsEstimate.Amount)
+	if err != nil {
+		return Estimate{}, err
+	}
+	fallbackAmount, err := smsEstimate.Amount.MulFloat(e.FallbackRate)
+	if err != nil {
+		return Estimate{}, err
+	}
+	amount, err := price.Amount.Add(fallbackAmount)
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	return Estimate{Channel: messaging.ViberSms, Country: code, Segments: smsEstimate.Segments, Amount: amount, MaxAmount: maxAmount}, nil
+}
+
+// Estimate returns the cost of the notification sent through the channel.
+func (e *Estimator) Estimate(channel messaging.Channel, recipient messaging.Recipient, notification *messaging.Notification) (Estimate, error) {
+	switch channel {
+	case messaging.Sms:
+		messageType := Promotional
+		if notification.Transactional {
+			messageType = Transactional
+		}
+		return e.EstimateSms(messaging.NewSmsMessage(recipient, notification), messageType)
+	case messaging.Viber:
+		return e.EstimateViber(messaging.NewViberMessage(recipient, notification))
+	case messaging.ViberSms:
+		return e.EstimateViberSms(messaging.NewViberSmsMessage(recipient, notification))
+	default:
+		return Estimate{}, fmt.Errorf("unknown channel %q", channel)
+	}
+}
+
+func (e *Estimator) estimateSms(code string, messageType MessageType, text string) (Estimate, error) {
+	price, err := e.lookup(code, messaging.Sms, messageType)
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	segments, _ := sms.Segments(text)
+	amount, err := price.Amount.Mul(int64(segments))
+	if err != nil {
+		return Estimate{}, err
+	}
+
+	return Estimate{Channel: messaging.Sms, Country: code, Segments: segments, Amount: amount, MaxAmount: amount}, nil
+}
+
+func (e *Estimator) lookup(code string, channel messaging.Channel, messageType MessageType) (Price, error) {
+	price, ok := e.table.Lookup(code, channel, messageType)
+	if !ok {
+		return Price{}, fmt.Errorf("%w: %s for country %q", ErrNoPrice, channel, code)
+	}
+
+	return price, nil
+}
+
+func messageTypeOf(sourceType viber.MessageSourceType) MessageType {
+	if sourceType == viber.Transactional {
+		return Transactional
+	}
+
+	return Promotional
+}
+
+// Strategy selects the cheapest channel of the notification which satisfies the delivery constraints.
+// All the channels must be priced in the same currency, otherwise ErrMixedCurrencies is returned.
+type Strategy struct {
+	// Channels are the allowed channels in the order of preference for equal costs (Viber, Viber plus SMS and SMS by default).
+	Channels []messaging.Channel
+	// RequireSms excludes channels without the SMS message, so receivers without Viber get the notification as well.
+	// Promotional Viber plus SMS messages are excluded too, as the SMS fallback is sent for transactional ones only.
+	RequireSms bool
+	// WorstCase compares maximum amounts instead of expected ones.
+	WorstCase bool
+	// MaxSegments excludes SMS and Viber plus SMS messages longer than the number of segments (no limit if zero).
+	MaxSegments int
+
+	estimator *Estimator
+}
+
+// NewStrategy creates new Strategy with the estimator.
+func NewStrategy(estimator *Estimator) *Strategy {
+	return &Strategy{estimator: estimator}
+}
+
+// Select returns the estimate of the cheapest channel of the notification.
+func (s *Strategy) Select(recipient messaging.Recipient, notification *messaging.Notification) (Estimate, error) {
+	estimates, err := s.Estimates(recipient, notification)
+	if err != nil {
+		return Estimate{}, err
+	}
+	if len(estimates) == 0 {
+		return Estimate{}, ErrNoChannel
+	}
+
+	return estimates[0], nil
+}
+
+// Estimates returns the estimates of the channels which satisfy the constraints, the cheapest first.
+// Channels without a price are skipped, ErrMixedCurrencies is returned if the rest are priced in different currencies.
+// Any other estimation error (e.g. money.ErrOverflow) is returned as is.
+func (s *Strategy) Estimates(recipient messaging.Recipient, notification *messaging.Notification) ([]Estimate, error) {
+	var estimates []Estimate
+	for _, channel := range s.channels() {
+		if s.RequireSms && !hasSms(channel, notification) {
+			continue
+		}
+
+		estimate, err := s.estimator.Estimate(channel, recipient, notification)
+		if errors.As(err, &money.CurrencyMismatchError{}) {
+			return nil, ErrMixedCurrencies
+		}
+		if errors.Is(err, ErrNoPrice) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if s.MaxSegments > 0 && estimate.Segments > s.MaxSegments {
+			continue
+		}
+
+		if len(estimates) > 0 && estimate.Amount.Currency() != estimates[0].Amount.Currency() {
+			return nil, ErrMixedCurrencies
+		}
+
+		estimates = append(estimates, estimate)
+	}
+
+	sort.SliceStable(estimates, func(i, j int) bool {
+		return s.cost(estimates[i]).Cmp(s.cost(estimates[j])) < 0
+	})
+	return estimates, nil
+}
+
+func (s *Strategy) cost(estimate Estimate) money.Money {
+	if s.WorstCase {
+		return estimate.MaxAmount
+	}
+
+	return estimate.Amount
+}
+
+func hasSms(channel messaging.Channel, notification *messaging.Notification) bool {
+	switch channel {
+	case messaging.Sms:
+		return true
+	case messaging.ViberSms:
+		return notification.Transactional
+	default:
+		return false
+	}
+}
+
+func (s *Strategy) channels() []messaging.Channel {
+	if len(s.Channels) == 0 {
+		return []messaging.Channel{messaging.Viber, messaging.ViberSms, messaging.Sms}
+	}
+
+	return s.Channels
+}
@@ -0,0 +1,192 @@
+package pricing_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/money"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/pricing"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+)
+
+const priceTable = `[
+	{"country": "", "channel": "sms", "amount": 0.05, "currency": "EUR"},
+	{"country": "UA", "channel": "sms", "amount": 0.02, "currency": "EUR"},
+	{"country": "UA", "channel": "viber", "message_type": "transactional", "amount": 0.015, "currency": "EUR"},
+	{"country": "UA", "channel": "viber", "message_type": "promotional", "amount": 0.03, "currency": "EUR"},
+	{"country": "UA", "channel": "viber_sms", "amount": 0.016, "currency": "EUR"},
+	{"country": "PL", "channel": "viber", "amount": 0.08, "currency": "EUR"}
+]`
+
+func readTable(t *testing.T) *pricing.Table {
+	table, err := pricing.ReadTable(strings.NewReader(priceTable))
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error: %v", err)
+	}
+
+	return table
+}
+
+func TestLookupPrice(t *testing.T) {
+	table := readTable(t)
+
+	var inputData = []struct {
+		name           string
+		country        string
+		channel        messaging.Channel
+		messageType    pricing.MessageType
+		expectedAmount string
+		expectedOk     bool
+	}{
+		{"country price", "UA", messaging.Sms, pricing.Transactional, "0.02", true},
+		{"default price", "DE", messaging.Sms, pricing.Promotional, "0.05", true},
+		{"message type price", "UA", messaging.Viber, pricing.Promotional, "0.03", true},
+		{"any type price", "PL", messaging.Viber, pricing.Transactional, "0.08", true},
+		{"no price", "DE", messaging.Viber, pricing.Transactional, "0", false},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			price, ok := table.Lookup(input.country, input.channel, input.messageType)
+			if ok != input.expectedOk || price.Amount.Amount() != input.expectedAmount {
+				t.Errorf("FAIL. Expected amount %s (%t), but got %s (%t)", input.expectedAmount, input.expectedOk, price.Amount, ok)
+			}
+		})
+	}
+
+	if len(table.Prices()) != 6 {
+		t.Errorf("FAIL. Expected 6 prices, but got %d", len(table.Prices()))
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	estimator := pricing.NewEstimator(readTable(t))
+	estimator.FallbackRate = 0.5
+	recipient := messaging.Recipient{Phone: "380671234567"}
+	longText := strings.Repeat("a", 200)
+
+	var inputData = []struct {
+		name              string
+		channel           messaging.Channel
+		notification      messaging.Notification
+		expectedSegments  int
+		expectedAmount    string
+		expectedMaxAmount string
+	}{
+		{"single segment SMS", messaging.Sms, messaging.Notification{Text: "Hello"}, 1, "0.02", "0.02"},
+		{"two segments SMS", messaging.Sms, messaging.Notification{Text: longText}, 2, "0.04", "0.04"},
+		{"UCS-2 SMS", messaging.Sms, messaging.Notification{Text: strings.Repeat("ї", 71)}, 2, "0.04", "0.04"},
+		{"transactional Viber", messaging.Viber, messaging.Notification{Text: longText, Transactional: true}, 0, "0.015", "0.015"},
+		{"promotional Viber", messaging.Viber, messaging.Notification{Text: longText}, 0, "0.03", "0.03"},
+		{"Viber plus SMS", messaging.ViberSms, messaging.Notification{Text: longText, Transactional: true}, 2, "0.036", "0.056"},
+		{"promotional Viber plus SMS", messaging.ViberSms, messaging.Notification{Text: longText}, 0, "0.016", "0.016"},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			estimate, err := estimator.Estimate(input.channel, recipient, &input.notification)
+			if err != nil {
+				t.Fatalf("FAIL. Unexpected error: %v", err)
+			}
+			if estimate.Segments != input.expectedSegments || estimate.Country != "UA" || estimate.Amount.Currency() != "EUR" {
+				t.Errorf("FAIL. Unexpected estimate '%+v'", estimate)
+			}
+			if estimate.Amount.Amount() != input.expectedAmount || estimate.MaxAmount.Amount() != input.expectedMaxAmount {
+				t.Errorf("FAIL. Expected amounts %s and %s, but got %s and %s", input.expectedAmount, input.expectedMaxAmount, estimate.Amount, estimate.MaxAmount)
+			}
+		})
+	}
+
+	estimate, err := estimator.EstimateSms(sms.NewMessage("4915112345678", "", "Hello", true), pricing.Promotional)
+	if err != nil || estimate.Amount != money.MustParse("0.05", "EUR") {
+		t.Errorf("FAIL. Expected the default SMS price, but got '%+v' (%v)", estimate, err)
+	}
+}
+
+func TestSelectChannel(t *testing.T) {
+	strategy := pricing.NewStrategy(pricing.NewEstimator(readTable(t)))
+	shortText := messaging.Notification{Text: "Your code is 1234", Transactional: true}
+	longText := messaging.Notification{Text: strings.Repeat("a", 400), Transactional: true}
+	promotional := messaging.Notification{Text: "Spring sale"}
+
+	var inputData = []struct {
+		name            string
+		phone           string
+		notification    messaging.Notification
+		requireSms      bool
+		worstCase       bool
+		maxSegments     int
+		expectedChannel messaging.Channel
+		expectedError   error
+	}{
+		{"cheapest Viber", "380671234567", shortText, false, false, 0, messaging.Viber, nil},
+		{"SMS required", "380671234567", shortText, true, false, 0, messaging.ViberSms, nil},
+		{"promotional with SMS required", "380671234567", promotional, true, false, 0, messaging.Sms, nil},
+		{"worst case", "380671234567", shortText, true, true, 0, messaging.Sms, nil},
+		{"segments limit", "380671234567", longText, true, false, 2, "", pricing.ErrNoChannel},
+		{"only SMS priced", "4915112345678", shortText, false, false, 0, messaging.Sms, nil},
+		{"SMS cheaper than Viber", "48601234567", shortText, false, false, 0, messaging.Sms, nil},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			strategy.RequireSms = input.requireSms
+			strategy.WorstCase = input.worstCase
+			strategy.MaxSegments = input.maxSegments
+
+			estimate, err := strategy.Select(messaging.Recipient{Phone: input.phone}, &input.notification)
+			if err != input.expectedError {
+				t.Fatalf("FAIL. Expected error '%v', but got '%v'", input.expectedError, err)
+			}
+			if estimate.Channel != input.expectedChannel {
+				t.Errorf("FAIL. Expected channel '%s', but got '%s'", input.expectedChannel, estimate.Channel)
+			}
+		})
+	}
+}
+
+func TestSelectChannelErrors(t *testing.T) {
+	var inputData = []struct {
+		name          string
+		table         string
+		expectedError error
+	}{
+		{"mixed currencies of channels", `[{"country": "UA", "channel": "sms", "amount": 0.02, "currency": "EUR"}, {"country": "UA", "channel": "viber", "amount": 0.5, "currency": "UAH"}]`, pricing.ErrMixedCurrencies},
+		{"mixed currencies of Viber plus SMS", `[{"country": "UA", "channel": "sms", "amount": 0.02, "currency": "EUR"}, {"country": "UA", "channel": "viber_sms", "amount": 0.5, "currency": "UAH"}]`, pricing.ErrMixedCurrencies},
+		{"overflow", `[{"country": "UA", "channel": "sms", "amount": 9000000000, "currency": "EUR"}, {"country": "UA", "channel": "viber", "amount": 0.5, "currency": "EUR"}]`, money.ErrOverflow},
+		{"no price", `[{"country": "PL", "channel": "sms", "amount": 0.02, "currency": "EUR"}]`, pricing.ErrNoChannel},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			table, err := pricing.ReadTable(strings.NewReader(input.table))
+			if err != nil {
+				t.Fatalf("FAIL. Unexpected error: %v", err)
+			}
+
+			strategy := pricing.NewStrategy(pricing.NewEstimator(table))
+			notification := messaging.Notification{Text: strings.Repeat("a", 400), Transactional: true}
+			_, err = strategy.Select(messaging.Recipient{Phone: "380671234567"}, &notification)
+			if !errors.Is(err, input.expectedError) {
+				t.Errorf("FAIL. Expected error '%v', but got '%v'", input.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestPriceJson(t *testing.T) {
+	price := pricing.Price{Country: "UA", Channel: messaging.Sms, Amount: money.MustParse("0.0215", "EUR")}
+	data, err := json.Marshal(price)
+	expected := `{"country":"UA","channel":"sms","message_type":"","amount":"0.0215","currency":"EUR"}`
+	if err != nil || string(data) != expected {
+		t.Errorf("FAIL. Expected json '%s', but got '%s' (%v)", expected, data, err)
+	}
+
+	var actual pricing.Price
+	if err := json.Unmarshal(data, &actual); err != nil || actual != price {
+		t.Errorf("FAIL. Expected price '%+v', but got '%+v' (%v)", price, actual, err)
+	}
+}
@@ -0,0 +1,149 @@
+// Package pricing contains a price table of the channels, a cost estimator of messages
+// and a strategy which selects the cheapest channel of the notification.
+package pricing
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/money"
+)
+
+// MessageType represents a type of traffic the message is priced by.
+type MessageType string
+
+const (
+	AnyType       MessageType = ""              // AnyType matches both transactional and promotional messages.
+	Transactional MessageType = "transactional" // Transactional is a type of transactional messages.
+	Promotional   MessageType = "promotional"   // Promotional is a type of promotional messages.
+)
+
+// Price represents a price of a single message (of a single segment for SMS messages).
+// For Viber plus SMS messages it is the price of the Viber message, the SMS message is priced by the Sms channel.
+type Price struct {
+	Country     string            `json:"country"`      // Country is an ISO 3166-1 alpha-2 code (empty for the default price).
+	Channel     messaging.Channel `json:"channel"`      // Channel the price applies to.
+	MessageType MessageType       `json:"message_type"` // MessageType the price applies to (empty for any).
+	Amount      money.Money       `json:"amount"`       // Amount is a price of the message or of the SMS segment with its currency.
+}
+
+type priceJson struct {
+	Country     string            `json:"country"`
+	Channel     messaging.Channel `json:"channel"`
+	MessageType MessageType       `json:"message_type"`
+	Amount      json.RawMessage   `json:"amount"`
+	Currency    string            `json:"currency"`
+}
+
+// MarshalJSON implements json.Marshaler interface. The amount is written as a string next to the currency.
+func (p Price) MarshalJSON() ([]byte, error) {
+	amount, err := json.Marshal(p.Amount.Amount())
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(priceJson{Country: p.Country, Channel: p.Channel, MessageType: p.MessageType, Amount: amount, Currency: p.Amount.Currency()})
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface. The amount may be a string or a number,
+// it is parsed exactly in the currency of the price.
+func (p *Price) UnmarshalJSON(data []byte) error {
+	var value priceJson
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	amount := money.Zero(value.Currency)
+	if len(value.Amount) > 0 {
+		if err := amount.UnmarshalJSON(value.Amount); err != nil {
+			return err
+		}
+	}
+
+	*p = Price{Country: value.Country, Channel: value.Channel, MessageType: value.MessageType, Amount: amount}
+	return nil
+}
+
+type priceKey struct {
+	country     string
+	channel     messaging.Channel
+	messageType MessageType
+}
+
+// Table contains prices by country, channel and message type.
+type Table struct {
+	mu     sync.RWMutex
+	prices map[priceKey]Price
+}
+
+// NewTable creates new Table with the prices.
+func NewTable(prices ...Price) *Table {
+	table := &Table{prices: make(map[priceKey]Price)}
+	for _, price := range prices {
+		table.Set(price)
+	}
+
+	return table
+}
+
+// ReadTable reads the table from a JSON array of prices.
+func ReadTable(r io.Reader) (*Table, error) {
+	var prices []Price
+	if err := json.NewDecoder(r).Decode(&prices); err != nil {
+		return nil, fmt.Errorf("read price table: %w", err)
+	}
+
+	return NewTable(prices...), nil
+}
+
+// Set sets the price. The price with the same country, channel and message type is replaced.
+func (t *Table) Set(price Price) *Table {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prices[priceKey{price.Country, price.Channel, price.MessageType}] = price
+	return t
+}
+
+// Lookup returns the price of the message. The price of the country is preferred to the default one,
+// and the price of the message type is preferred to the price of any type.
+func (t *Table) Lookup(country string, channel messaging.Channel, messageType MessageType) (Price, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, c := range []string{country, ""} {
+		for _, m := range []MessageType{messageType, AnyType} {
+			if price, ok := t.prices[priceKey{c, channel, m}]; ok {
+				return price, true
+			}
+		}
+	}
+
+	return Price{}, false
+}
+
+// Prices returns all the prices of the table ordered by country, channel and message type.
+func (t *Table) Prices() []Price {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	prices := make([]Price, 0, len(t.prices))
+	for _, price := range t.prices {
+		prices = append(prices, price)
+	}
+	sort.Slice(prices, func(i, j int) bool {
+		if prices[i].Country != prices[j].Country {
+			return prices[i].Country < prices[j].Country
+		}
+		if prices[i].Channel != prices[j].Channel {
+			return prices[i].Channel < prices[j].Channel
+		}
+		return prices[i].MessageType < prices[j].MessageType
+	})
+
+	return prices
+}
@@ -0,0 +1,207 @@
+// Package reconcile contains a reconciler which merges message statuses received from
+// callbacks and status requests into a single monotonic status per message.
+package reconcile
+
+import (
+	"sync"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	viberplussms "github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+// Source represents where the status was observed.
+type Source string
+
+const (
+	Callback Source = "callback" // Callback means the status was received by the callback handler.
+	Polling  Source = "polling"  // Polling means the status was returned by GetMessageStatus.
+)
+
+// Key identifies the reconciled message.
+type Key struct {
+	Channel   messaging.Channel `json:"channel"`
+	MessageId int64             `json:"message_id"`
+}
+
+// Observation represents a message status observed by a callback or a status request.
+type Observation struct {
+	Key
+	Status messaging.DeliveryStatus `json:"status"` // Status is the observed status.
+	Source Source                   `json:"source"` // Source is where the status was observed.
+	At     time.Time                `json:"at"`     // At is a time when the status was observed.
+}
+
+// Transition represents an applied status change of the message.
+type Transition struct {
+	From   messaging.DeliveryStatus `json:"from"`   // From is a previous status.
+	To     messaging.DeliveryStatus `json:"to"`     // To is a new status.
+	Source Source                   `json:"source"` // Source is where the new status was observed.
+	At     time.Time                `json:"at"`     // At is a time when the new status was observed.
+}
+
+// Record represents the reconciled status of the message with its transition history.
+type Record struct {
+	Key
+	Status    messaging.DeliveryStatus `json:"status"`     // Status is the current reconciled status.
+	UpdatedAt time.Time                `json:"updated_at"` // UpdatedAt is a time of the last applied transition.
+	History   []Transition             `json:"history"`    // History contains all applied transitions in order.
+	Ignored   int                      `json:"ignored"`    // Ignored is a number of ignored duplicate or stale observations.
+}
+
+// Reconciler merges status observations per message. Observations which repeat the current status
+// or would move the message back (like Sent after Delivered, or Pending after Sent) are ignored,
+// and once a terminal status is reached it never changes.
+type Reconciler struct {
+	OnTransition func(record Record, transition Transition) // OnTransition is called after every applied transition.
+
+	mu      sync.Mutex
+	records map[Key]*Record
+}
+
+// New creates new reconciler.
+func New() *Reconciler {
+	return &Reconciler{records: make(map[Key]*Record)}
+}
+
+// Observe merges the observation and returns the applied transition, or false if the observation was ignored.
+func (r *Reconciler) Observe(observation Observation) (Transition, bool) {
+	if observation.At.IsZero() {
+		observation.At = time.Now()
+	}
+
+	r.mu.Lock()
+	record, ok := r.records[observation.Key]
+	if !ok {
+		record = &Record{Key: observation.Key}
+		r.records[observation.Key] = record
+	}
+
+	if !Advances(record.Status, observation.Status) {
+		record.Ignored++
+		r.mu.Unlock()
+		return Transition{}, false
+	}
+
+	transition := Transition{From: record.Status, To: observation.Status, Source: observation.Source, At: observation.At}
+	record.Status = observation.Status
+	record.UpdatedAt = observation.At
+	record.History = append(record.History, transition)
+	snapshot := record.copy()
+	r.mu.Unlock()
+
+	if r.OnTransition != nil {
+		r.OnTransition(snapshot, transition)
+	}
+
+	return transition, true
+}
+
+// ObserveSmsStatus merges the SMS message status returned by sms.Client.GetMessageStatus.
+func (r *Reconciler) ObserveSmsStatus(messageId int64, status sms.MessageStatus) (Transition, bool) {
+	return r.Observe(Observation{
+		Key:    Key{Channel: messaging.Sms, MessageId: messageId},
+		Status: messaging.FromSmsStatus(status),
+		Source: Polling,
+	})
+}
+
+// ObserveViberReceipt merges the Viber message receipt returned by viber.Client.GetMessageStatus.
+func (r *Reconciler) ObserveViberReceipt(receipt *viber.MessageReceipt) (Transition, bool) {
+	return r.Observe(Observation{
+		Key:    Key{Channel: messaging.Viber, MessageId: receipt.MessageId},
+		Status: messaging.FromViberStatus(receipt.Status),
+		Source: Polling,
+	})
+}
+
+// ObserveViberSmsReceipt merges the Viber plus SMS message receipt returned by viber/sms.Client.GetMessageStatus.
+func (r *Reconciler) ObserveViberSmsReceipt(receipt *viberplussms.MessageReceipt) (Transition, bool) {
+	return r.Observe(Observation{
+		Key:    Key{Channel: messaging.ViberSms, MessageId: receipt.MessageId},
+		Status: messaging.FromViberSmsReceipt(receipt),
+		Source: Polling,
+	})
+}
+
+// ObserveViberCallback merges the Viber message status callback.
+func (r *Reconciler) ObserveViberCallback(event *viber.CallbackEvent) (Transition, bool) {
+	return r.Observe(Observation{
+		Key:    Key{Channel: messaging.Viber, MessageId: event.MessageId},
+		Status: messaging.FromViberStatus(event.Status),
+		Source: Callback,
+		At:     event.ReceivedAt,
+	})
+}
+
+// ObserveViberSmsCallback merges the Viber plus SMS message status callback.
+func (r *Reconciler) ObserveViberSmsCallback(event *viberplussms.CallbackEvent) (Transition, bool) {
+	receipt := &viberplussms.MessageReceipt{
+		MessageId:        event.MessageId,
+		Status:           event.Status,
+		SmsMessageId:     event.SmsMessageId,
+		SmsMessageStatus: event.SmsMessageStatus,
+	}
+
+	return r.Observe(Observation{
+		Key:    Key{Channel: messaging.ViberSms, MessageId: event.MessageId},
+		Status: messaging.FromViberSmsReceipt(receipt),
+		Source: Callback,
+		At:     event.ReceivedAt,
+	})
+}
+
+// Get returns the reconciled record of the message.
+func (r *Reconciler) Get(channel messaging.Channel, messageId int64) (Record, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	record, ok := r.records[Key{Channel: channel, MessageId: messageId}]
+	if !ok {
+		return Record{}, false
+	}
+
+	return record.copy(), true
+}
+
+// Forget removes the record of the message, e.g. once its final status is saved elsewhere.
+func (r *Reconciler) Forget(channel messaging.Channel, messageId int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.records, Key{Channel: channel, MessageId: messageId})
+}
+
+// Advances returns true if the message may move from the current status to the next one:
+// terminal statuses never change and other statuses only move forward
+// (Unknown, Accepted, Pending, Sent, then a terminal status).
+func Advances(current messaging.DeliveryStatus, next messaging.DeliveryStatus) bool {
+	if current.IsTerminal() {
+		return false
+	}
+
+	return rank(next) > rank(current)
+}
+
+func rank(status messaging.DeliveryStatus) int {
+	switch {
+	case status.IsTerminal():
+		return 4
+	case status == messaging.Sent:
+		return 3
+	case status == messaging.Pending:
+		return 2
+	case status == messaging.Accepted:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func (r *Record) copy() Record {
+	c := *r
+	c.History = append([]Transition(nil), r.History...)
+	return c
+}
@@ -0,0 +1,94 @@
+package reconcile_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/reconcile"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	viberplussms "github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+func TestReconcileObservations(t *testing.T) {
+	start := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	key := reconcile.Key{Channel: messaging.Viber, MessageId: 429}
+
+	var inputData = []struct {
+		name            string
+		observations    []messaging.DeliveryStatus
+		expectedStatus  messaging.DeliveryStatus
+		expectedHistory []messaging.DeliveryStatus
+	}{
+		{"in order", []messaging.DeliveryStatus{messaging.Pending, messaging.Sent, messaging.Delivered}, messaging.Delivered, []messaging.DeliveryStatus{messaging.Pending, messaging.Sent, messaging.Delivered}},
+		{"duplicates", []messaging.DeliveryStatus{messaging.Sent, messaging.Sent, messaging.Delivered, messaging.Delivered}, messaging.Delivered, []messaging.DeliveryStatus{messaging.Sent, messaging.Delivered}},
+		{"out of order", []messaging.DeliveryStatus{messaging.Delivered, messaging.Sent, messaging.Pending}, messaging.Delivered, []messaging.DeliveryStatus{messaging.Delivered}},
+		{"skipped statuses", []messaging.DeliveryStatus{messaging.Sent, messaging.Pending, messaging.Undelivered}, messaging.Undelivered, []messaging.DeliveryStatus{messaging.Sent, messaging.Undelivered}},
+		{"terminal is final", []messaging.DeliveryStatus{messaging.Rejected, messaging.Delivered}, messaging.Rejected, []messaging.DeliveryStatus{messaging.Rejected}},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			var transitions []reconcile.Transition
+			reconciler := reconcile.New()
+			reconciler.OnTransition = func(record reconcile.Record, transition reconcile.Transition) {
+				transitions = append(transitions, transition)
+			}
+
+			for i, status := range input.observations {
+				source := reconcile.Polling
+				if i%2 == 1 {
+					source = reconcile.Callback
+				}
+				reconciler.Observe(reconcile.Observation{Key: key, Status: status, Source: source, At: start.Add(time.Duration(i) * time.Minute)})
+			}
+
+			record, ok := reconciler.Get(key.Channel, key.MessageId)
+			if !ok {
+				t.Fatalf("FAIL. Expected record to exist")
+			}
+
+			if record.Status != input.expectedStatus {
+				t.Errorf("FAIL. Expected status '%s', but got '%s'", input.expectedStatus, record.Status)
+			}
+
+			if len(record.History) != len(input.expectedHistory) || len(transitions) != len(input.expectedHistory) {
+				t.Fatalf("FAIL. Expected history '%v', but got '%+v'", input.expectedHistory, record.History)
+			}
+
+			previous := messaging.Unknown
+			for i, transition := range record.History {
+				if transition.From != previous || transition.To != input.expectedHistory[i] || transition.At.IsZero() {
+					t.Errorf("FAIL. Unexpected transition '%+v' at position %d", transition, i)
+				}
+				previous = transition.To
+			}
+
+			if record.Ignored != len(input.observations)-len(input.expectedHistory) {
+				t.Errorf("FAIL. Expected %d ignored observations, but got %d", len(input.observations)-len(input.expectedHistory), record.Ignored)
+			}
+		})
+	}
+}
+
+func TestReconcileCallbacksAndPolling(t *testing.T) {
+	reconciler := reconcile.New()
+
+	// the callback arrives first, the status request made earlier returns later
+	reconciler.ObserveViberSmsCallback(&viberplussms.CallbackEvent{
+		MessageId: 429, Status: viber.Undelivered, SmsMessageId: 22, SmsMessageStatus: viberplussms.SmsDelivered,
+	})
+	if _, applied := reconciler.ObserveViberSmsReceipt(&viberplussms.MessageReceipt{MessageId: 429, Status: viber.Undelivered, SmsMessageId: 22}); applied {
+		t.Errorf("FAIL. Expected stale receipt to be ignored")
+	}
+
+	record, _ := reconciler.Get(messaging.ViberSms, 429)
+	if record.Status != messaging.Delivered || len(record.History) != 1 || record.History[0].Source != reconcile.Callback {
+		t.Errorf("FAIL. Expected message delivered by callback, but got '%+v'", record)
+	}
+
+	// the same message id in another channel is a different message
+	if _, ok := reconciler.Get(messaging.Viber, 429); ok {
+		t.Errorf("FAIL. Expected no record for Viber channel")
+	}
+}
@@ -0,0 +1,323 @@
+// Package router selects one of several provider accounts for every message, so brands (tenants)
+// with their own SMS logins and Viber API keys can be served by a single application.
+package router
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/country"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	viberplussms "github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+// ErrNoAccount is returned when no rule matches the message.
+var ErrNoAccount = errors.New("no account matches the message")
+
+// ErrLimitExceeded is returned when all the accounts matching the message reached their limits.
+var ErrLimitExceeded = errors.New("account limit exceeded")
+
+// SmsSender sends SMS messages. It is implemented by sms.Client.
+type SmsSender interface {
+	SendMessage(message *sms.Message) (int64, error)
+}
+
+// ViberSender sends Viber messages. It is implemented by viber.Client.
+type ViberSender interface {
+	SendMessage(message *viber.Message) (int64, error)
+}
+
+// ViberSmsSender sends Viber plus SMS messages. It is implemented by viber/sms.Client.
+type ViberSmsSender interface {
+	SendMessage(message *viberplussms.Message) (int64, error)
+}
+
+// Limit represents a maximum number of messages sent through the account per period.
+// Messages the provider returned an error for are not counted.
+type Limit struct {
+	Messages int           // Messages is a maximum number of messages per period (no limit if zero).
+	Period   time.Duration // Period is a length of the period (1 second by default).
+}
+
+// Account represents provider credentials of a single brand. Channels without a client are not served by the account.
+type Account struct {
+	Name     string         // Name of the account, referenced by rules.
+	Sms      SmsSender      // Sms sends SMS messages of the account.
+	Viber    ViberSender    // Viber sends Viber messages of the account.
+	ViberSms ViberSmsSender // ViberSms sends Viber plus SMS messages of the account.
+	Limit    Limit          // Limit of messages sent through the account.
+}
+
+// Supports returns true if the account has a client of the channel.
+func (a *Account) Supports(channel messaging.Channel) bool {
+	switch channel {
+	case messaging.Sms:
+		return a.Sms != nil
+	case messaging.Viber:
+		return a.Viber != nil
+	case messaging.ViberSms:
+		return a.ViberSms != nil
+	default:
+		return false
+	}
+}
+
+// Route represents properties of the message the account is selected by.
+type Route struct {
+	Tenant  string            // Tenant is an id of the brand the message is sent on behalf of.
+	Sender  string            // Sender is a message sender.
+	Phone   string            // Phone is a receiver phone number, the destination country is derived from it.
+	Channel messaging.Channel // Channel is a type of the message.
+}
+
+// Rule selects the account for messages matching all its non-empty fields.
+type Rule struct {
+	Tenant  string            // Tenant matches the route tenant.
+	Sender  string            // Sender matches the message sender.
+	Country string            // Country matches the ISO 3166-1 alpha-2 code of the destination country.
+	Channel messaging.Channel // Channel matches the message type.
+	Account string            // Account is a name of the selected account.
+}
+
+// Matches returns true if the route matches the rule.
+func (r Rule) Matches(route Route) bool {
+	if r.Tenant != "" && r.Tenant != route.Tenant {
+		return false
+	}
+	if r.Sender != "" && r.Sender != route.Sender {
+		return false
+	}
+	if r.Country != "" {
+		if code, _ := country.FromPhone(route.Phone); code != r.Country {
+			return false
+		}
+	}
+
+	return r.Channel == "" || r.Channel == route.Channel
+}
+
+// Metrics represents usage of the account.
+type Metrics struct {
+	Sent       int64     `json:"sent"`         // Sent is a number of messages accepted by the provider.
+	Failed     int64     `json:"failed"`       // Failed is a number of messages the provider returned an error for.
+	Limited    int64     `json:"limited"`      // Limited is a number of messages not sent through the account because of its limit.
+	LastSentAt time.Time `json:"last_sent_at"` // LastSentAt is a time of the last accepted message.
+}
+
+// Router sends messages through the account selected by the first matching rule.
+// If the account reached its limit, the next matching rule is tried.
+type Router struct {
+	Now func() time.Time // Now returns current time (time.Now by default).
+
+	mu       sync.Mutex
+	accounts map[string]*account
+	rules    []Rule
+}
+
+type account struct {
+	*Account
+	metrics     Metrics
+	windowStart time.Time
+	windowCount int
+}
+
+// New creates new Router.
+func New() *Router {
+	return &Router{accounts: make(map[string]*account)}
+}
+
+// AddAccount adds the account. The account with the same name is replaced.
+func (r *Router) AddAccount(a *Account) *Router {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.accounts[a.Name] = &account{Account: a}
+	return r
+}
+
+// AddRule adds the rule. Rules are checked in the order they were added.
+func (r *Router) AddRule(rule Rule) *Router {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rules = append(r.rules, rule)
+	return r
+}
+
+// Select returns the account the message of the route is sent through. The message is counted against the account limit.
+func (r *Router) Select(route Route) (*Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, err := r.selectAccount(route)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.Account, nil
+}
+
+// SendSms sends the SMS message through the selected account and returns the message id and the account name.
+func (r *Router) SendSms(tenant string, message *sms.Message) (int64, string, error) {
+	route := Route{Tenant: tenant, Sender: message.Sender, Phone: message.ReceiverPhone, Channel: messaging.Sms}
+	return r.send(route, func(a *Account) (int64, error) {
+		return a.Sms.SendMessage(message)
+	})
+}
+
+// SendViber sends the Viber message through the selected account and returns the message id and the account name.
+func (r *Router) SendViber(tenant string, message *viber.Message) (int64, string, error) {
+	route := Route{Tenant: tenant, Sender: message.Sender, Phone: message.Receiver, Channel: messaging.Viber}
+	return r.send(route, func(a *Account) (int64, error) {
+		return a.Viber.SendMessage(message)
+	})
+}
+
+// SendViberSms sends the Viber plus SMS message through the selected account and returns the message id and the account name.
+func (r *Router) SendViberSms(tenant string, message *viberplussms.Message) (int64, string, error) {
+	route := Route{Tenant: tenant, Sender: message.Sender, Phone: message.Receiver, Channel: messaging.ViberSms}
+	return r.send(route, func(a *Account) (int64, error) {
+		return a.ViberSms.SendMessage(message)
+	})
+}
+
+// Account returns the account by its name.
+func (r *Router) Account(name string) (*Account, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.accounts[name]
+	if !ok {
+		return nil, false
+	}
+
+	return a.Account, true
+}
+
+// Metrics returns usage of the account by its name.
+func (r *Router) Metrics(name string) (Metrics, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	a, ok := r.accounts[name]
+	if !ok {
+		return Metrics{}, false
+	}
+
+	return a.metrics, true
+}
+
+// AllMetrics returns usage of all the accounts by their names.
+func (r *Router) AllMetrics() map[string]Metrics {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	metrics := make(map[string]Metrics, len(r.accounts))
+	for name, a := range r.accounts {
+		metrics[name] = a.metrics
+	}
+
+	return metrics
+}
+
+func (r *Router) send(route Route, send func(a *Account) (int64, error)) (int64, string, error) {
+	r.mu.Lock()
+	a, err := r.selectAccount(route)
+	var window time.Time
+	if a != nil {
+		window = a.windowStart
+	}
+	r.mu.Unlock()
+	if err != nil {
+		return -1, "", err
+	}
+
+	messageId, err := send(a.Account)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		a.metrics.Failed++
+		a.release(window)
+		return messageId, a.Name, err
+	}
+
+	a.metrics.Sent++
+	a.metrics.LastSentAt = r.now()
+	return messageId, a.Name, nil
+}
+
+// selectAccount must be called with mu held.
+func (r *Router) selectAccount(route Route) (*account, error) {
+	limited := false
+	for _, rule := range r.rules {
+		if !rule.Matches(route) {
+			continue
+		}
+
+		a, ok := r.accounts[rule.Account]
+		if !ok {
+			return nil, fmt.Errorf("rule refers to unknown account %q", rule.Account)
+		}
+		if !a.Supports(route.Channel) {
+			continue
+		}
+		if !a.reserve(r.now()) {
+			a.metrics.Limited++
+			limited = true
+			continue
+		}
+
+		return a, nil
+	}
+
+	if limited {
+		return nil, ErrLimitExceeded
+	}
+
+	return nil, ErrNoAccount
+}
+
+// reserve counts the message against the account limit and returns false if the limit is reached.
+func (a *account) reserve(now time.Time) bool {
+	if a.Limit.Messages <= 0 {
+		return true
+	}
+
+	period := a.Limit.Period
+	if period <= 0 {
+		period = time.Second
+	}
+	if now.Sub(a.windowStart) >= period {
+		a.windowStart = now
+		a.windowCount = 0
+	}
+	if a.windowCount >= a.Limit.Messages {
+		return false
+	}
+
+	a.windowCount++
+	return true
+}
+
+// release returns the message reserved in the window back to the account limit, so failed messages are not counted.
+// Nothing is returned if the window has already been reset.
+func (a *account) release(window time.Time) {
+	if a.Limit.Messages <= 0 || !a.windowStart.Equal(window) || a.windowCount == 0 {
+		return
+	}
+
+	a.windowCount--
+}
+
+func (r *Router) now() time.Time {
+	if r.Now == nil {
+		return time.Now()
+	}
+
+	return r.Now()
+}
@@ -0,0 +1,168 @@
+package router_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/router"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+)
+
+type fakeSmsClient struct {
+	messageId int64
+	err       error
+	sent      int
+}
+
+func (c *fakeSmsClient) SendMessage(message *sms.Message) (int64, error) {
+	c.sent++
+	return c.messageId, c.err
+}
+
+type fakeViberClient struct {
+	messageId int64
+	sent      int
+}
+
+func (c *fakeViberClient) SendMessage(message *viber.Message) (int64, error) {
+	c.sent++
+	return c.messageId, nil
+}
+
+func TestSelectAccount(t *testing.T) {
+	r := router.New().
+		AddAccount(&router.Account{Name: "brand-a", Sms: &fakeSmsClient{}, Viber: &fakeViberClient{}}).
+		AddAccount(&router.Account{Name: "brand-b", Sms: &fakeSmsClient{}}).
+		AddAccount(&router.Account{Name: "poland", Sms: &fakeSmsClient{}}).
+		AddAccount(&router.Account{Name: "default", Sms: &fakeSmsClient{}, Viber: &fakeViberClient{}}).
+		AddRule(router.Rule{Tenant: "a", Account: "brand-a"}).
+		AddRule(router.Rule{Tenant: "b", Country: "PL", Account: "poland"}).
+		AddRule(router.Rule{Tenant: "b", Account: "brand-b"}).
+		AddRule(router.Rule{Sender: "Promo", Channel: messaging.Sms, Account: "brand-b"}).
+		AddRule(router.Rule{Channel: messaging.Viber, Account: "default"})
+
+	var inputData = []struct {
+		name            string
+		route           router.Route
+		expectedAccount string
+		expectedError   error
+	}{
+		{"tenant", router.Route{Tenant: "a", Phone: "380671234567", Channel: messaging.Sms}, "brand-a", nil},
+		{"tenant and country", router.Route{Tenant: "b", Phone: "48601234567", Channel: messaging.Sms}, "poland", nil},
+		{"tenant other country", router.Route{Tenant: "b", Phone: "380671234567", Channel: messaging.Sms}, "brand-b", nil},
+		{"channel not supported", router.Route{Tenant: "b", Phone: "380671234567", Channel: messaging.Viber}, "default", nil},
+		{"sender", router.Route{Sender: "Promo", Phone: "380671234567", Channel: messaging.Sms}, "brand-b", nil},
+		{"no rule", router.Route{Tenant: "c", Phone: "380671234567", Channel: messaging.Sms}, "", router.ErrNoAccount},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			account, err := r.Select(input.route)
+			if err != input.expectedError {
+				t.Fatalf("FAIL. Expected error '%v', but got '%v'", input.expectedError, err)
+			}
+			if err == nil && account.Name != input.expectedAccount {
+				t.Errorf("FAIL. Expected account '%s', but got '%s'", input.expectedAccount, account.Name)
+			}
+		})
+	}
+}
+
+func TestSendWithLimits(t *testing.T) {
+	now := time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)
+	primary := &fakeSmsClient{messageId: 1}
+	overflow := &fakeSmsClient{messageId: 2}
+
+	r := router.New().
+		AddAccount(&router.Account{Name: "primary", Sms: primary, Limit: router.Limit{Messages: 2, Period: time.Minute}}).
+		AddAccount(&router.Account{Name: "overflow", Sms: overflow, Limit: router.Limit{Messages: 1, Period: time.Minute}}).
+		AddRule(router.Rule{Tenant: "a", Account: "primary"}).
+		AddRule(router.Rule{Tenant: "a", Account: "overflow"})
+	r.Now = func() time.Time { return now }
+
+	message := sms.NewMessage("380671234567", "Brand", "Hello", true)
+	expectedAccounts := []string{"primary", "primary", "overflow"}
+	for i, expected := range expectedAccounts {
+		messageId, account, err := r.SendSms("a", message)
+		if err != nil {
+			t.Fatalf("FAIL. Unexpected error of message %d: %v", i, err)
+		}
+		if account != expected || messageId <= 0 {
+			t.Errorf("FAIL. Expected message %d to be sent through '%s', but got '%s' (%d)", i, expected, account, messageId)
+		}
+	}
+
+	if _, _, err := r.SendSms("a", message); err != router.ErrLimitExceeded {
+		t.Errorf("FAIL. Expected error '%v', but got '%v'", router.ErrLimitExceeded, err)
+	}
+
+	now = now.Add(time.Minute)
+	if _, account, err := r.SendSms("a", message); err != nil || account != "primary" {
+		t.Errorf("FAIL. Expected the limit to be reset, but got '%s' (%v)", account, err)
+	}
+
+	metrics, _ := r.Metrics("primary")
+	if metrics.Sent != 3 || metrics.Limited != 2 || !metrics.LastSentAt.Equal(now) {
+		t.Errorf("FAIL. Unexpected primary metrics '%+v'", metrics)
+	}
+	metrics, _ = r.Metrics("overflow")
+	if metrics.Sent != 1 || metrics.Limited != 1 {
+		t.Errorf("FAIL. Unexpected overflow metrics '%+v'", metrics)
+	}
+	if primary.sent != 3 || overflow.sent != 1 {
+		t.Errorf("FAIL. Expected 3 and 1 messages sent, but got %d and %d", primary.sent, overflow.sent)
+	}
+}
+
+func TestSendFailure(t *testing.T) {
+	client := &fakeSmsClient{messageId: -1, err: sms.Error{Code: sms.NotEnoughMoney}}
+	r := router.New().
+		AddAccount(&router.Account{Name: "brand", Sms: client}).
+		AddRule(router.Rule{Account: "brand"})
+
+	_, account, err := r.SendSms("", sms.NewMessage("380671234567", "Brand", "Hello", true))
+	var smsErr sms.Error
+	if !errors.As(err, &smsErr) || account != "brand" {
+		t.Errorf("FAIL. Expected SMS error from 'brand', but got '%v' from '%s'", err, account)
+	}
+
+	metrics := r.AllMetrics()["brand"]
+	if metrics.Failed != 1 || metrics.Sent != 0 {
+		t.Errorf("FAIL. Unexpected metrics '%+v'", metrics)
+	}
+}
+
+func TestSendFailureReleasesLimit(t *testing.T) {
+	client := &fakeSmsClient{messageId: -1, err: sms.Error{Code: sms.NotEnoughMoney}}
+	r := router.New().
+		AddAccount(&router.Account{Name: "brand", Sms: client, Limit: router.Limit{Messages: 1, Period: time.Minute}}).
+		AddRule(router.Rule{Account: "brand"})
+	r.Now = func() time.Time { return time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC) }
+
+	message := sms.NewMessage("380671234567", "Brand", "Hello", true)
+	if _, _, err := r.SendSms("", message); err == nil {
+		t.Fatalf("FAIL. Expected SMS error")
+	}
+
+	client.err = nil
+	client.messageId = 1
+	if _, account, err := r.SendSms("", message); err != nil || account != "brand" {
+		t.Errorf("FAIL. Expected the failed message not to be counted against the limit, but got '%s' (%v)", account, err)
+	}
+
+	metrics := r.AllMetrics()["brand"]
+	if metrics.Failed != 1 || metrics.Sent != 1 || metrics.Limited != 0 {
+		t.Errorf("FAIL. Unexpected metrics '%+v'", metrics)
+	}
+}
+
+func TestUnknownAccount(t *testing.T) {
+	r := router.New().AddRule(router.Rule{Account: "missing"})
+
+	if _, err := r.Select(router.Route{Channel: messaging.Sms}); err == nil {
+		t.Errorf("FAIL. Expected error of the unknown account")
+	}
+}
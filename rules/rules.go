@@ -0,0 +1,330 @@
+// Package rules rewrites or rejects messages before sending by the rules of the destination country,
+// like numeric senders, forced transliteration, validity limits, allowed channels and quiet hours.
+package rules
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/country"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	viberplussms "github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+// QuietHours represents a daily period when promotional messages are not sent.
+type QuietHours struct {
+	Start    string `json:"start"`     // Start is a time of day in the "15:04" format.
+	End      string `json:"end"`       // End is a time of day in the "15:04" format, the period may span midnight.
+	TimeZone string `json:"time_zone"` // TimeZone is an IANA time zone name (UTC by default).
+
+	start    time.Duration
+	end      time.Duration
+	location *time.Location
+}
+
+// Rule represents sending rules of the country.
+type Rule struct {
+	Country string `json:"country"` // Country is an ISO 3166-1 alpha-2 code (empty for the default rule).
+
+	// NumericSender requires phone number senders: alphanumeric senders are replaced with SenderReplacement
+	// or the message is rejected if it is empty.
+	NumericSender bool `json:"numeric_sender"`
+	// SenderReplacement is a sender used instead of alphanumeric ones. If NumericSender is false, it replaces every sender.
+	SenderReplacement string `json:"sender_replacement"`
+	// Transliterate replaces Cyrillic letters of SMS texts with Latin ones.
+	Transliterate bool `json:"transliterate"`
+	// MinValidityPeriod and MaxValidityPeriod limit the validity period of Viber messages in seconds (no limit if zero).
+	// Messages with the provider default validity get MaxValidityPeriod.
+	MinValidityPeriod int `json:"min_validity_period"`
+	MaxValidityPeriod int `json:"max_validity_period"`
+	// Channels are the allowed channels (all by default).
+	Channels []messaging.Channel `json:"channels"`
+	// QuietHours is a period when promotional messages are rejected (none by default).
+	QuietHours *QuietHours `json:"quiet_hours"`
+}
+
+// RejectedError is returned when the message is not allowed to be sent.
+type RejectedError struct {
+	Country string // Country of the receiver.
+	Reason  string // Reason of the rejection.
+}
+
+// Error implements error interface.
+func (e RejectedError) Error() string {
+	country := e.Country
+	if country == "" {
+		country = "unknown country"
+	}
+
+	return fmt.Sprintf("message to %s rejected: %s", country, e.Reason)
+}
+
+// Engine applies the rule of the receiver country to messages. The default rule is applied
+// to countries without their own rule, messages are sent as is if there is no rule at all.
+type Engine struct {
+	Now func() time.Time // Now returns current time (time.Now by default).
+
+	mu    sync.RWMutex
+	rules map[string]Rule
+}
+
+// New creates new Engine without rules.
+func New() *Engine {
+	return &Engine{rules: make(map[string]Rule)}
+}
+
+// ReadRules creates new Engine with the rules read from a JSON array.
+func ReadRules(r io.Reader) (*Engine, error) {
+	var rules []Rule
+	if err := json.NewDecoder(r).Decode(&rules); err != nil {
+		return nil, fmt.Errorf("read rules: %w", err)
+	}
+
+	engine := New()
+	for _, rule := range rules {
+		if err := engine.AddRule(rule); err != nil {
+			return nil, err
+		}
+	}
+
+	return engine, nil
+}
+
+// LoadRules creates new Engine with the rules read from the JSON file.
+func LoadRules(path string) (*Engine, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ReadRules(file)
+}
+
+// AddRule adds the rule. The rule of the same country is replaced.
+func (e *Engine) AddRule(rule Rule) error {
+	if rule.QuietHours != nil {
+		quietHours := *rule.QuietHours
+		if err := quietHours.parse(); err != nil {
+			return fmt.Errorf("rule of %q: %w", rule.Country, err)
+		}
+		rule.QuietHours = &quietHours
+	}
+	if rule.MaxValidityPeriod > 0 && rule.MinValidityPeriod > rule.MaxValidityPeriod {
+		return fmt.Errorf("rule of %q: min validity period exceeds max validity period", rule.Country)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.rules[strings.ToUpper(rule.Country)] = rule
+	return nil
+}
+
+// Rule returns the rule applied to the receiver phone number.
+func (e *Engine) Rule(phone string) (Rule, bool) {
+	code, _ := country.FromPhone(phone)
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if rule, ok := e.rules[code]; ok {
+		return rule, true
+	}
+	rule, ok := e.rules[""]
+	return rule, ok
+}
+
+// Allows returns true if the channel is allowed for the receiver phone number.
+func (e *Engine) Allows(phone string, channel messaging.Channel) bool {
+	rule, ok := e.Rule(phone)
+	return !ok || rule.allows(channel)
+}
+
+// ApplySms rewrites the SMS message or returns RejectedError. Promotional messages are rejected during quiet hours.
+func (e *Engine) ApplySms(message *sms.Message, promotional bool) error {
+	rule, ok := e.Rule(message.ReceiverPhone)
+	if !ok {
+		return nil
+	}
+
+	if err := e.check(rule, message.ReceiverPhone, messaging.Sms, promotional); err != nil {
+		return err
+	}
+
+	sender, err := rule.sender(message.Sender)
+	if err != nil {
+		return e.reject(message.ReceiverPhone, err.Error())
+	}
+	message.Sender = sender
+	if rule.Transliterate {
+		message.Text = sms.Transliterate(message.Text)
+	}
+
+	return nil
+}
+
+// ApplyViber rewrites the Viber message or returns RejectedError.
+func (e *Engine) ApplyViber(message *viber.Message) error {
+	return e.applyViber(message, messaging.Viber)
+}
+
+// ApplyViberSms rewrites the Viber plus SMS message or returns RejectedError.
+// The SMS text is transliterated, the Viber text is kept as is.
+func (e *Engine) ApplyViberSms(message *viberplussms.Message) error {
+	if err := e.applyViber(&message.Message, messaging.ViberSms); err != nil {
+		return err
+	}
+
+	if rule, ok := e.Rule(message.Receiver); ok && rule.Transliterate {
+		message.SmsText = sms.Transliterate(message.SmsText)
+	}
+	return nil
+}
+
+func (e *Engine) applyViber(message *viber.Message, channel messaging.Channel) error {
+	rule, ok := e.Rule(message.Receiver)
+	if !ok {
+		return nil
+	}
+
+	if err := e.check(rule, message.Receiver, channel, message.SourceType == viber.Promotional); err != nil {
+		return err
+	}
+
+	sender, err := rule.sender(message.Sender)
+	if err != nil {
+		return e.reject(message.Receiver, err.Error())
+	}
+	message.Sender = sender
+
+	if rule.MaxValidityPeriod > 0 && (message.ValidityPeriod == 0 || message.ValidityPeriod > rule.MaxValidityPeriod) {
+		message.ValidityPeriod = rule.MaxValidityPeriod
+	}
+	if rule.MinValidityPeriod > 0 && message.ValidityPeriod != 0 && message.ValidityPeriod < rule.MinValidityPeriod {
+		message.ValidityPeriod = rule.MinValidityPeriod
+	}
+
+	return nil
+}
+
+func (e *Engine) check(rule Rule, phone string, channel messaging.Channel, promotional bool) error {
+	if !rule.allows(channel) {
+		return e.reject(phone, fmt.Sprintf("channel %s is not allowed", channel))
+	}
+	if promotional && rule.QuietHours != nil && rule.QuietHours.contains(e.now()) {
+		return e.reject(phone, "promotional messages are not allowed during quiet hours")
+	}
+
+	return nil
+}
+
+func (e *Engine) reject(phone string, reason string) error {
+	code, _ := country.FromPhone(phone)
+	return RejectedError{Country: code, Reason: reason}
+}
+
+func (e *Engine) now() time.Time {
+	if e.Now == nil {
+		return time.Now()
+	}
+
+	return e.Now()
+}
+
+func (r Rule) allows(channel messaging.Channel) bool {
+	if len(r.Channels) == 0 {
+		return true
+	}
+
+	for _, c := range r.Channels {
+		if c == channel {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r Rule) sender(sender string) (string, error) {
+	if !r.NumericSender {
+		if r.SenderReplacement != "" {
+			return r.SenderReplacement, nil
+		}
+		return sender, nil
+	}
+
+	if isNumeric(sender) {
+		return sender, nil
+	}
+	if r.SenderReplacement == "" {
+		return "", fmt.Errorf("alphanumeric sender %q is not allowed", sender)
+	}
+
+	return r.SenderReplacement, nil
+}
+
+func isNumeric(sender string) bool {
+	digits := strings.TrimPrefix(sender, "+")
+	if digits == "" {
+		return false
+	}
+
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (q *QuietHours) parse() error {
+	start, err := parseTimeOfDay(q.Start)
+	if err != nil {
+		return err
+	}
+	end, err := parseTimeOfDay(q.End)
+	if err != nil {
+		return err
+	}
+
+	location := time.UTC
+	if q.TimeZone != "" {
+		if location, err = time.LoadLocation(q.TimeZone); err != nil {
+			return err
+		}
+	}
+
+	q.start, q.end, q.location = start, end, location
+	return nil
+}
+
+// contains returns true if the time is within the quiet hours.
+func (q *QuietHours) contains(t time.Time) bool {
+	t = t.In(q.location)
+	timeOfDay := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+
+	if q.start <= q.end {
+		return timeOfDay >= q.start && timeOfDay < q.end
+	}
+
+	// the period spans midnight
+	return timeOfDay >= q.start || timeOfDay < q.end
+}
+
+func parseTimeOfDay(text string) (time.Duration, error) {
+	t, err := time.Parse("15:04", text)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", text)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
@@ -0,0 +1,155 @@
+package rules_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/rules"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	viberplussms "github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+const rulesConfig = `[
+	{"country": "", "max_validity_period": 86400},
+	{"country": "UA", "transliterate": true, "min_validity_period": 60,
+	 "quiet_hours": {"start": "21:00", "end": "09:00", "time_zone": "Europe/Kiev"}},
+	{"country": "US", "numeric_sender": true, "sender_replacement": "12025550100", "channels": ["sms"]},
+	{"country": "PL", "numeric_sender": true}
+]`
+
+func newEngine(t *testing.T, now time.Time) *rules.Engine {
+	engine, err := rules.ReadRules(strings.NewReader(rulesConfig))
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error: %v", err)
+	}
+	engine.Now = func() time.Time { return now }
+
+	return engine
+}
+
+func TestApplySms(t *testing.T) {
+	day := time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC)
+	night := time.Date(2022, 1, 1, 22, 0, 0, 0, time.UTC)
+
+	var inputData = []struct {
+		name           string
+		now            time.Time
+		phone          string
+		sender         string
+		promotional    bool
+		expectedSender string
+		expectedText   string
+		expectedReject bool
+	}{
+		{"transliterated", day, "380671234567", "Brand", false, "Brand", "Pryvit", false},
+		{"promotional at night", night, "380671234567", "Brand", true, "", "", true},
+		{"transactional at night", night, "380671234567", "Brand", false, "Brand", "Pryvit", false},
+		{"sender replaced", day, "12025550123", "Brand", true, "12025550100", "Привіт", false},
+		{"numeric sender kept", day, "12025550123", "+12025550199", false, "+12025550199", "Привіт", false},
+		{"alphanumeric sender rejected", day, "48601234567", "Brand", false, "", "", true},
+		{"default rule", day, "4915112345678", "Brand", true, "Brand", "Привіт", false},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			engine := newEngine(t, input.now)
+			message := sms.NewMessage(input.phone, input.sender, "Привіт", true)
+
+			err := engine.ApplySms(message, input.promotional)
+			var rejected rules.RejectedError
+			if errors.As(err, &rejected) != input.expectedReject {
+				t.Fatalf("FAIL. Expected rejection: %t, but got '%v'", input.expectedReject, err)
+			}
+			if input.expectedReject {
+				return
+			}
+
+			if message.Sender != input.expectedSender || message.Text != input.expectedText {
+				t.Errorf("FAIL. Expected sender '%s' and text '%s', but got '%s' and '%s'", input.expectedSender, input.expectedText, message.Sender, message.Text)
+			}
+		})
+	}
+}
+
+func TestApplyViber(t *testing.T) {
+	var inputData = []struct {
+		name             string
+		phone            string
+		validityPeriod   int
+		expectedValidity int
+		expectedReject   bool
+	}{
+		{"default validity limited", "4915112345678", 0, 86400, false},
+		{"long validity limited", "4915112345678", 100000, 86400, false},
+		{"short validity kept", "4915112345678", 3600, 3600, false},
+		{"min validity", "380671234567", 30, 60, false},
+		{"channel not allowed", "12025550123", 0, 0, true},
+	}
+
+	engine := newEngine(t, time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC))
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			message := viber.NewMessage().SetReceiver(input.phone).SetSender("Brand").
+				SetSourceType(viber.Promotional).SetValidityPeriod(input.validityPeriod)
+
+			err := engine.ApplyViber(message)
+			if (err != nil) != input.expectedReject {
+				t.Fatalf("FAIL. Expected rejection: %t, but got '%v'", input.expectedReject, err)
+			}
+			if err == nil && message.ValidityPeriod != input.expectedValidity {
+				t.Errorf("FAIL. Expected validity period %d, but got %d", input.expectedValidity, message.ValidityPeriod)
+			}
+		})
+	}
+
+	if engine.Allows("12025550123", messaging.Viber) || !engine.Allows("12025550123", messaging.Sms) {
+		t.Errorf("FAIL. Expected only SMS to be allowed for US")
+	}
+}
+
+func TestApplyViberSms(t *testing.T) {
+	engine := newEngine(t, time.Date(2022, 1, 1, 12, 0, 0, 0, time.UTC))
+	message := viberplussms.NewMessage()
+	message.SetReceiver("380671234567").SetSender("Brand").SetText("Привіт").SetSourceType(viber.Transactional)
+	message.SetSmsText("Привіт")
+
+	if err := engine.ApplyViberSms(message); err != nil {
+		t.Fatalf("FAIL. Unexpected error: %v", err)
+	}
+	if message.Text != "Привіт" || message.SmsText != "Pryvit" {
+		t.Errorf("FAIL. Expected only the SMS text to be transliterated, but got '%s' and '%s'", message.Text, message.SmsText)
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(rulesConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	engine, err := rules.LoadRules(path)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error: %v", err)
+	}
+	if rule, ok := engine.Rule("48601234567"); !ok || !rule.NumericSender {
+		t.Errorf("FAIL. Expected the rule of PL, but got '%+v'", rule)
+	}
+
+	var inputData = []string{
+		`[{"country": "UA", "quiet_hours": {"start": "25:00", "end": "09:00"}}]`,
+		`[{"country": "UA", "quiet_hours": {"start": "21:00", "end": "09:00", "time_zone": "Mars/Base"}}]`,
+		`[{"country": "UA", "min_validity_period": 100, "max_validity_period": 10}]`,
+		`{"country": "UA"}`,
+	}
+	for _, input := range inputData {
+		if _, err := rules.ReadRules(strings.NewReader(input)); err == nil {
+			t.Errorf("FAIL. Expected error of rules '%s'", input)
+		}
+	}
+}
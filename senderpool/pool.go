@@ -0,0 +1,306 @@
+// Package senderpool distributes messages across several registered senders (alphanumeric names or phone numbers).
+package senderpool
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/country"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+)
+
+// ErrEmpty is returned when the pool has no senders.
+var ErrEmpty = errors.New("sender pool is empty")
+
+const (
+	defaultStickyTimeout = 24 * time.Hour
+	sweepInterval        = 1000
+)
+
+// Strategy represents how senders are assigned to new recipients.
+type Strategy int
+
+const (
+	RoundRobin Strategy = iota // RoundRobin assigns senders in turn.
+	Weighted                   // Weighted assigns senders in proportion to their weights.
+)
+
+// Sender represents a sender of the pool.
+type Sender struct {
+	Name   string `json:"name"`   // Name is a message sender (alphanumeric name or phone number).
+	Weight int    `json:"weight"` // Weight is a share of recipients assigned to the sender by the Weighted strategy (1 by default).
+}
+
+// SmsSender sends SMS messages. It is implemented by sms.Client.
+type SmsSender interface {
+	SendMessage(message *sms.Message) (int64, error)
+}
+
+// ViberSender sends Viber messages. It is implemented by viber.Client.
+type ViberSender interface {
+	SendMessage(message *viber.Message) (int64, error)
+}
+
+// Pool assigns senders to messages. A recipient keeps its sender until StickyTimeout passes
+// since the last message or the sender is removed.
+type Pool struct {
+	StickyTimeout time.Duration                  // StickyTimeout is a time the recipient keeps its sender (24 hours by default).
+	IsSenderError func(error) bool               // IsSenderError returns true if the sender must be removed because of the error (IsIncorrectSenderError by default).
+	OnRemoved     func(sender string, err error) // OnRemoved is called after the sender was removed because of the error.
+	Now           func() time.Time               // Now returns current time (time.Now by default).
+
+	strategy Strategy
+	mu       sync.Mutex
+	senders  []*member
+	sticky   map[string]assignment
+	assigned int
+}
+
+type member struct {
+	Sender
+	current int
+}
+
+type assignment struct {
+	sender string
+	usedAt time.Time
+}
+
+// New creates new Pool with the strategy and the senders.
+func New(strategy Strategy, senders ...Sender) *Pool {
+	pool := &Pool{strategy: strategy, sticky: make(map[string]assignment)}
+	for _, sender := range senders {
+		pool.Add(sender)
+	}
+
+	return pool
+}
+
+// Add adds the sender. The sender with the same name is replaced.
+func (p *Pool) Add(sender Sender) *Pool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if sender.Weight <= 0 {
+		sender.Weight = 1
+	}
+	for _, m := range p.senders {
+		if m.Name == sender.Name {
+			m.Sender = sender
+			return p
+		}
+	}
+
+	p.senders = append(p.senders, &member{Sender: sender})
+	return p
+}
+
+// Remove removes the sender. Recipients of the sender get new senders. It returns false if the sender was not in the pool.
+func (p *Pool) Remove(name string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, m := range p.senders {
+		if m.Name == name {
+			p.senders = append(p.senders[:i], p.senders[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// Senders returns the senders of the pool.
+func (p *Pool) Senders() []Sender {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	senders := make([]Sender, len(p.senders))
+	for i, m := range p.senders {
+		senders[i] = m.Sender
+	}
+
+	return senders
+}
+
+// Next returns the sender of the recipient phone number.
+func (p *Pool) Next(recipient string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	key := country.Normalize(recipient)
+	if a, ok := p.sticky[key]; ok && now.Sub(a.usedAt) < p.stickyTimeout() && p.contains(a.sender) {
+		p.sticky[key] = assignment{sender: a.sender, usedAt: now}
+		return a.sender, nil
+	}
+
+	if len(p.senders) == 0 {
+		return "", ErrEmpty
+	}
+
+	sender := p.pick()
+	p.sticky[key] = assignment{sender: sender, usedAt: now}
+	p.assigned++
+	if p.assigned%sweepInterval == 0 {
+		p.sweep(now)
+	}
+
+	return sender, nil
+}
+
+// AssignSms sets the sender of the SMS message.
+func (p *Pool) AssignSms(message *sms.Message) error {
+	sender, err := p.Next(message.ReceiverPhone)
+	if err != nil {
+		return err
+	}
+
+	message.Sender = sender
+	return nil
+}
+
+// AssignViber sets the sender of the Viber message.
+func (p *Pool) AssignViber(message *viber.Message) error {
+	sender, err := p.Next(message.Receiver)
+	if err != nil {
+		return err
+	}
+
+	message.SetSender(sender)
+	return nil
+}
+
+// Report removes the sender if the error was caused by it and returns true if the sender was removed.
+func (p *Pool) Report(sender string, err error) bool {
+	if err == nil || !p.isSenderError(err) || !p.Remove(sender) {
+		return false
+	}
+
+	if p.OnRemoved != nil {
+		p.OnRemoved(sender, err)
+	}
+	return true
+}
+
+// SendSms assigns the sender to the SMS message and sends it. If the sender is rejected,
+// it is removed and the message is sent again with another sender.
+// If all the senders are rejected, the last error is returned.
+func (p *Pool) SendSms(client SmsSender, message *sms.Message) (int64, error) {
+	var senderErr error
+	for {
+		if err := p.AssignSms(message); err != nil {
+			if senderErr != nil {
+				// all the senders were rejected
+				return -1, senderErr
+			}
+			return -1, err
+		}
+
+		messageId, err := client.SendMessage(message)
+		if !p.Report(message.Sender, err) {
+			return messageId, err
+		}
+		senderErr = err
+	}
+}
+
+// SendViber assigns the sender to the Viber message and sends it. If the sender is rejected,
+// it is removed and the message is sent again with another sender.
+// If all the senders are rejected, the last error is returned.
+func (p *Pool) SendViber(client ViberSender, message *viber.Message) (int64, error) {
+	var senderErr error
+	for {
+		if err := p.AssignViber(message); err != nil {
+			if senderErr != nil {
+				// all the senders were rejected
+				return -1, senderErr
+			}
+			return -1, err
+		}
+
+		messageId, err := client.SendMessage(message)
+		if !p.Report(message.Sender, err) {
+			return messageId, err
+		}
+		senderErr = err
+	}
+}
+
+// IsIncorrectSenderError returns true if the error is the SMS IncorrectSender error
+// or the Viber error of the invalid source_addr parameter.
+func IsIncorrectSenderError(err error) bool {
+	var smsErr sms.Error
+	if errors.As(err, &smsErr) {
+		return smsErr.Code == sms.IncorrectSender
+	}
+
+	return viber.IsInvalidSenderError(err)
+}
+
+// pick selects the sender by the smooth weighted round-robin, all weights are equal for the RoundRobin strategy.
+// It must be called with mu held.
+func (p *Pool) pick() string {
+	total := 0
+	var best *member
+	for _, m := range p.senders {
+		weight := m.Weight
+		if p.strategy == RoundRobin {
+			weight = 1
+		}
+
+		m.current += weight
+		total += weight
+		if best == nil || m.current > best.current {
+			best = m
+		}
+	}
+
+	best.current -= total
+	return best.Name
+}
+
+func (p *Pool) contains(name string) bool {
+	for _, m := range p.senders {
+		if m.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sweep forgets expired assignments. It must be called with mu held.
+func (p *Pool) sweep(now time.Time) {
+	for key, a := range p.sticky {
+		if now.Sub(a.usedAt) >= p.stickyTimeout() {
+			delete(p.sticky, key)
+		}
+	}
+}
+
+func (p *Pool) isSenderError(err error) bool {
+	if p.IsSenderError == nil {
+		return IsIncorrectSenderError(err)
+	}
+
+	return p.IsSenderError(err)
+}
+
+func (p *Pool) stickyTimeout() time.Duration {
+	if p.StickyTimeout <= 0 {
+		return defaultStickyTimeout
+	}
+
+	return p.StickyTimeout
+}
+
+func (p *Pool) now() time.Time {
+	if p.Now == nil {
+		return time.Now()
+	}
+
+	return p.Now()
+}
@@ -0,0 +1,163 @@
+package senderpool_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/senderpool"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+)
+
+type fakeSmsClient struct {
+	rejected map[string]bool
+	sent     []string
+}
+
+func (c *fakeSmsClient) SendMessage(message *sms.Message) (int64, error) {
+	if c.rejected[message.Sender] {
+		return -1, sms.Error{Code: sms.IncorrectSender}
+	}
+
+	c.sent = append(c.sent, message.Sender)
+	return int64(len(c.sent)), nil
+}
+
+type fakeViberClient struct {
+	rejected map[string]bool
+	sent     []string
+}
+
+func (c *fakeViberClient) SendMessage(message *viber.Message) (int64, error) {
+	if c.rejected[message.Sender] {
+		return -1, viber.Error{Name: "Invalid Parameter: source_addr", Message: "Empty parameter or parameter validation error", Code: 1, Status: 400}
+	}
+
+	c.sent = append(c.sent, message.Sender)
+	return int64(len(c.sent)), nil
+}
+
+func TestDistribution(t *testing.T) {
+	var inputData = []struct {
+		name     string
+		strategy senderpool.Strategy
+		expected map[string]int
+	}{
+		{"round robin", senderpool.RoundRobin, map[string]int{"Alpha": 4, "Beta": 4, "Gamma": 4}},
+		{"weighted", senderpool.Weighted, map[string]int{"Alpha": 6, "Beta": 4, "Gamma": 2}},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			pool := senderpool.New(input.strategy,
+				senderpool.Sender{Name: "Alpha", Weight: 3},
+				senderpool.Sender{Name: "Beta", Weight: 2},
+				senderpool.Sender{Name: "Gamma"})
+
+			actual := make(map[string]int)
+			for i := 0; i < 12; i++ {
+				sender, err := pool.Next(fmt.Sprintf("3806700000%02d", i))
+				if err != nil {
+					t.Fatalf("FAIL. Unexpected error: %v", err)
+				}
+				actual[sender]++
+			}
+
+			for sender, count := range input.expected {
+				if actual[sender] != count {
+					t.Errorf("FAIL. Expected %d recipients of '%s', but got %d", count, sender, actual[sender])
+				}
+			}
+		})
+	}
+}
+
+func TestStickySender(t *testing.T) {
+	now := time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)
+	pool := senderpool.New(senderpool.RoundRobin, senderpool.Sender{Name: "Alpha"}, senderpool.Sender{Name: "Beta"})
+	pool.StickyTimeout = time.Hour
+	pool.Now = func() time.Time { return now }
+
+	first, _ := pool.Next("380671234567")
+	other, _ := pool.Next("380670000000")
+	if first == other {
+		t.Fatalf("FAIL. Expected different senders of new recipients, but got '%s' twice", first)
+	}
+
+	if sender, _ := pool.Next("+380 67 123 45 67"); sender != first {
+		t.Errorf("FAIL. Expected sticky sender '%s', but got '%s'", first, sender)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if sender, _ := pool.Next("380671234567"); sender != "Alpha" {
+		t.Errorf("FAIL. Expected the next sender 'Alpha' after the timeout, but got '%s'", sender)
+	}
+
+	pool.Remove("Alpha")
+	if sender, _ := pool.Next("380671234567"); sender != "Beta" {
+		t.Errorf("FAIL. Expected sender 'Beta' after 'Alpha' was removed, but got '%s'", sender)
+	}
+}
+
+func TestRemoveIncorrectSender(t *testing.T) {
+	client := &fakeSmsClient{rejected: map[string]bool{"Alpha": true}}
+	pool := senderpool.New(senderpool.RoundRobin, senderpool.Sender{Name: "Alpha"}, senderpool.Sender{Name: "Beta"})
+	var removed []string
+	pool.OnRemoved = func(sender string, err error) {
+		removed = append(removed, sender)
+	}
+
+	message := sms.NewMessage("380671234567", "", "Hello", true)
+	messageId, err := pool.SendSms(client, message)
+	if err != nil || messageId != 1 || message.Sender != "Beta" {
+		t.Fatalf("FAIL. Expected the message to be sent by 'Beta', but got '%s' (%d, %v)", message.Sender, messageId, err)
+	}
+	if len(removed) != 1 || removed[0] != "Alpha" || len(pool.Senders()) != 1 {
+		t.Errorf("FAIL. Expected 'Alpha' to be removed, but got %v", removed)
+	}
+
+	client.rejected["Beta"] = true
+	_, err = pool.SendSms(client, sms.NewMessage("380670000000", "", "Hello", true))
+	if !senderpool.IsIncorrectSenderError(err) {
+		t.Errorf("FAIL. Expected IncorrectSender error, but got '%v'", err)
+	}
+	if _, err := pool.Next("380670000000"); err != senderpool.ErrEmpty {
+		t.Errorf("FAIL. Expected error '%v', but got '%v'", senderpool.ErrEmpty, err)
+	}
+}
+
+func TestAssignViber(t *testing.T) {
+	pool := senderpool.New(senderpool.RoundRobin, senderpool.Sender{Name: "Alpha"})
+	message := viber.NewMessage().SetReceiver("380671234567")
+
+	if err := pool.AssignViber(message); err != nil || message.Sender != "Alpha" {
+		t.Errorf("FAIL. Expected sender 'Alpha', but got '%s' (%v)", message.Sender, err)
+	}
+	if pool.Report("Alpha", fmt.Errorf("timeout")) {
+		t.Errorf("FAIL. Expected the sender to be kept after an unrelated error")
+	}
+}
+
+func TestRemoveIncorrectViberSender(t *testing.T) {
+	client := &fakeViberClient{rejected: map[string]bool{"Alpha": true}}
+	pool := senderpool.New(senderpool.RoundRobin, senderpool.Sender{Name: "Alpha"}, senderpool.Sender{Name: "Beta"})
+
+	message := viber.NewMessage().SetReceiver("380671234567")
+	messageId, err := pool.SendViber(client, message)
+	if err != nil || messageId != 1 || message.Sender != "Beta" {
+		t.Fatalf("FAIL. Expected the message to be sent by 'Beta', but got '%s' (%d, %v)", message.Sender, messageId, err)
+	}
+	if senders := pool.Senders(); len(senders) != 1 || senders[0].Name != "Beta" {
+		t.Errorf("FAIL. Expected 'Alpha' to be removed, but got %v", senders)
+	}
+
+	client.rejected["Beta"] = true
+	_, err = pool.SendViber(client, viber.NewMessage().SetReceiver("380670000000"))
+	if !senderpool.IsIncorrectSenderError(err) {
+		t.Errorf("FAIL. Expected invalid sender error, but got '%v'", err)
+	}
+	if _, err := pool.Next("380670000000"); err != senderpool.ErrEmpty {
+		t.Errorf("FAIL. Expected error '%v', but got '%v'", senderpool.ErrEmpty, err)
+	}
+}
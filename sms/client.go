@@ -2,14 +2,17 @@
 package sms
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/internal/batch"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/money"
 )
 
 const baseUrl = "https://web.it-decision.com/ru/js"
@@ -34,9 +37,16 @@ func NewMessage(receiver string, sender string, text string, delivery bool) *Mes
 
 // Balance represents user money balance.
 type Balance struct {
-	BalanceAmount float64 `json:"balance"`
-	CreditAmount  float64 `json:"credit"`
-	Currency      string  `json:"currency"`
+	BalanceAmount money.Money `json:"balance"`  // BalanceAmount is an exact balance amount in Currency.
+	CreditAmount  money.Money `json:"credit"`   // CreditAmount is an exact credit amount in Currency.
+	Currency      string      `json:"currency"` // Currency is a currency of the account.
+}
+
+// Available returns the funds available for sending, which is the balance plus the credit.
+func (b *Balance) Available() money.Money {
+	// amounts of the same balance are in the same currency and within the supported range
+	available, _ := b.BalanceAmount.Add(b.CreditAmount)
+	return available
 }
 
 // ErrorCode represents an error code of the SMS operation.
@@ -100,6 +110,8 @@ func (s MessageStatus) String() string {
 		return "Unknown"
 	case Delivered:
 		return "Delivered"
+	case Expired:
+		return "Expired"
 	case Undeliverable:
 		return "Undeliverable"
 	case Accepted:
@@ -122,10 +134,18 @@ func (e Error) Error() string {
 
 type emptyValueFunction func() (int64, error)
 
+// MessageStatusResult holds the status of the particular SMS message or the error occurred while getting it.
+type MessageStatusResult struct {
+	Status MessageStatus
+	Err    error
+}
+
 // Client is used to work with SMS messages.
 type Client struct {
 	Login    string
 	Password string
+
+	batchOptions batch.Options
 }
 
 // NewClient creates new SMS client instance.
@@ -142,7 +162,7 @@ func (client *Client) SendMessage(message *Message) (int64, error) {
 	url := fmt.Sprintf("%s/send?login=%s&password=%s&phone=%s&sender=%s&text=%s&dlr=%d",
 		baseUrl, client.Login, client.Password, url.QueryEscape(message.ReceiverPhone), url.QueryEscape(message.Sender), url.QueryEscape(message.Text), dlr)
 
-	responseBody, err := makeHttpRequest(url)
+	responseBody, err := makeHttpRequest(context.Background(), url)
 	if err != nil {
 		return -1, err
 	}
@@ -152,9 +172,13 @@ func (client *Client) SendMessage(message *Message) (int64, error) {
 
 // GetMessageStatus returns SMS message delivery status.
 func (smsClient *Client) GetMessageStatus(messageId int64) (MessageStatus, error) {
+	return smsClient.getMessageStatus(context.Background(), messageId)
+}
+
+func (smsClient *Client) getMessageStatus(ctx context.Context, messageId int64) (MessageStatus, error) {
 	url := fmt.Sprintf("%s/state?login=%s&password=%s&msgid=%d", baseUrl, smsClient.Login, smsClient.Password, messageId)
 
-	responseBody, err := makeHttpRequest(url)
+	responseBody, err := makeHttpRequest(ctx, url)
 	if err != nil {
 		return -1, err
 	}
@@ -171,31 +195,62 @@ func (smsClient *Client) GetMessageStatus(messageId int64) (MessageStatus, error
 	return MessageStatus(status), nil
 }
 
+// SetBatchLimits sets the maximum number of simultaneous requests and the maximum
+// number of requests per second used by GetMessageStatuses. Zero requestsPerSecond means no rate limit.
+func (smsClient *Client) SetBatchLimits(concurrency int, requestsPerSecond float64) *Client {
+	smsClient.batchOptions.Concurrency = concurrency
+	smsClient.batchOptions.RequestsPerSecond = requestsPerSecond
+	return smsClient
+}
+
+// GetMessageStatuses returns delivery statuses of many SMS messages.
+// Requests are sent concurrently (see SetBatchLimits), duplicate ids are requested once.
+// Returned map contains a result for every requested id. If the context is done before
+// all statuses are received, remaining ids get the context error, which is returned as well.
+func (smsClient *Client) GetMessageStatuses(ctx context.Context, messageIds []int64) (map[int64]MessageStatusResult, error) {
+	results, err := batch.Run(ctx, messageIds, smsClient.batchOptions, func(ctx context.Context, id int64) (interface{}, error) {
+		return smsClient.getMessageStatus(ctx, id)
+	})
+
+	statuses := make(map[int64]MessageStatusResult, len(results))
+	for id, result := range results {
+		status, _ := result.Value.(MessageStatus)
+		statuses[id] = MessageStatusResult{Status: status, Err: result.Err}
+	}
+
+	return statuses, err
+}
+
 // GetBalance returns user balance information.
 func (smsClient *Client) GetBalance() (*Balance, error) {
 	url := fmt.Sprintf("%s/balance?login=%s&password=%s", baseUrl, smsClient.Login, smsClient.Password)
 
-	responseBody, err := makeHttpRequest(url)
+	responseBody, err := makeHttpRequest(context.Background(), url)
 	if err != nil {
 		return nil, err
 	}
 
-	// replace symbols in the response body so it's possible to parse it as json
-	// regexp removes quotation marks ("") around the numbers, so they could be parsed as float
-	regex := regexp.MustCompile(`"([-+]?[0-9]*\.?[0-9]+)"`)
-	relplacedContent := strings.ReplaceAll(strings.ReplaceAll(responseBody, "[", "{"), "]", "}")
-	relplacedContent = regex.ReplaceAllString(relplacedContent, "$1")
+	// replace brackets in the response body so it's possible to parse it as json,
+	// amounts are parsed exactly from their string values
+	replacedContent := strings.ReplaceAll(strings.ReplaceAll(responseBody, "[", "{"), "]", "}")
 
 	var balance Balance
-	if err := json.Unmarshal([]byte(relplacedContent), &balance); err != nil {
+	if err := json.Unmarshal([]byte(replacedContent), &balance); err != nil {
 		return nil, err
 	}
 
+	balance.BalanceAmount = balance.BalanceAmount.WithCurrency(balance.Currency)
+	balance.CreditAmount = balance.CreditAmount.WithCurrency(balance.Currency)
 	return &balance, nil
 }
 
-func makeHttpRequest(url string) (string, error) {
-	response, err := http.Get(url)
+func makeHttpRequest(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	response, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -1,8 +1,11 @@
 package sms_test
 
 import (
+	"context"
+	"net/http"
 	"testing"
 
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/money"
 	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
 	"github.com/jarcoal/httpmock"
 )
@@ -80,12 +83,12 @@ func TestGetBalance(t *testing.T) {
 	}{
 		{
 			`["balance":"-791.8391870","credit":"1000","currency":"EUR"]`,
-			&sms.Balance{BalanceAmount: -791.8391870, CreditAmount: 1000, Currency: "EUR"},
+			&sms.Balance{BalanceAmount: money.MustParse("-791.839187", "EUR"), CreditAmount: money.MustParse("1000", "EUR"), Currency: "EUR"},
 			nil,
 		},
 		{
 			`["balance":"348.879089","credit":"-5000.509409","currency":""]`,
-			&sms.Balance{BalanceAmount: 348.879089, CreditAmount: -5000.509409, Currency: ""},
+			&sms.Balance{BalanceAmount: money.MustParse("348.879089", ""), CreditAmount: money.MustParse("-5000.509409", ""), Currency: ""},
 			nil,
 		},
 		{`["error","45"]`, nil, sms.Error{sms.UserLocked}},
@@ -109,9 +112,75 @@ func TestGetBalance(t *testing.T) {
 			if balance != nil &&
 				(balance.BalanceAmount != input.expectedBalance.BalanceAmount ||
 					balance.CreditAmount != input.expectedBalance.CreditAmount ||
+					balance.Available() != input.expectedBalance.Available() ||
 					balance.Currency != input.expectedBalance.Currency) {
 				t.Errorf("FAIL. Expected balance '%+v', but got '%+v'", input.expectedBalance, balance)
 			}
 		})
 	}
 }
+
+func TestGetMessageStatuses(t *testing.T) {
+	var responses = map[string]string{
+		"1": `["status","2"]`,
+		"2": `["status","5"]`,
+		"3": `["error","42"]`,
+	}
+
+	var expectedResults = map[int64]sms.MessageStatusResult{
+		1: {Status: sms.Delivered},
+		2: {Status: sms.Undeliverable},
+		3: {Status: -1, Err: sms.Error{sms.InvalidMessageId}},
+	}
+
+	smsClient := sms.NewClient("", "").SetBatchLimits(2, 0)
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("GET", "https://web.it-decision.com/ru/js/state",
+		func(req *http.Request) (*http.Response, error) {
+			return httpmock.NewStringResponse(200, responses[req.URL.Query().Get("msgid")]), nil
+		})
+
+	results, err := smsClient.GetMessageStatuses(context.Background(), []int64{1, 2, 2, 3, 1})
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	if len(results) != len(expectedResults) {
+		t.Errorf("FAIL. Expected %d results, but got %d", len(expectedResults), len(results))
+	}
+
+	for id, expected := range expectedResults {
+		if results[id] != expected {
+			t.Errorf("FAIL. Expected result '%+v' for message %d, but got '%+v'", expected, id, results[id])
+		}
+	}
+
+	if calls := httpmock.GetTotalCallCount(); calls != 3 {
+		t.Errorf("FAIL. Expected 3 requests, but got %d", calls)
+	}
+}
+
+func TestMessageStatusString(t *testing.T) {
+	var inputData = []struct {
+		status       sms.MessageStatus
+		expectedName string
+	}{
+		{sms.Unknown, "Unknown"},
+		{sms.Delivered, "Delivered"},
+		{sms.Expired, "Expired"},
+		{sms.Undeliverable, "Undeliverable"},
+		{sms.Accepted, "Accepted"},
+		{sms.MessageStatus(1), "Invalid status"},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.expectedName, func(t *testing.T) {
+			if input.status.String() != input.expectedName {
+				t.Errorf("FAIL. Expected status name '%s', but got '%s'", input.expectedName, input.status.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package sms
+
+import "github.com/IT-DecisionTelecom/decisiontelecom-go/internal/enum"
+
+var messageStatusNames = enum.Names{
+	"Unknown":       int64(Unknown),
+	"Delivered":     int64(Delivered),
+	"Expired":       int64(Expired),
+	"Undeliverable": int64(Undeliverable),
+	"Accepted":      int64(Accepted),
+}
+
+var errorCodeNames = enum.Names{
+	"InvalidNumber":          int64(InvalidNumber),
+	"IncorrectSender":        int64(IncorrectSender),
+	"InvalidMessageId":       int64(InvalidMessageId),
+	"IncorrectJson":          int64(IncorrectJson),
+	"InvalidLoginOrPassword": int64(InvalidLoginOrPassword),
+	"UserLocked":             int64(UserLocked),
+	"EmptyText":              int64(EmptyText),
+	"EmptyLogin":             int64(EmptyLogin),
+	"EmptyPassword":          int64(EmptyPassword),
+	"NotEnoughMoney":         int64(NotEnoughMoney),
+	"AuthorizationError":     int64(AuthorizationError),
+	"InvalidPhoneNumber":     int64(InvalidPhoneNumber),
+}
+
+// ParseMessageStatus parses the message status from its name (like "Delivered") or numeric code (like "2").
+func ParseMessageStatus(text string) (MessageStatus, error) {
+	code, err := enum.Parse(text, "SMS message status", 0, false, messageStatusNames)
+	return MessageStatus(code), err
+}
+
+// MarshalText implements encoding.TextMarshaler interface.
+// Known statuses are encoded as names, unknown ones as numeric codes.
+func (s MessageStatus) MarshalText() ([]byte, error) {
+	return messageStatusNames.Text(int64(s)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler interface. It accepts names and numeric codes.
+func (s *MessageStatus) UnmarshalText(text []byte) error {
+	status, err := ParseMessageStatus(string(text))
+	if err != nil {
+		return err
+	}
+
+	*s = status
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface. It accepts names and numeric codes.
+func (s *MessageStatus) UnmarshalJSON(data []byte) error {
+	return enum.UnmarshalJSON(data, s.UnmarshalText)
+}
+
+// ParseErrorCode parses the error code from its name (like "InvalidNumber") or numeric code (like "40").
+func ParseErrorCode(text string) (ErrorCode, error) {
+	code, err := enum.Parse(text, "SMS error code", 0, false, errorCodeNames)
+	return ErrorCode(code), err
+}
+
+// MarshalText implements encoding.TextMarshaler interface.
+// Known error codes are encoded as names, unknown ones as numbers.
+func (code ErrorCode) MarshalText() ([]byte, error) {
+	return errorCodeNames.Text(int64(code)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler interface. It accepts names and numeric codes.
+func (code *ErrorCode) UnmarshalText(text []byte) error {
+	errorCode, err := ParseErrorCode(string(text))
+	if err != nil {
+		return err
+	}
+
+	*code = errorCode
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface. It accepts names and numeric codes.
+func (code *ErrorCode) UnmarshalJSON(data []byte) error {
+	return enum.UnmarshalJSON(data, code.UnmarshalText)
+}
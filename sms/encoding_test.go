@@ -0,0 +1,83 @@
+package sms_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+)
+
+func TestParseMessageStatus(t *testing.T) {
+	var inputData = []struct {
+		text           string
+		expectedStatus sms.MessageStatus
+		expectedError  bool
+	}{
+		{"Delivered", sms.Delivered, false},
+		{"expired", sms.Expired, false},
+		{"5", sms.Undeliverable, false},
+		{"42", sms.MessageStatus(42), false},
+		{"Lost", 0, true},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.text, func(t *testing.T) {
+			status, err := sms.ParseMessageStatus(input.text)
+			if (err != nil) != input.expectedError {
+				t.Errorf("FAIL. Expected error: %t, but got '%v'", input.expectedError, err)
+			}
+
+			if err == nil && status != input.expectedStatus {
+				t.Errorf("FAIL. Expected status '%d', but got '%d'", input.expectedStatus, status)
+			}
+		})
+	}
+}
+
+func TestEnumsJsonRoundTrip(t *testing.T) {
+	type record struct {
+		Status sms.MessageStatus `json:"status"`
+		Code   sms.ErrorCode     `json:"code"`
+	}
+
+	var inputData = []struct {
+		record       record
+		expectedJson string
+	}{
+		{record{sms.Delivered, sms.NotEnoughMoney}, `{"status":"Delivered","code":"NotEnoughMoney"}`},
+		{record{sms.Expired, sms.InvalidPhoneNumber}, `{"status":"Expired","code":"InvalidPhoneNumber"}`},
+		{record{sms.MessageStatus(9), sms.ErrorCode(99)}, `{"status":"9","code":"99"}`},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.expectedJson, func(t *testing.T) {
+			content, err := json.Marshal(input.record)
+			if err != nil {
+				t.Fatalf("FAIL. Unexpected error '%v'", err)
+			}
+
+			if string(content) != input.expectedJson {
+				t.Errorf("FAIL. Expected JSON '%s', but got '%s'", input.expectedJson, content)
+			}
+
+			var decoded record
+			if err := json.Unmarshal(content, &decoded); err != nil {
+				t.Fatalf("FAIL. Unexpected error '%v'", err)
+			}
+
+			if decoded != input.record {
+				t.Errorf("FAIL. Expected record '%+v', but got '%+v'", input.record, decoded)
+			}
+		})
+	}
+
+	// numeric codes written before names were introduced are still accepted
+	var decoded record
+	if err := json.Unmarshal([]byte(`{"status":2,"code":44}`), &decoded); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	if decoded.Status != sms.Delivered || decoded.Code != sms.InvalidLoginOrPassword {
+		t.Errorf("FAIL. Expected record '%+v', but got '%+v'", record{sms.Delivered, sms.InvalidLoginOrPassword}, decoded)
+	}
+}
@@ -0,0 +1,94 @@
+package sms
+
+import "unicode/utf16"
+
+const (
+	gsm7SingleLength = 160 // gsm7SingleLength is a maximum number of septets in a single GSM-7 message.
+	gsm7PartLength   = 153 // gsm7PartLength is a maximum number of septets in a part of a concatenated GSM-7 message.
+	ucs2SingleLength = 70  // ucs2SingleLength is a maximum number of UTF-16 code units in a single UCS-2 message.
+	ucs2PartLength   = 67  // ucs2PartLength is a maximum number of UTF-16 code units in a part of a concatenated UCS-2 message.
+)
+
+// Encoding represents the encoding the SMS message text is sent in.
+type Encoding int
+
+const (
+	Gsm7 Encoding = iota // Gsm7 is the GSM 03.38 7-bit default alphabet.
+	Ucs2                 // Ucs2 is the 16-bit encoding used when the text has characters out of the GSM alphabet.
+)
+
+// String returns the encoding description.
+func (e Encoding) String() string {
+	switch e {
+	case Gsm7:
+		return "GSM-7"
+	case Ucs2:
+		return "UCS-2"
+	default:
+		return "Invalid encoding"
+	}
+}
+
+// gsm7Basic contains characters of the GSM 03.38 basic character set.
+var gsm7Basic = makeCharset("@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+	"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà")
+
+// gsm7Extension contains characters of the GSM 03.38 extension table, each takes two septets.
+var gsm7Extension = makeCharset("\f^{}\\[~]|€")
+
+// Segments returns the number of SMS segments the text is sent in and the encoding.
+func Segments(text string) (int, Encoding) {
+	length, encoding := textLength(text)
+	if length == 0 {
+		return 0, encoding
+	}
+
+	single, part := gsm7SingleLength, gsm7PartLength
+	if encoding == Ucs2 {
+		single, part = ucs2SingleLength, ucs2PartLength
+	}
+
+	if length <= single {
+		return 1, encoding
+	}
+
+	return (length + part - 1) / part, encoding
+}
+
+// IsGsm7 returns true if the text can be sent in the GSM-7 encoding.
+func IsGsm7(text string) bool {
+	for _, r := range text {
+		if !gsm7Basic[r] && !gsm7Extension[r] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// textLength returns the length of the text in septets for GSM-7 or in UTF-16 code units for UCS-2.
+func textLength(text string) (int, Encoding) {
+	if !IsGsm7(text) {
+		return len(utf16.Encode([]rune(text))), Ucs2
+	}
+
+	length := 0
+	for _, r := range text {
+		if gsm7Extension[r] {
+			length += 2
+		} else {
+			length++
+		}
+	}
+
+	return length, Gsm7
+}
+
+func makeCharset(chars string) map[rune]bool {
+	charset := make(map[rune]bool)
+	for _, r := range chars {
+		charset[r] = true
+	}
+
+	return charset
+}
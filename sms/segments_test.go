@@ -0,0 +1,42 @@
+package sms_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+)
+
+func TestSegments(t *testing.T) {
+	var inputData = []struct {
+		name             string
+		text             string
+		expectedSegments int
+		expectedEncoding sms.Encoding
+	}{
+		{"empty", "", 0, sms.Gsm7},
+		{"short GSM", "Hello", 1, sms.Gsm7},
+		{"full GSM", strings.Repeat("a", 160), 1, sms.Gsm7},
+		{"two GSM parts", strings.Repeat("a", 161), 2, sms.Gsm7},
+		{"three GSM parts", strings.Repeat("a", 307), 3, sms.Gsm7},
+		{"GSM extension", strings.Repeat("€", 80), 1, sms.Gsm7},
+		{"GSM extension overflow", strings.Repeat("€", 80) + "a", 2, sms.Gsm7},
+		{"short UCS-2", "Привіт", 1, sms.Ucs2},
+		{"full UCS-2", strings.Repeat("ї", 70), 1, sms.Ucs2},
+		{"two UCS-2 parts", strings.Repeat("ї", 71), 2, sms.Ucs2},
+		{"mixed", strings.Repeat("a", 100) + "ї", 2, sms.Ucs2},
+		{"surrogate pairs", strings.Repeat("😀", 35), 1, sms.Ucs2},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			segments, encoding := sms.Segments(input.text)
+			if segments != input.expectedSegments {
+				t.Errorf("FAIL. Expected %d segments, but got %d", input.expectedSegments, segments)
+			}
+			if encoding != input.expectedEncoding {
+				t.Errorf("FAIL. Expected encoding '%s', but got '%s'", input.expectedEncoding, encoding)
+			}
+		})
+	}
+}
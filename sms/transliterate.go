@@ -0,0 +1,96 @@
+package sms
+
+import (
+	"strings"
+	"unicode"
+)
+
+// cyrillicLatin maps lower case Cyrillic letters to Latin by the Ukrainian national transliteration
+// (Cabinet of Ministers resolution 55 of 2010). Letters used in Russian only are mapped as well.
+var cyrillicLatin = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "h", 'ґ': "g", 'д': "d", 'е': "e", 'є': "ie", 'ж': "zh", 'з': "z",
+	'и': "y", 'і': "i", 'ї': "i", 'й': "i", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p",
+	'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "shch",
+	'ь': "", 'ю': "iu", 'я': "ia",
+	'ы': "y", 'э': "e", 'ё': "io", 'ъ': "",
+}
+
+// cyrillicLatinInitial overrides the mapping of letters at the beginning of a word.
+var cyrillicLatinInitial = map[rune]string{
+	'є': "ye", 'ї': "yi", 'й': "y", 'ю': "yu", 'я': "ya", 'ё': "yo",
+}
+
+// punctuationAscii maps typographic characters out of the GSM alphabet to their plain versions.
+var punctuationAscii = map[rune]string{
+	'“': "\"", '”': "\"", '„': "\"", '«': "\"", '»': "\"", '‘': "'", '’': "'", 'ʼ': "'",
+	'–': "-", '—': "-", '−': "-", '…': "...", ' ': " ", '№': "No", '`': "'",
+}
+
+// Transliterate replaces Cyrillic letters with Latin ones and typographic characters with plain ones,
+// so the text can be sent in the GSM-7 encoding which allows more than twice as many characters per segment.
+// Other characters are kept as is.
+func Transliterate(text string) string {
+	runes := []rune(text)
+	var builder strings.Builder
+	builder.Grow(len(text))
+
+	for i, r := range runes {
+		lower := unicode.ToLower(r)
+		latin, ok := cyrillicLatin[lower]
+		if !ok {
+			if isApostrophe(r) && i > 0 && i+1 < len(runes) && isCyrillic(runes[i-1]) && isCyrillic(runes[i+1]) {
+				// the apostrophe inside Ukrainian words is not transliterated
+				continue
+			}
+			if plain, ok := punctuationAscii[r]; ok {
+				builder.WriteString(plain)
+			} else {
+				builder.WriteRune(r)
+			}
+			continue
+		}
+
+		if i == 0 || !isLetter(runes[i-1]) {
+			if initial, ok := cyrillicLatinInitial[lower]; ok {
+				latin = initial
+			}
+		}
+		if lower == 'г' && i > 0 && unicode.ToLower(runes[i-1]) == 'з' {
+			// "зг" is transliterated as "zgh" to distinguish it from "ж"
+			latin = "gh"
+		}
+
+		if latin != "" && unicode.IsUpper(r) {
+			if isUpperWord(runes, i) {
+				latin = strings.ToUpper(latin)
+			} else {
+				latin = strings.ToUpper(latin[:1]) + latin[1:]
+			}
+		}
+
+		builder.WriteString(latin)
+	}
+
+	return builder.String()
+}
+
+// isUpperWord returns true if the letter is a part of an upper case word (like an abbreviation).
+func isUpperWord(runes []rune, i int) bool {
+	if i+1 < len(runes) && isLetter(runes[i+1]) {
+		return unicode.IsUpper(runes[i+1])
+	}
+
+	return i > 0 && isLetter(runes[i-1]) && unicode.IsUpper(runes[i-1])
+}
+
+func isCyrillic(r rune) bool {
+	return unicode.Is(unicode.Cyrillic, r)
+}
+
+func isLetter(r rune) bool {
+	return unicode.IsLetter(r) || isApostrophe(r)
+}
+
+func isApostrophe(r rune) bool {
+	return r == '\'' || r == '’' || r == 'ʼ'
+}
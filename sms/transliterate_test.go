@@ -0,0 +1,36 @@
+package sms_test
+
+import (
+	"testing"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+)
+
+func TestTransliterate(t *testing.T) {
+	var inputData = []struct {
+		text     string
+		expected string
+	}{
+		{"Згорани", "Zghorany"},
+		{"Юрій", "Yurii"},
+		{"Їжакевич", "Yizhakevych"},
+		{"м'ясо", "miaso"},
+		{"ЖУК", "ZHUK"},
+		{"Щука", "Shchuka"},
+		{"Привіт «світ» — 100 № 5", "Pryvit \"svit\" - 100 No 5"},
+		{"Код: 1234", "Kod: 1234"},
+		{"Hello, world!", "Hello, world!"},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.text, func(t *testing.T) {
+			actual := sms.Transliterate(input.text)
+			if actual != input.expected {
+				t.Errorf("FAIL. Expected '%s', but got '%s'", input.expected, actual)
+			}
+			if !sms.IsGsm7(actual) {
+				t.Errorf("FAIL. Expected GSM-7 text, but got '%s'", actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/internal/jsonl"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/reconcile"
+)
+
+// FileStore is a MessageStore which appends every changed record to a file as a JSON line.
+// The latest line of a message wins, so the file is replayed when the store is opened.
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	records records
+}
+
+// OpenFileStore opens (or creates) the file message store at the given path and replays its content.
+func OpenFileStore(path string) (*FileStore, error) {
+	rs, size, err := replayFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := jsonl.OpenAppend(path, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileStore{path: path, file: file, records: rs}, nil
+}
+
+// SaveMessage implements MessageStore interface.
+func (s *FileStore) SaveMessage(record *MessageRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, hadPrevious := s.records[record.Key()]
+	saved := s.records.save(record)
+	if err := s.append(saved); err != nil {
+		s.rollback(record.Key(), previous, hadPrevious)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateStatus implements MessageStore interface.
+func (s *FileStore) UpdateStatus(observation reconcile.Observation) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	previous, hadPrevious := s.records[observation.Key]
+	if hadPrevious {
+		previous = previous.copy()
+	}
+
+	updated, changed := s.records.updateStatus(observation)
+	if !changed {
+		return false, nil
+	}
+
+	if err := s.append(updated); err != nil {
+		s.rollback(observation.Key, previous, hadPrevious)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Get implements MessageStore interface.
+func (s *FileStore) Get(channel messaging.Channel, messageId int64) (*MessageRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.records.get(channel, messageId)
+}
+
+// Find implements MessageStore interface.
+func (s *FileStore) Find(query Query) ([]*MessageRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.records.find(query), nil
+}
+
+// Close closes the underlying file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *FileStore) append(record *MessageRecord) error {
+	if s.file == nil {
+		return fmt.Errorf("message file store '%s' is closed", s.path)
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	return s.file.Sync()
+}
+
+func (s *FileStore) rollback(key reconcile.Key, previous *MessageRecord, hadPrevious bool) {
+	if hadPrevious {
+		s.records[key] = previous
+	} else {
+		delete(s.records, key)
+	}
+}
+
+// replayFile returns the records saved to the file and the size of its complete lines.
+func replayFile(path string) (records, int64, error) {
+	rs := make(records)
+	size, err := jsonl.Replay(path, func(line []byte) error {
+		var record MessageRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("invalid message file '%s': %v", path, err)
+		}
+
+		rs[record.Key()] = &record
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return rs, size, nil
+}
@@ -0,0 +1,53 @@
+package store
+
+import (
+	"sync"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/reconcile"
+)
+
+// MemoryStore is a MessageStore which keeps messages in memory.
+type MemoryStore struct {
+	mu      sync.Mutex
+	records records
+}
+
+// NewMemoryStore creates new in-memory message store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: make(records)}
+}
+
+// SaveMessage implements MessageStore interface.
+func (s *MemoryStore) SaveMessage(record *MessageRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records.save(record)
+	return nil
+}
+
+// UpdateStatus implements MessageStore interface.
+func (s *MemoryStore) UpdateStatus(observation reconcile.Observation) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, changed := s.records.updateStatus(observation)
+	return changed, nil
+}
+
+// Get implements MessageStore interface.
+func (s *MemoryStore) Get(channel messaging.Channel, messageId int64) (*MessageRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.records.get(channel, messageId)
+}
+
+// Find implements MessageStore interface.
+func (s *MemoryStore) Find(query Query) ([]*MessageRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.records.find(query), nil
+}
@@ -0,0 +1,617 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/reconcile"
+)
+
+const (
+	defaultSqlBatchSize  = 100
+	defaultSqlBatchDelay = 5 * time.Millisecond
+	sqlSelectChunkSize   = 500
+	sqlStatusAttempts    = 3
+)
+
+// errStatusChanged is returned by tryUpdateStatus when the status was changed since it was read.
+var errStatusChanged = errors.New("store: status was changed concurrently")
+
+// SqlDialect represents the SQL database flavour, it defines query placeholders.
+type SqlDialect int
+
+const (
+	SqliteDialect   SqlDialect = iota // SqliteDialect uses "?" placeholders.
+	MysqlDialect                      // MysqlDialect uses "?" placeholders.
+	PostgresDialect                   // PostgresDialect uses "$1" placeholders.
+)
+
+// sqlMigrations are schema changes applied in order, the schema version is the number of applied migrations.
+// Applied migrations must never be changed, add a new one instead.
+var sqlMigrations = []string{
+	`CREATE TABLE messages (
+		channel VARCHAR(16) NOT NULL,
+		message_id BIGINT NOT NULL,
+		recipient VARCHAR(32) NOT NULL,
+		sender VARCHAR(64) NOT NULL,
+		metadata TEXT NOT NULL,
+		status INTEGER NOT NULL,
+		sent_at BIGINT NOT NULL,
+		updated_at BIGINT NOT NULL,
+		PRIMARY KEY (channel, message_id)
+	)`,
+	`CREATE TABLE message_transitions (
+		channel VARCHAR(16) NOT NULL,
+		message_id BIGINT NOT NULL,
+		seq INTEGER NOT NULL,
+		from_status INTEGER NOT NULL,
+		to_status INTEGER NOT NULL,
+		source VARCHAR(16) NOT NULL,
+		changed_at BIGINT NOT NULL,
+		PRIMARY KEY (channel, message_id, seq)
+	)`,
+	`CREATE INDEX messages_recipient ON messages (recipient, sent_at)`,
+	`CREATE INDEX messages_status ON messages (status, sent_at)`,
+	`CREATE INDEX messages_sent_at ON messages (sent_at)`,
+}
+
+// SqlStore is a MessageStore which keeps messages in a SQL database using database/sql.
+//
+// Writes are batched: concurrent SaveMessage and UpdateStatus calls are queued and applied
+// by a single writer in one transaction, and every call returns once its batch is committed.
+// Statuses are updated with a compare-and-set, so several processes may share the database.
+type SqlStore struct {
+	db      *sql.DB
+	dialect SqlDialect
+
+	mu         sync.Mutex
+	batchSize  int
+	batchDelay time.Duration
+
+	closeMu sync.RWMutex
+	writes  chan *sqlWrite
+	closed  bool
+	done    chan struct{}
+}
+
+type sqlWrite struct {
+	apply   func(tx *sql.Tx) error
+	changed bool
+	result  chan error
+}
+
+// OpenSqlStore applies pending schema migrations and creates new SQL message store.
+// The driver of the database must be registered by the application.
+func OpenSqlStore(db *sql.DB, dialect SqlDialect) (*SqlStore, error) {
+	s := &SqlStore{
+		db:         db,
+		dialect:    dialect,
+		batchSize:  defaultSqlBatchSize,
+		batchDelay: defaultSqlBatchDelay,
+		writes:     make(chan *sqlWrite, defaultSqlBatchSize),
+		done:       make(chan struct{}),
+	}
+
+	if err := s.migrate(); err != nil {
+		return nil, err
+	}
+
+	go s.write()
+	return s, nil
+}
+
+// SetBatchLimits sets the maximum number of writes per transaction (100 by default) and the time
+// the writer waits for more writes before committing a batch (5 milliseconds by default).
+func (s *SqlStore) SetBatchLimits(size int, delay time.Duration) *SqlStore {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if size > 0 {
+		s.batchSize = size
+	}
+	if delay >= 0 {
+		s.batchDelay = delay
+	}
+
+	return s
+}
+
+// SchemaVersion returns the number of applied schema migrations.
+func (s *SqlStore) SchemaVersion() (int, error) {
+	var version int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&version)
+	return version, err
+}
+
+// SaveMessage implements MessageStore interface.
+func (s *SqlStore) SaveMessage(record *MessageRecord) error {
+	record = record.copy()
+	_, err := s.enqueue(func(tx *sql.Tx) (bool, error) {
+		return true, s.saveMessage(tx, record)
+	})
+	return err
+}
+
+// SaveMessages saves many messages in one batch.
+func (s *SqlStore) SaveMessages(records []*MessageRecord) error {
+	copies := make([]*MessageRecord, len(records))
+	for i, record := range records {
+		copies[i] = record.copy()
+	}
+
+	_, err := s.enqueue(func(tx *sql.Tx) (bool, error) {
+		for _, record := range copies {
+			if err := s.saveMessage(tx, record); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	})
+	return err
+}
+
+// UpdateStatus implements MessageStore interface.
+func (s *SqlStore) UpdateStatus(observation reconcile.Observation) (bool, error) {
+	if observation.At.IsZero() {
+		observation.At = time.Now()
+	}
+
+	return s.enqueue(func(tx *sql.Tx) (bool, error) {
+		return s.updateStatus(tx, observation)
+	})
+}
+
+// Get implements MessageStore interface.
+func (s *SqlStore) Get(channel messaging.Channel, messageId int64) (*MessageRecord, error) {
+	records, err := s.query("WHERE channel = "+s.placeholder(1)+" AND message_id = "+s.placeholder(2), []interface{}{string(channel), messageId})
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return records[0], nil
+}
+
+// Find implements MessageStore interface.
+func (s *SqlStore) Find(query Query) ([]*MessageRecord, error) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.Replace(condition, "?", s.placeholder(len(args)), 1))
+	}
+
+	if query.Channel != "" {
+		add("channel = ?", string(query.Channel))
+	}
+	if query.Recipient != "" {
+		add("recipient = ?", query.Recipient)
+	}
+	if !query.From.IsZero() {
+		add("sent_at >= ?", toSqlTime(query.From))
+	}
+	if !query.To.IsZero() {
+		add("sent_at < ?", toSqlTime(query.To))
+	}
+	if len(query.Statuses) > 0 {
+		var placeholders []string
+		for _, status := range query.Statuses {
+			args = append(args, int(status))
+			placeholders = append(placeholders, s.placeholder(len(args)))
+		}
+		conditions = append(conditions, "status IN ("+strings.Join(placeholders, ", ")+")")
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	where += " ORDER BY sent_at, message_id"
+	if query.Limit > 0 {
+		where += " LIMIT " + strconv.Itoa(query.Limit)
+	}
+
+	return s.query(where, args)
+}
+
+// Close applies queued writes and stops the writer. It doesn't close the database.
+func (s *SqlStore) Close() error {
+	s.closeMu.Lock()
+	if s.closed {
+		s.closeMu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.writes)
+	s.closeMu.Unlock()
+
+	<-s.done
+	return nil
+}
+
+func (s *SqlStore) migrate() error {
+	_, err := s.db.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL PRIMARY KEY, applied_at BIGINT NOT NULL)")
+	if err != nil {
+		return err
+	}
+
+	version, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version > len(sqlMigrations) {
+		return fmt.Errorf("store: database schema version %d is newer than supported %d", version, len(sqlMigrations))
+	}
+
+	for i := version; i < len(sqlMigrations); i++ {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(sqlMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("store: migration %d failed: %v", i+1, err)
+		}
+		if _, err := tx.Exec("INSERT INTO schema_migrations (version, applied_at) VALUES ("+s.placeholder(1)+", "+s.placeholder(2)+")", i+1, toSqlTime(time.Now())); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// enqueue passes the write to the writer and waits until its batch is committed.
+func (s *SqlStore) enqueue(apply func(tx *sql.Tx) (bool, error)) (bool, error) {
+	w := &sqlWrite{result: make(chan error, 1)}
+	w.apply = func(tx *sql.Tx) error {
+		changed, err := apply(tx)
+		w.changed = changed
+		return err
+	}
+
+	s.closeMu.RLock()
+	if s.closed {
+		s.closeMu.RUnlock()
+		return false, errors.New("store: SQL store is closed")
+	}
+	// sending under the lock keeps Close from closing the channel in between
+	s.writes <- w
+	s.closeMu.RUnlock()
+
+	err := <-w.result
+	return w.changed && err == nil, err
+}
+
+func (s *SqlStore) write() {
+	defer close(s.done)
+
+	for w := range s.writes {
+		s.mu.Lock()
+		size, delay := s.batchSize, s.batchDelay
+		s.mu.Unlock()
+
+		batch := []*sqlWrite{w}
+		timer := time.NewTimer(delay)
+	collect:
+		for len(batch) < size {
+			select {
+			case next, ok := <-s.writes:
+				if !ok {
+					break collect
+				}
+				batch = append(batch, next)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		s.commit(batch)
+	}
+}
+
+// commit applies the batch in one transaction. If the transaction fails, writes are applied
+// one by one, so a failed write doesn't fail the others.
+func (s *SqlStore) commit(batch []*sqlWrite) {
+	if len(batch) > 1 {
+		if err := s.transaction(batch); err == nil {
+			for _, w := range batch {
+				w.result <- nil
+			}
+			return
+		}
+	}
+
+	for _, w := range batch {
+		w.result <- s.transaction([]*sqlWrite{w})
+	}
+}
+
+func (s *SqlStore) transaction(batch []*sqlWrite) error {
+	tx, err := s.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+
+	for _, w := range batch {
+		if err := w.apply(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SqlStore) saveMessage(tx *sql.Tx, record *MessageRecord) error {
+	metadata, err := json.Marshal(record.Metadata)
+	if err != nil {
+		return err
+	}
+
+	transitions, err := s.countTransitions(tx, record.Key())
+	if err != nil {
+		return err
+	}
+
+	var exists int
+	err = tx.QueryRow("SELECT 1 FROM messages WHERE channel = "+s.placeholder(1)+" AND message_id = "+s.placeholder(2),
+		string(record.Channel), record.MessageId).Scan(&exists)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	if exists == 0 {
+		_, err = tx.Exec("INSERT INTO messages (channel, message_id, recipient, sender, metadata, status, sent_at, updated_at) VALUES ("+s.placeholders(8)+")",
+			string(record.Channel), record.MessageId, record.Recipient, record.Sender, string(metadata), int(record.Status), toSqlTime(record.SentAt), toSqlTime(record.UpdatedAt))
+		if err != nil {
+			return err
+		}
+		return s.insertTransitions(tx, record.Key(), 0, record.History)
+	}
+
+	if transitions > 0 {
+		// the status was already recorded (e.g. the callback came first), keep it
+		_, err = tx.Exec("UPDATE messages SET recipient = "+s.placeholder(1)+", sender = "+s.placeholder(2)+", metadata = "+s.placeholder(3)+", sent_at = "+s.placeholder(4)+
+			" WHERE channel = "+s.placeholder(5)+" AND message_id = "+s.placeholder(6),
+			record.Recipient, record.Sender, string(metadata), toSqlTime(record.SentAt), string(record.Channel), record.MessageId)
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE messages SET recipient = "+s.placeholder(1)+", sender = "+s.placeholder(2)+", metadata = "+s.placeholder(3)+", sent_at = "+s.placeholder(4)+
+		", status = "+s.placeholder(5)+", updated_at = "+s.placeholder(6)+" WHERE channel = "+s.placeholder(7)+" AND message_id = "+s.placeholder(8),
+		record.Recipient, record.Sender, string(metadata), toSqlTime(record.SentAt), int(record.Status), toSqlTime(record.UpdatedAt), string(record.Channel), record.MessageId)
+	if err != nil {
+		return err
+	}
+
+	return s.insertTransitions(tx, record.Key(), 0, record.History)
+}
+
+// updateStatus applies the observation, re-reading the status if another process changed it concurrently.
+func (s *SqlStore) updateStatus(tx *sql.Tx, observation reconcile.Observation) (bool, error) {
+	for attempt := 1; ; attempt++ {
+		changed, err := s.tryUpdateStatus(tx, observation)
+		if err != errStatusChanged {
+			return changed, err
+		}
+		if attempt == sqlStatusAttempts {
+			return false, fmt.Errorf("store: status of message %d was changed concurrently %d times", observation.MessageId, attempt)
+		}
+	}
+}
+
+func (s *SqlStore) tryUpdateStatus(tx *sql.Tx, observation reconcile.Observation) (bool, error) {
+	var current int
+	err := tx.QueryRow("SELECT status FROM messages WHERE channel = "+s.placeholder(1)+" AND message_id = "+s.placeholder(2),
+		string(observation.Channel), observation.MessageId).Scan(&current)
+	if err == sql.ErrNoRows {
+		_, err = tx.Exec("INSERT INTO messages (channel, message_id, recipient, sender, metadata, status, sent_at, updated_at) VALUES ("+s.placeholders(8)+")",
+			string(observation.Channel), observation.MessageId, "", "", "null", int(messaging.Unknown), toSqlTime(time.Time{}), toSqlTime(time.Time{}))
+	}
+	if err != nil {
+		return false, err
+	}
+
+	from := messaging.DeliveryStatus(current)
+	if !reconcile.Advances(from, observation.Status) {
+		return false, nil
+	}
+
+	// compare-and-set, another process may have changed the status since it was read
+	result, err := tx.Exec("UPDATE messages SET status = "+s.placeholder(1)+", updated_at = "+s.placeholder(2)+
+		" WHERE channel = "+s.placeholder(3)+" AND message_id = "+s.placeholder(4)+" AND status = "+s.placeholder(5),
+		int(observation.Status), toSqlTime(observation.At), string(observation.Channel), observation.MessageId, current)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, errStatusChanged
+	}
+
+	seq, err := s.countTransitions(tx, observation.Key)
+	if err != nil {
+		return false, err
+	}
+
+	transition := reconcile.Transition{From: from, To: observation.Status, Source: observation.Source, At: observation.At}
+	return true, s.insertTransitions(tx, observation.Key, seq, []reconcile.Transition{transition})
+}
+
+func (s *SqlStore) countTransitions(tx *sql.Tx, key reconcile.Key) (int, error) {
+	var count int
+	err := tx.QueryRow("SELECT COUNT(*) FROM message_transitions WHERE channel = "+s.placeholder(1)+" AND message_id = "+s.placeholder(2),
+		string(key.Channel), key.MessageId).Scan(&count)
+	return count, err
+}
+
+func (s *SqlStore) insertTransitions(tx *sql.Tx, key reconcile.Key, seq int, transitions []reconcile.Transition) error {
+	for i, transition := range transitions {
+		_, err := tx.Exec("INSERT INTO message_transitions (channel, message_id, seq, from_status, to_status, source, changed_at) VALUES ("+s.placeholders(7)+")",
+			string(key.Channel), key.MessageId, seq+i, int(transition.From), int(transition.To), string(transition.Source), toSqlTime(transition.At))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// query returns messages selected by the where clause (which may contain order and limit) with their history.
+func (s *SqlStore) query(where string, args []interface{}) ([]*MessageRecord, error) {
+	rows, err := s.db.Query("SELECT channel, message_id, recipient, sender, metadata, status, sent_at, updated_at FROM messages "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*MessageRecord
+	index := make(map[reconcile.Key]*MessageRecord)
+	for rows.Next() {
+		var record MessageRecord
+		var channel, metadata string
+		var status int
+		var sentAt, updatedAt int64
+		if err := rows.Scan(&channel, &record.MessageId, &record.Recipient, &record.Sender, &metadata, &status, &sentAt, &updatedAt); err != nil {
+			return nil, err
+		}
+
+		record.Channel = messaging.Channel(channel)
+		record.Status = messaging.DeliveryStatus(status)
+		record.SentAt = fromSqlTime(sentAt)
+		record.UpdatedAt = fromSqlTime(updatedAt)
+		if err := json.Unmarshal([]byte(metadata), &record.Metadata); err != nil {
+			return nil, err
+		}
+
+		result = append(result, &record)
+		index[record.Key()] = &record
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.loadHistory(result, index); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// loadHistory loads transitions of the records by channels and chunks of message ids, so the primary key is used.
+func (s *SqlStore) loadHistory(records []*MessageRecord, index map[reconcile.Key]*MessageRecord) error {
+	var channels []messaging.Channel
+	ids := make(map[messaging.Channel][]int64)
+	for _, record := range records {
+		if _, ok := ids[record.Channel]; !ok {
+			channels = append(channels, record.Channel)
+		}
+		ids[record.Channel] = append(ids[record.Channel], record.MessageId)
+	}
+
+	for _, channel := range channels {
+		channelIds := ids[channel]
+		for start := 0; start < len(channelIds); start += sqlSelectChunkSize {
+			end := start + sqlSelectChunkSize
+			if end > len(channelIds) {
+				end = len(channelIds)
+			}
+
+			if err := s.loadHistoryChunk(channel, channelIds[start:end], index); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *SqlStore) loadHistoryChunk(channel messaging.Channel, ids []int64, index map[reconcile.Key]*MessageRecord) error {
+	args := []interface{}{string(channel)}
+	var placeholders []string
+	for _, id := range ids {
+		args = append(args, id)
+		placeholders = append(placeholders, s.placeholder(len(args)))
+	}
+
+	rows, err := s.db.Query("SELECT message_id, from_status, to_status, source, changed_at FROM message_transitions WHERE channel = "+s.placeholder(1)+
+		" AND message_id IN ("+strings.Join(placeholders, ", ")+") ORDER BY message_id, seq", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		key := reconcile.Key{Channel: channel}
+		var source string
+		var from, to int
+		var at int64
+		if err := rows.Scan(&key.MessageId, &from, &to, &source, &at); err != nil {
+			return err
+		}
+
+		if record, ok := index[key]; ok {
+			record.History = append(record.History, reconcile.Transition{
+				From:   messaging.DeliveryStatus(from),
+				To:     messaging.DeliveryStatus(to),
+				Source: reconcile.Source(source),
+				At:     fromSqlTime(at),
+			})
+		}
+	}
+
+	return rows.Err()
+}
+
+func (s *SqlStore) placeholder(n int) string {
+	if s.dialect == PostgresDialect {
+		return "$" + strconv.Itoa(n)
+	}
+
+	return "?"
+}
+
+func (s *SqlStore) placeholders(count int) string {
+	placeholders := make([]string, count)
+	for i := range placeholders {
+		placeholders[i] = s.placeholder(i + 1)
+	}
+
+	return strings.Join(placeholders, ", ")
+}
+
+// toSqlTime stores times as Unix nanoseconds, so they are compared the same way by all databases.
+func toSqlTime(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.UnixNano()
+}
+
+func fromSqlTime(nanoseconds int64) time.Time {
+	if nanoseconds == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, nanoseconds).UTC()
+}
@@ -0,0 +1,238 @@
+// Package store contains a store of sent messages and their delivery statuses.
+package store
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/reconcile"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	viberplussms "github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+// ErrNotFound is returned when the message is not found in the store.
+var ErrNotFound = errors.New("store: message not found")
+
+// MessageRecord represents a sent message and what happened to it.
+type MessageRecord struct {
+	Channel   messaging.Channel        `json:"channel"`    // Channel is a channel the message was sent through.
+	MessageId int64                    `json:"message_id"` // MessageId is an id returned by the provider.
+	Recipient string                   `json:"recipient"`  // Recipient is a receiver phone number.
+	Sender    string                   `json:"sender"`     // Sender is a message sender.
+	Metadata  map[string]string        `json:"metadata"`   // Metadata contains application defined values (like tenant or campaign).
+	Status    messaging.DeliveryStatus `json:"status"`     // Status is the current delivery status.
+	SentAt    time.Time                `json:"sent_at"`    // SentAt is a time when the message was sent.
+	UpdatedAt time.Time                `json:"updated_at"` // UpdatedAt is a time of the last status change.
+	History   []reconcile.Transition   `json:"history"`    // History contains all status changes in order.
+}
+
+// Key returns the key identifying the message.
+func (r *MessageRecord) Key() reconcile.Key {
+	return reconcile.Key{Channel: r.Channel, MessageId: r.MessageId}
+}
+
+// NewSmsRecord creates the record of the sent SMS message.
+func NewSmsRecord(messageId int64, message *sms.Message) *MessageRecord {
+	return &MessageRecord{
+		Channel:   messaging.Sms,
+		MessageId: messageId,
+		Recipient: message.ReceiverPhone,
+		Sender:    message.Sender,
+		SentAt:    time.Now(),
+	}
+}
+
+// NewViberRecord creates the record of the sent Viber message.
+func NewViberRecord(messageId int64, message *viber.Message) *MessageRecord {
+	return &MessageRecord{
+		Channel:   messaging.Viber,
+		MessageId: messageId,
+		Recipient: message.Receiver,
+		Sender:    message.Sender,
+		SentAt:    time.Now(),
+	}
+}
+
+// NewViberSmsRecord creates the record of the sent Viber plus SMS message.
+func NewViberSmsRecord(messageId int64, message *viberplussms.Message) *MessageRecord {
+	record := NewViberRecord(messageId, &message.Message)
+	record.Channel = messaging.ViberSms
+	return record
+}
+
+// Query describes which messages should be found. Zero fields don't filter.
+type Query struct {
+	Channel   messaging.Channel          // Channel is a channel of the messages.
+	Recipient string                     // Recipient is a receiver phone number.
+	From      time.Time                  // From is the earliest send time (inclusive).
+	To        time.Time                  // To is the latest send time (exclusive).
+	Statuses  []messaging.DeliveryStatus // Statuses are the accepted current statuses.
+	Limit     int                        // Limit is a maximum number of returned messages.
+}
+
+// Matches returns true if the record satisfies the query.
+func (q *Query) Matches(record *MessageRecord) bool {
+	if q.Channel != "" && record.Channel != q.Channel {
+		return false
+	}
+	if q.Recipient != "" && record.Recipient != q.Recipient {
+		return false
+	}
+	if !q.From.IsZero() && record.SentAt.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !record.SentAt.Before(q.To) {
+		return false
+	}
+	if len(q.Statuses) == 0 {
+		return true
+	}
+
+	for _, status := range q.Statuses {
+		if record.Status == status {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MessageStore persists sent messages and their delivery statuses.
+type MessageStore interface {
+	// SaveMessage saves the sent message. If a status of the message was already
+	// recorded (e.g. the callback came first), the status and its history are kept.
+	SaveMessage(record *MessageRecord) error
+	// UpdateStatus applies the observed status to the message using the reconcile rules,
+	// so duplicates and stale statuses are ignored. It returns true if the status was changed.
+	// Unknown messages are created with the status only.
+	UpdateStatus(observation reconcile.Observation) (bool, error)
+	// Get returns the message by the channel and id, or ErrNotFound.
+	Get(channel messaging.Channel, messageId int64) (*MessageRecord, error)
+	// Find returns messages matching the query ordered by send time.
+	Find(query Query) ([]*MessageRecord, error)
+}
+
+// RecordViberCallbacks returns a function which saves Viber status callbacks to the store.
+// Pass it to viber.NewCallbackHandler.
+func RecordViberCallbacks(s MessageStore) func(event *viber.CallbackEvent) error {
+	return func(event *viber.CallbackEvent) error {
+		_, err := s.UpdateStatus(reconcile.Observation{
+			Key:    reconcile.Key{Channel: messaging.Viber, MessageId: event.MessageId},
+			Status: messaging.FromViberStatus(event.Status),
+			Source: reconcile.Callback,
+			At:     event.ReceivedAt,
+		})
+		return err
+	}
+}
+
+// RecordViberSmsCallbacks returns a function which saves Viber plus SMS status callbacks to the store.
+// Pass it to viber/sms.NewCallbackHandler.
+func RecordViberSmsCallbacks(s MessageStore) func(event *viberplussms.CallbackEvent) error {
+	return func(event *viberplussms.CallbackEvent) error {
+		receipt := &viberplussms.MessageReceipt{
+			MessageId:        event.MessageId,
+			Status:           event.Status,
+			SmsMessageId:     event.SmsMessageId,
+			SmsMessageStatus: event.SmsMessageStatus,
+		}
+
+		_, err := s.UpdateStatus(reconcile.Observation{
+			Key:    reconcile.Key{Channel: messaging.ViberSms, MessageId: event.MessageId},
+			Status: messaging.FromViberSmsReceipt(receipt),
+			Source: reconcile.Callback,
+			At:     event.ReceivedAt,
+		})
+		return err
+	}
+}
+
+// records is an in-memory index of records shared by the memory and file stores.
+// It is not safe for concurrent use.
+type records map[reconcile.Key]*MessageRecord
+
+func (rs records) save(record *MessageRecord) *MessageRecord {
+	saved := record.copy()
+	if existing, ok := rs[record.Key()]; ok && len(existing.History) > 0 {
+		saved.Status = existing.Status
+		saved.UpdatedAt = existing.UpdatedAt
+		saved.History = existing.History
+	}
+
+	rs[saved.Key()] = saved
+	return saved.copy()
+}
+
+func (rs records) updateStatus(observation reconcile.Observation) (*MessageRecord, bool) {
+	if observation.At.IsZero() {
+		observation.At = time.Now()
+	}
+
+	record, ok := rs[observation.Key]
+	if !ok {
+		record = &MessageRecord{Channel: observation.Channel, MessageId: observation.MessageId}
+	}
+
+	if !reconcile.Advances(record.Status, observation.Status) {
+		return nil, false
+	}
+
+	record.History = append(record.History, reconcile.Transition{
+		From:   record.Status,
+		To:     observation.Status,
+		Source: observation.Source,
+		At:     observation.At,
+	})
+	record.Status = observation.Status
+	record.UpdatedAt = observation.At
+	rs[observation.Key] = record
+
+	return record.copy(), true
+}
+
+func (rs records) get(channel messaging.Channel, messageId int64) (*MessageRecord, error) {
+	record, ok := rs[reconcile.Key{Channel: channel, MessageId: messageId}]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return record.copy(), nil
+}
+
+func (rs records) find(query Query) []*MessageRecord {
+	var result []*MessageRecord
+	for _, record := range rs {
+		if query.Matches(record) {
+			result = append(result, record.copy())
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].SentAt.Equal(result[j].SentAt) {
+			return result[i].MessageId < result[j].MessageId
+		}
+		return result[i].SentAt.Before(result[j].SentAt)
+	})
+
+	if query.Limit > 0 && len(result) > query.Limit {
+		result = result[:query.Limit]
+	}
+
+	return result
+}
+
+func (r *MessageRecord) copy() *MessageRecord {
+	c := *r
+	c.History = append([]reconcile.Transition(nil), r.History...)
+	if r.Metadata != nil {
+		c.Metadata = make(map[string]string, len(r.Metadata))
+		for key, value := range r.Metadata {
+			c.Metadata[key] = value
+		}
+	}
+
+	return &c
+}
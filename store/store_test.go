@@ -0,0 +1,391 @@
+package store_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/reconcile"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/store"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	_ "modernc.org/sqlite"
+)
+
+var start = time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+type storeFactory struct {
+	name string
+	open func(t *testing.T) store.MessageStore
+}
+
+func storeFactories() []storeFactory {
+	return []storeFactory{
+		{"memory", func(t *testing.T) store.MessageStore { return store.NewMemoryStore() }},
+		{"file", func(t *testing.T) store.MessageStore {
+			s, err := store.OpenFileStore(filepath.Join(t.TempDir(), "messages.jsonl"))
+			if err != nil {
+				t.Fatalf("FAIL. Unexpected error: %v", err)
+			}
+			t.Cleanup(func() { s.Close() })
+			return s
+		}},
+		{"sql", func(t *testing.T) store.MessageStore { return openSqlStore(t, openSqliteDb(t)) }},
+	}
+}
+
+func TestSaveAndUpdateStatus(t *testing.T) {
+	for _, factory := range storeFactories() {
+		t.Run(factory.name, func(t *testing.T) {
+			s := factory.open(t)
+
+			record := store.NewSmsRecord(31885463, &sms.Message{ReceiverPhone: "380504444444", Sender: "380503333333"})
+			record.SentAt = start
+			record.Metadata = map[string]string{"tenant": "acme"}
+			if err := s.SaveMessage(record); err != nil {
+				t.Fatalf("FAIL. Unexpected error: %v", err)
+			}
+
+			key := record.Key()
+			observations := []struct {
+				status  messaging.DeliveryStatus
+				source  reconcile.Source
+				changed bool
+			}{
+				{messaging.Sent, reconcile.Callback, true},
+				{messaging.Sent, reconcile.Polling, false},
+				{messaging.Delivered, reconcile.Polling, true},
+				{messaging.Pending, reconcile.Callback, false},
+			}
+			for i, observation := range observations {
+				changed, err := s.UpdateStatus(reconcile.Observation{Key: key, Status: observation.status, Source: observation.source, At: start.Add(time.Duration(i+1) * time.Minute)})
+				if err != nil {
+					t.Fatalf("FAIL. Unexpected error: %v", err)
+				}
+				if changed != observation.changed {
+					t.Errorf("FAIL. Expected observation %d changed to be %v, but got %v", i, observation.changed, changed)
+				}
+			}
+
+			saved, err := s.Get(messaging.Sms, 31885463)
+			if err != nil {
+				t.Fatalf("FAIL. Unexpected error: %v", err)
+			}
+
+			if saved.Status != messaging.Delivered || len(saved.History) != 2 {
+				t.Errorf("FAIL. Expected delivered status with 2 transitions, but got '%s' with %d", saved.Status, len(saved.History))
+			}
+			if saved.Recipient != "380504444444" || saved.Metadata["tenant"] != "acme" || !saved.SentAt.Equal(start) {
+				t.Errorf("FAIL. Unexpected record '%+v'", saved)
+			}
+			if !saved.UpdatedAt.Equal(start.Add(3 * time.Minute)) {
+				t.Errorf("FAIL. Expected updated at '%v', but got '%v'", start.Add(3*time.Minute), saved.UpdatedAt)
+			}
+
+			if _, err := s.Get(messaging.Viber, 31885463); err != store.ErrNotFound {
+				t.Errorf("FAIL. Expected not found error, but got '%v'", err)
+			}
+		})
+	}
+}
+
+func TestSaveMessageAfterCallback(t *testing.T) {
+	for _, factory := range storeFactories() {
+		t.Run(factory.name, func(t *testing.T) {
+			s := factory.open(t)
+
+			handle := store.RecordViberCallbacks(s)
+			if err := handle(&viber.CallbackEvent{MessageId: 429, Status: viber.Delivered, ReceivedAt: start}); err != nil {
+				t.Fatalf("FAIL. Unexpected error: %v", err)
+			}
+
+			record := store.NewViberRecord(429, &viber.Message{Receiver: "380504444444", Sender: "Sender"})
+			if err := s.SaveMessage(record); err != nil {
+				t.Fatalf("FAIL. Unexpected error: %v", err)
+			}
+
+			saved, err := s.Get(messaging.Viber, 429)
+			if err != nil {
+				t.Fatalf("FAIL. Unexpected error: %v", err)
+			}
+
+			if saved.Status != messaging.Delivered || saved.Recipient != "380504444444" {
+				t.Errorf("FAIL. Expected delivered record of recipient, but got '%+v'", saved)
+			}
+			if len(saved.History) != 1 || saved.History[0].Source != reconcile.Callback {
+				t.Errorf("FAIL. Expected callback transition, but got '%+v'", saved.History)
+			}
+		})
+	}
+}
+
+func TestFindMessages(t *testing.T) {
+	for _, factory := range storeFactories() {
+		t.Run(factory.name, func(t *testing.T) {
+			s := factory.open(t)
+
+			messages := []struct {
+				channel   messaging.Channel
+				id        int64
+				recipient string
+				status    messaging.DeliveryStatus
+			}{
+				{messaging.Sms, 1, "380501111111", messaging.Delivered},
+				{messaging.Viber, 2, "380502222222", messaging.Sent},
+				{messaging.Sms, 3, "380501111111", messaging.Undelivered},
+				{messaging.ViberSms, 4, "380501111111", messaging.Unknown},
+			}
+			for i, message := range messages {
+				record := &store.MessageRecord{Channel: message.channel, MessageId: message.id, Recipient: message.recipient, SentAt: start.Add(time.Duration(i) * time.Hour)}
+				if err := s.SaveMessage(record); err != nil {
+					t.Fatalf("FAIL. Unexpected error: %v", err)
+				}
+				if message.status != messaging.Unknown {
+					s.UpdateStatus(reconcile.Observation{Key: record.Key(), Status: message.status, Source: reconcile.Polling})
+				}
+			}
+
+			var inputData = []struct {
+				name        string
+				query       store.Query
+				expectedIds []int64
+			}{
+				{"all", store.Query{}, []int64{1, 2, 3, 4}},
+				{"by recipient", store.Query{Recipient: "380501111111"}, []int64{1, 3, 4}},
+				{"by channel", store.Query{Channel: messaging.Sms}, []int64{1, 3}},
+				{"by time range", store.Query{From: start.Add(time.Hour), To: start.Add(3 * time.Hour)}, []int64{2, 3}},
+				{"by status", store.Query{Statuses: []messaging.DeliveryStatus{messaging.Sent, messaging.Undelivered}}, []int64{2, 3}},
+				{"with limit", store.Query{Recipient: "380501111111", Limit: 2}, []int64{1, 3}},
+			}
+
+			for _, input := range inputData {
+				records, err := s.Find(input.query)
+				if err != nil {
+					t.Fatalf("FAIL. Unexpected error: %v", err)
+				}
+
+				var ids []int64
+				for _, record := range records {
+					ids = append(ids, record.MessageId)
+				}
+
+				if len(ids) != len(input.expectedIds) {
+					t.Errorf("FAIL. %s: expected ids '%v', but got '%v'", input.name, input.expectedIds, ids)
+					continue
+				}
+				for i := range ids {
+					if ids[i] != input.expectedIds[i] {
+						t.Errorf("FAIL. %s: expected ids '%v', but got '%v'", input.name, input.expectedIds, ids)
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSameIdInDifferentChannels(t *testing.T) {
+	for _, factory := range storeFactories() {
+		t.Run(factory.name, func(t *testing.T) {
+			s := factory.open(t)
+
+			for _, channel := range []messaging.Channel{messaging.Sms, messaging.Viber} {
+				if err := s.SaveMessage(&store.MessageRecord{Channel: channel, MessageId: 7, Recipient: "380501111111", SentAt: start}); err != nil {
+					t.Fatalf("FAIL. Unexpected error: %v", err)
+				}
+			}
+			s.UpdateStatus(reconcile.Observation{Key: reconcile.Key{Channel: messaging.Sms, MessageId: 7}, Status: messaging.Sent, Source: reconcile.Polling})
+			s.UpdateStatus(reconcile.Observation{Key: reconcile.Key{Channel: messaging.Sms, MessageId: 7}, Status: messaging.Delivered, Source: reconcile.Polling})
+			s.UpdateStatus(reconcile.Observation{Key: reconcile.Key{Channel: messaging.Viber, MessageId: 7}, Status: messaging.Rejected, Source: reconcile.Callback})
+
+			var inputData = []struct {
+				channel         messaging.Channel
+				expectedHistory int
+			}{
+				{messaging.Sms, 2},
+				{messaging.Viber, 1},
+			}
+
+			for _, input := range inputData {
+				record, err := s.Get(input.channel, 7)
+				if err != nil {
+					t.Fatalf("FAIL. Unexpected error: %v", err)
+				}
+				if len(record.History) != input.expectedHistory {
+					t.Errorf("FAIL. Expected %d transitions of the %s message, but got %d", input.expectedHistory, input.channel, len(record.History))
+				}
+			}
+		})
+	}
+}
+
+func TestFileStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "messages.jsonl")
+
+	s, err := store.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error: %v", err)
+	}
+
+	record := &store.MessageRecord{Channel: messaging.Viber, MessageId: 429, Recipient: "380504444444", SentAt: start}
+	s.SaveMessage(record)
+	s.UpdateStatus(reconcile.Observation{Key: record.Key(), Status: messaging.Sent, Source: reconcile.Polling, At: start})
+	s.UpdateStatus(reconcile.Observation{Key: record.Key(), Status: messaging.Delivered, Source: reconcile.Callback, At: start})
+	s.Close()
+
+	// simulate a write interrupted by a crash
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	file.WriteString(`{"channel":"viber","message_id":430`)
+	file.Close()
+
+	s, err = store.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error: %v", err)
+	}
+	defer s.Close()
+
+	saved, err := s.Get(messaging.Viber, 429)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error: %v", err)
+	}
+	if saved.Status != messaging.Delivered || len(saved.History) != 2 || saved.Recipient != "380504444444" {
+		t.Errorf("FAIL. Unexpected record '%+v'", saved)
+	}
+
+	if _, err := s.Get(messaging.Viber, 430); err != store.ErrNotFound {
+		t.Errorf("FAIL. Expected partial record to be skipped, but got '%v'", err)
+	}
+
+	s.SaveMessage(&store.MessageRecord{Channel: messaging.Viber, MessageId: 431, SentAt: start})
+	s.Close()
+
+	s, err = store.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("FAIL. Expected records after the partial line to be readable, but got error: %v", err)
+	}
+	if _, err := s.Get(messaging.Viber, 431); err != nil {
+		t.Errorf("FAIL. Unexpected error: %v", err)
+	}
+}
+
+func openSqliteDb(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "messages.db")+"?_pragma=busy_timeout%3d5000&_pragma=journal_mode%3dwal")
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func openSqlStore(t *testing.T, db *sql.DB) *store.SqlStore {
+	s, err := store.OpenSqlStore(db, store.SqliteDialect)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestSqlStoreMigrations(t *testing.T) {
+	db := openSqliteDb(t)
+
+	s := openSqlStore(t, db)
+	s.SaveMessage(&store.MessageRecord{Channel: messaging.Sms, MessageId: 1, Recipient: "380501111111", SentAt: start})
+	s.Close()
+
+	// migrations are applied once, so the data survives reopening
+	s = openSqlStore(t, db)
+	version, err := s.SchemaVersion()
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error: %v", err)
+	}
+	if version != 5 {
+		t.Errorf("FAIL. Expected schema version 5, but got %d", version)
+	}
+
+	if _, err := s.Get(messaging.Sms, 1); err != nil {
+		t.Errorf("FAIL. Unexpected error: %v", err)
+	}
+}
+
+func TestSqlStoreBatchedWrites(t *testing.T) {
+	s := openSqlStore(t, openSqliteDb(t)).SetBatchLimits(50, 10*time.Millisecond)
+
+	var records []*store.MessageRecord
+	for i := 0; i < 100; i++ {
+		records = append(records, &store.MessageRecord{Channel: messaging.Viber, MessageId: int64(i), Recipient: "380501111111", SentAt: start.Add(time.Duration(i) * time.Second)})
+	}
+	if err := s.SaveMessages(records); err != nil {
+		t.Fatalf("FAIL. Unexpected error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	changes := make(chan bool, 200)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(id int64) {
+			defer wg.Done()
+			for _, status := range []messaging.DeliveryStatus{messaging.Delivered, messaging.Sent} {
+				changed, err := s.UpdateStatus(reconcile.Observation{Key: reconcile.Key{Channel: messaging.Viber, MessageId: id}, Status: status, Source: reconcile.Callback})
+				if err != nil {
+					t.Errorf("FAIL. Unexpected error: %v", err)
+				}
+				changes <- changed
+			}
+		}(int64(i))
+	}
+	wg.Wait()
+	close(changes)
+
+	changed := 0
+	for c := range changes {
+		if c {
+			changed++
+		}
+	}
+	if changed != 100 {
+		t.Errorf("FAIL. Expected 100 changed statuses, but got %d", changed)
+	}
+
+	delivered, err := s.Find(store.Query{Statuses: []messaging.DeliveryStatus{messaging.Delivered}})
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error: %v", err)
+	}
+	if len(delivered) != 100 || len(delivered[0].History) != 1 {
+		t.Errorf("FAIL. Expected 100 delivered messages with 1 transition, but got %d", len(delivered))
+	}
+}
+
+func TestSqlStoreConcurrentStatusChange(t *testing.T) {
+	db := openSqliteDb(t)
+	s := openSqlStore(t, db)
+	if err := s.SaveMessage(&store.MessageRecord{Channel: messaging.Sms, MessageId: 1, Recipient: "380501111111", SentAt: start}); err != nil {
+		t.Fatalf("FAIL. Unexpected error: %v", err)
+	}
+
+	// the trigger skips the first status update, as if another process changed the status in between
+	_, err := db.Exec(`CREATE TABLE skipped_updates (n INTEGER);
+		INSERT INTO skipped_updates VALUES (1);
+		CREATE TRIGGER skip_status_update BEFORE UPDATE OF status ON messages WHEN (SELECT COUNT(*) FROM skipped_updates) > 0
+		BEGIN
+			DELETE FROM skipped_updates;
+			SELECT RAISE(IGNORE);
+		END;`)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error: %v", err)
+	}
+
+	changed, err := s.UpdateStatus(reconcile.Observation{Key: reconcile.Key{Channel: messaging.Sms, MessageId: 1}, Status: messaging.Delivered, Source: reconcile.Callback})
+	if err != nil || !changed {
+		t.Fatalf("FAIL. Expected the status to be changed after the retry, but got %t (%v)", changed, err)
+	}
+
+	record, err := s.Get(messaging.Sms, 1)
+	if err != nil || record.Status != messaging.Delivered || len(record.History) != 1 {
+		t.Errorf("FAIL. Expected delivered message with 1 transition, but got '%+v' (%v)", record, err)
+	}
+}
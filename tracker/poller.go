@@ -0,0 +1,59 @@
+package tracker
+
+import (
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	viberplussms "github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+// Poller gets the current delivery status of the message.
+type Poller interface {
+	Poll(messageId int64) (messaging.DeliveryStatus, error)
+}
+
+// PollerFunc is an adapter to use ordinary functions as pollers.
+type PollerFunc func(messageId int64) (messaging.DeliveryStatus, error)
+
+// Poll implements Poller interface.
+func (f PollerFunc) Poll(messageId int64) (messaging.DeliveryStatus, error) {
+	return f(messageId)
+}
+
+// SmsPoller returns a poller of the SMS message statuses.
+func SmsPoller(client *sms.Client) Poller {
+	return PollerFunc(func(messageId int64) (messaging.DeliveryStatus, error) {
+		status, err := client.GetMessageStatus(messageId)
+		if err != nil {
+			return messaging.Unknown, err
+		}
+
+		return messaging.FromSmsStatus(status), nil
+	})
+}
+
+// ViberPoller returns a poller of the Viber message statuses.
+func ViberPoller(client *viber.Client) Poller {
+	return PollerFunc(func(messageId int64) (messaging.DeliveryStatus, error) {
+		receipt, err := client.GetMessageStatus(messageId)
+		if err != nil {
+			return messaging.Unknown, err
+		}
+
+		return messaging.FromViberStatus(receipt.Status), nil
+	})
+}
+
+// ViberSmsPoller returns a poller of the Viber plus SMS message statuses.
+// If the message was not delivered through Viber and the SMS was sent instead,
+// the status of the SMS message is reported.
+func ViberSmsPoller(client *viberplussms.Client) Poller {
+	return PollerFunc(func(messageId int64) (messaging.DeliveryStatus, error) {
+		receipt, err := client.GetMessageStatus(messageId)
+		if err != nil {
+			return messaging.Unknown, err
+		}
+
+		return messaging.FromViberSmsReceipt(receipt), nil
+	})
+}
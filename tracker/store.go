@@ -0,0 +1,151 @@
+package tracker
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+)
+
+// Key identifies the tracked message.
+type Key struct {
+	Channel   messaging.Channel `json:"channel"`
+	MessageId int64             `json:"message_id"`
+}
+
+// Item represents a tracked message.
+type Item struct {
+	Key
+	Status     messaging.DeliveryStatus `json:"status"`       // Status is the last known message status.
+	Polls      int                      `json:"polls"`        // Polls is a number of status requests made so far.
+	StartedAt  time.Time                `json:"started_at"`   // StartedAt is a time when tracking started.
+	Deadline   time.Time                `json:"deadline"`     // Deadline is a time when tracking stops if the status is still not terminal.
+	NextPollAt time.Time                `json:"next_poll_at"` // NextPollAt is a time of the next status request.
+}
+
+// Store persists tracked messages, so tracking survives restarts.
+type Store interface {
+	// Save inserts or replaces the tracked message.
+	Save(item *Item) error
+	// Delete removes the message which is not tracked anymore.
+	Delete(key Key) error
+	// Load returns all tracked messages.
+	Load() ([]*Item, error)
+}
+
+// MemoryStore is a Store which keeps tracked messages in memory.
+type MemoryStore struct {
+	mu    sync.Mutex
+	items map[Key]Item
+}
+
+// NewMemoryStore creates new in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{items: make(map[Key]Item)}
+}
+
+// Save implements Store interface.
+func (s *MemoryStore) Save(item *Item) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items[item.Key] = *item
+	return nil
+}
+
+// Delete implements Store interface.
+func (s *MemoryStore) Delete(key Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.items, key)
+	return nil
+}
+
+// Load implements Store interface.
+func (s *MemoryStore) Load() ([]*Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	items := make([]*Item, 0, len(s.items))
+	for _, item := range s.items {
+		item := item
+		items = append(items, &item)
+	}
+
+	return items, nil
+}
+
+// FileStore is a Store which keeps tracked messages in a JSON file.
+// The whole file is rewritten on every change, so it suits moderate numbers of tracked messages.
+type FileStore struct {
+	mu     sync.Mutex
+	path   string
+	memory *MemoryStore
+}
+
+// OpenFileStore opens (or creates) the file store at the given path.
+func OpenFileStore(path string) (*FileStore, error) {
+	store := &FileStore{path: path, memory: NewMemoryStore()}
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var items []Item
+	if err := json.Unmarshal(content, &items); err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		store.memory.items[item.Key] = item
+	}
+
+	return store, nil
+}
+
+// Save implements Store interface.
+func (s *FileStore) Save(item *Item) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.memory.Save(item)
+	return s.flush()
+}
+
+// Delete implements Store interface.
+func (s *FileStore) Delete(key Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.memory.Delete(key)
+	return s.flush()
+}
+
+// Load implements Store interface.
+func (s *FileStore) Load() ([]*Item, error) {
+	return s.memory.Load()
+}
+
+func (s *FileStore) flush() error {
+	items, _ := s.memory.Load()
+	content, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+
+	// write to the temporary file first, so the crash during the write does not corrupt the store
+	tmp := filepath.Join(filepath.Dir(s.path), "."+filepath.Base(s.path)+".tmp")
+	if err := os.WriteFile(tmp, content, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,463 @@
+// Package tracker contains a tracker which polls message statuses until they reach a terminal state.
+package tracker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/reconcile"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/store"
+)
+
+const (
+	defaultMinInterval = 5 * time.Second
+	defaultMaxInterval = 5 * time.Minute
+	defaultTimeout     = 24 * time.Hour
+	eventsBufferSize   = 100
+)
+
+// ErrNotTracked is returned when the message is not tracked by the tracker.
+var ErrNotTracked = errors.New("tracker: message is not tracked")
+
+// Event represents a status change of the tracked message.
+type Event struct {
+	Key
+	Status   messaging.DeliveryStatus // Status is a new message status.
+	Previous messaging.DeliveryStatus // Previous is a previous message status.
+	TimedOut bool                     // TimedOut is true if tracking stopped because the status was not terminal in time.
+	Err      error                    // Err is an error of the last status request (for timed out events).
+	At       time.Time                // At is a time when the change was noticed.
+}
+
+// Final returns true if the message is not tracked after this event.
+func (e Event) Final() bool {
+	return e.Status.IsTerminal() || e.TimedOut
+}
+
+// Tracker polls statuses of the tracked messages with backoff until they become terminal
+// or tracking times out. Tracked messages are saved to the store and restored by Start.
+type Tracker struct {
+	MinInterval time.Duration    // MinInterval is a delay before the first status request (5 seconds by default).
+	MaxInterval time.Duration    // MaxInterval is a maximum delay between status requests (5 minutes by default).
+	Timeout     time.Duration    // Timeout is a maximum tracking time of the message (24 hours by default).
+	OnEvent     func(e Event)    // OnEvent is called on every status change.
+	OnError     func(err error)  // OnError is called when saving the tracked message or its status fails.
+	Now         func() time.Time // Now returns current time (time.Now by default).
+
+	store    Store
+	pollers  map[messaging.Channel]Poller
+	messages store.MessageStore
+
+	mu      sync.Mutex
+	items   map[Key]*Item
+	waiters map[Key][]chan Event
+	events  chan Event
+	wake    chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// New creates new tracker which persists tracked messages to the given store.
+func New(store Store) *Tracker {
+	return &Tracker{
+		store:   store,
+		pollers: make(map[messaging.Channel]Poller),
+		items:   make(map[Key]*Item),
+		waiters: make(map[Key][]chan Event),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// SetPoller sets the poller used to get statuses of messages sent through the channel.
+func (t *Tracker) SetPoller(channel messaging.Channel, poller Poller) *Tracker {
+	t.pollers[channel] = poller
+	return t
+}
+
+// SetMessageStore sets the store where polled status changes are recorded.
+func (t *Tracker) SetMessageStore(messages store.MessageStore) *Tracker {
+	t.messages = messages
+	return t
+}
+
+// Events returns the channel of status change events. Once it is called, the channel
+// must be read continuously, otherwise polling stops when the channel buffer is full.
+func (t *Tracker) Events() <-chan Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.events == nil {
+		t.events = make(chan Event, eventsBufferSize)
+	}
+
+	return t.events
+}
+
+// Start restores tracked messages from the store and starts polling.
+func (t *Tracker) Start(ctx context.Context) error {
+	items, err := t.store.Load()
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.cancel != nil {
+		return errors.New("tracker: already started")
+	}
+
+	for _, item := range items {
+		if _, ok := t.items[item.Key]; !ok {
+			t.items[item.Key] = item
+		}
+	}
+
+	ctx, t.cancel = context.WithCancel(ctx)
+	t.done = make(chan struct{})
+	go t.run(ctx, t.done)
+
+	return nil
+}
+
+// Stop stops polling. Tracked messages stay in the store.
+func (t *Tracker) Stop() {
+	t.mu.Lock()
+	cancel, done := t.cancel, t.done
+	t.cancel, t.done = nil, nil
+	t.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// Track starts tracking of the message sent through the channel.
+func (t *Tracker) Track(channel messaging.Channel, messageId int64) error {
+	return t.track(Key{Channel: channel, MessageId: messageId}, nil)
+}
+
+// track starts tracking of the message and registers the waiter (if any) under the same lock,
+// so the final event can't be sent before the waiter is registered.
+func (t *Tracker) track(key Key, waiter chan Event) error {
+	if _, ok := t.pollers[key.Channel]; !ok {
+		return fmt.Errorf("tracker: no poller for channel '%s'", key.Channel)
+	}
+
+	now := t.now()
+	item := &Item{
+		Key:        key,
+		StartedAt:  now,
+		Deadline:   now.Add(t.timeout()),
+		NextPollAt: now.Add(t.minInterval()),
+	}
+
+	t.mu.Lock()
+	_, tracked := t.items[key]
+	if tracked && waiter != nil {
+		t.waiters[key] = append(t.waiters[key], waiter)
+	}
+	t.mu.Unlock()
+	if tracked {
+		return nil
+	}
+
+	// the item is saved before it is polled, so finishing it can't be followed by the save
+	if err := t.store.Save(item); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	if _, ok := t.items[key]; !ok {
+		t.items[key] = item
+	}
+	if waiter != nil {
+		t.waiters[key] = append(t.waiters[key], waiter)
+	}
+	t.mu.Unlock()
+
+	t.notify()
+	return nil
+}
+
+// Status returns the last known status of the tracked message.
+func (t *Tracker) Status(channel messaging.Channel, messageId int64) (messaging.DeliveryStatus, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	item, ok := t.items[Key{Channel: channel, MessageId: messageId}]
+	if !ok {
+		return messaging.Unknown, ErrNotTracked
+	}
+
+	return item.Status, nil
+}
+
+// Wait blocks until the tracked message reaches a terminal status or tracking times out,
+// and returns the final event.
+func (t *Tracker) Wait(ctx context.Context, channel messaging.Channel, messageId int64) (Event, error) {
+	key := Key{Channel: channel, MessageId: messageId}
+	waiter := make(chan Event, 1)
+
+	t.mu.Lock()
+	if _, ok := t.items[key]; !ok {
+		t.mu.Unlock()
+		return Event{}, ErrNotTracked
+	}
+	t.waiters[key] = append(t.waiters[key], waiter)
+	t.mu.Unlock()
+
+	return t.wait(ctx, key, waiter)
+}
+
+// SendAndWait sends the message using the send function, tracks it and blocks until
+// it reaches a terminal status or tracking times out. It returns the message id and the final event.
+func (t *Tracker) SendAndWait(ctx context.Context, channel messaging.Channel, send func() (int64, error)) (int64, Event, error) {
+	messageId, err := send()
+	if err != nil {
+		return messageId, Event{}, err
+	}
+
+	key := Key{Channel: channel, MessageId: messageId}
+	waiter := make(chan Event, 1)
+	if err := t.track(key, waiter); err != nil {
+		return messageId, Event{}, err
+	}
+
+	event, err := t.wait(ctx, key, waiter)
+	return messageId, event, err
+}
+
+func (t *Tracker) wait(ctx context.Context, key Key, waiter chan Event) (Event, error) {
+	select {
+	case event := <-waiter:
+		return event, nil
+	case <-ctx.Done():
+		t.removeWaiter(key, waiter)
+		return Event{}, ctx.Err()
+	}
+}
+
+func (t *Tracker) run(ctx context.Context, done chan struct{}) {
+	defer close(done)
+
+	for {
+		due, next := t.dueItems()
+		for _, item := range due {
+			if ctx.Err() != nil {
+				return
+			}
+			t.poll(ctx, item)
+		}
+
+		if len(due) > 0 {
+			continue
+		}
+
+		var timer <-chan time.Time
+		if !next.IsZero() {
+			delay := next.Sub(t.now())
+			if delay < 0 {
+				delay = 0
+			}
+			timer = time.After(delay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.wake:
+		case <-timer:
+		}
+	}
+}
+
+// dueItems returns copies of the items which should be polled now and the time of the next poll.
+func (t *Tracker) dueItems() ([]Item, time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	var due []Item
+	var next time.Time
+	for _, item := range t.items {
+		if !item.NextPollAt.After(now) {
+			due = append(due, *item)
+		} else if next.IsZero() || item.NextPollAt.Before(next) {
+			next = item.NextPollAt
+		}
+	}
+
+	return due, next
+}
+
+func (t *Tracker) poll(ctx context.Context, item Item) {
+	poller, ok := t.pollers[item.Channel]
+	if !ok {
+		t.finish(ctx, item, Event{Key: item.Key, Status: item.Status, Previous: item.Status, TimedOut: true,
+			Err: fmt.Errorf("tracker: no poller for channel '%s'", item.Channel), At: t.now()})
+		return
+	}
+
+	status, err := poller.Poll(item.MessageId)
+	now := t.now()
+	item.Polls++
+
+	if err == nil && status != item.Status {
+		event := Event{Key: item.Key, Status: status, Previous: item.Status, At: now}
+		item.Status = status
+		t.recordStatus(event)
+		if status.IsTerminal() {
+			t.finish(ctx, item, event)
+			return
+		}
+		t.emit(ctx, event)
+	}
+
+	if !now.Before(item.Deadline) {
+		t.finish(ctx, item, Event{Key: item.Key, Status: item.Status, Previous: item.Status, TimedOut: true, Err: err, At: now})
+		return
+	}
+
+	item.NextPollAt = now.Add(t.interval(item.Polls))
+
+	t.mu.Lock()
+	if _, ok := t.items[item.Key]; ok {
+		t.items[item.Key] = &item
+	}
+	t.mu.Unlock()
+
+	// the item is polled from memory, the store is behind until the next successful save
+	if err := t.store.Save(&item); err != nil {
+		t.reportError(err)
+	}
+}
+
+func (t *Tracker) finish(ctx context.Context, item Item, event Event) {
+	t.mu.Lock()
+	delete(t.items, item.Key)
+	waiters := t.waiters[item.Key]
+	delete(t.waiters, item.Key)
+	t.mu.Unlock()
+
+	if err := t.store.Delete(item.Key); err != nil {
+		t.reportError(err)
+	}
+
+	for _, waiter := range waiters {
+		waiter <- event
+	}
+
+	t.emit(ctx, event)
+}
+
+func (t *Tracker) recordStatus(event Event) {
+	if t.messages == nil {
+		return
+	}
+
+	// the record keeps its own status, so a failed update is fixed by the next status change
+	_, err := t.messages.UpdateStatus(reconcile.Observation{
+		Key:    reconcile.Key{Channel: event.Channel, MessageId: event.MessageId},
+		Status: event.Status,
+		Source: reconcile.Polling,
+		At:     event.At,
+	})
+	if err != nil {
+		t.reportError(err)
+	}
+}
+
+func (t *Tracker) reportError(err error) {
+	if t.OnError != nil {
+		t.OnError(err)
+	}
+}
+
+func (t *Tracker) emit(ctx context.Context, event Event) {
+	if t.OnEvent != nil {
+		t.OnEvent(event)
+	}
+
+	t.mu.Lock()
+	events := t.events
+	t.mu.Unlock()
+
+	if events != nil {
+		select {
+		case events <- event:
+		case <-ctx.Done():
+		}
+	}
+}
+
+func (t *Tracker) removeWaiter(key Key, waiter chan Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	waiters := t.waiters[key]
+	for i, w := range waiters {
+		if w == waiter {
+			t.waiters[key] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+}
+
+func (t *Tracker) notify() {
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// interval returns the delay before the next poll, it doubles with every poll.
+func (t *Tracker) interval(polls int) time.Duration {
+	interval := t.minInterval()
+	for i := 1; i < polls && interval < t.maxInterval(); i++ {
+		interval *= 2
+	}
+
+	if interval > t.maxInterval() {
+		interval = t.maxInterval()
+	}
+
+	return interval
+}
+
+func (t *Tracker) minInterval() time.Duration {
+	if t.MinInterval <= 0 {
+		return defaultMinInterval
+	}
+
+	return t.MinInterval
+}
+
+func (t *Tracker) maxInterval() time.Duration {
+	if t.MaxInterval <= 0 {
+		return defaultMaxInterval
+	}
+
+	return t.MaxInterval
+}
+
+func (t *Tracker) timeout() time.Duration {
+	if t.Timeout <= 0 {
+		return defaultTimeout
+	}
+
+	return t.Timeout
+}
+
+func (t *Tracker) now() time.Time {
+	if t.Now != nil {
+		return t.Now()
+	}
+
+	return time.Now()
+}
@@ -0,0 +1,303 @@
+package tracker_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/messaging"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/reconcile"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/store"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/tracker"
+	viberplussms "github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+	"github.com/jarcoal/httpmock"
+)
+
+// scriptedPoller returns statuses one by one, repeating the last one.
+func scriptedPoller(statuses ...messaging.DeliveryStatus) tracker.Poller {
+	var mu sync.Mutex
+	return tracker.PollerFunc(func(messageId int64) (messaging.DeliveryStatus, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		status := statuses[0]
+		if len(statuses) > 1 {
+			statuses = statuses[1:]
+		}
+		return status, nil
+	})
+}
+
+func newTracker(store tracker.Store, poller tracker.Poller) *tracker.Tracker {
+	tr := tracker.New(store).SetPoller(messaging.Viber, poller)
+	tr.MinInterval = time.Millisecond
+	tr.MaxInterval = 5 * time.Millisecond
+	return tr
+}
+
+func TestSendAndWait(t *testing.T) {
+	var inputData = []struct {
+		name             string
+		statuses         []messaging.DeliveryStatus
+		timeout          time.Duration
+		expectedStatus   messaging.DeliveryStatus
+		expectedTimedOut bool
+		expectedEvents   int
+	}{
+		{"delivered", []messaging.DeliveryStatus{messaging.Sent, messaging.Sent, messaging.Pending, messaging.Delivered}, time.Minute, messaging.Delivered, false, 3},
+		{"timed out", []messaging.DeliveryStatus{messaging.Sent, messaging.Pending}, 20 * time.Millisecond, messaging.Pending, true, 3},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var events []tracker.Event
+
+			tr := newTracker(tracker.NewMemoryStore(), scriptedPoller(input.statuses...))
+			tr.Timeout = input.timeout
+			tr.OnEvent = func(e tracker.Event) {
+				mu.Lock()
+				events = append(events, e)
+				mu.Unlock()
+			}
+
+			if err := tr.Start(context.Background()); err != nil {
+				t.Fatalf("FAIL. Unexpected error '%v'", err)
+			}
+			defer tr.Stop()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+
+			messageId, event, err := tr.SendAndWait(ctx, messaging.Viber, func() (int64, error) { return 429, nil })
+			if err != nil {
+				t.Fatalf("FAIL. Unexpected error '%v'", err)
+			}
+
+			if messageId != 429 || event.MessageId != 429 {
+				t.Errorf("FAIL. Expected messageId '%d', but got '%d' and '%d'", 429, messageId, event.MessageId)
+			}
+
+			if event.Status != input.expectedStatus || event.TimedOut != input.expectedTimedOut {
+				t.Errorf("FAIL. Expected final status '%s' (timed out: %t), but got '%+v'", input.expectedStatus, input.expectedTimedOut, event)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(events) != input.expectedEvents {
+				t.Errorf("FAIL. Expected %d events, but got '%+v'", input.expectedEvents, events)
+			}
+		})
+	}
+}
+
+// slowStore delays the first save of the message until it is polled or a timeout passes.
+type slowStore struct {
+	*tracker.MemoryStore
+	polled chan struct{}
+}
+
+func (s *slowStore) Save(item *tracker.Item) error {
+	if item.MessageId == 429 && item.Polls == 0 {
+		select {
+		case <-s.polled:
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	return s.MemoryStore.Save(item)
+}
+
+func TestSendAndWaitImmediateStatus(t *testing.T) {
+	slow := &slowStore{MemoryStore: tracker.NewMemoryStore(), polled: make(chan struct{}, 1)}
+	tr := newTracker(slow, tracker.PollerFunc(func(messageId int64) (messaging.DeliveryStatus, error) {
+		if messageId != 429 {
+			return messaging.Sent, nil
+		}
+
+		select {
+		case slow.polled <- struct{}{}:
+		default:
+		}
+		return messaging.Delivered, nil
+	}))
+
+	if err := tr.Start(context.Background()); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+	defer tr.Stop()
+
+	// another tracked message keeps polling running while the message is being tracked
+	if err := tr.Track(messaging.Viber, 1); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, event, err := tr.SendAndWait(ctx, messaging.Viber, func() (int64, error) { return 429, nil })
+	if err != nil || event.Status != messaging.Delivered {
+		t.Fatalf("FAIL. Expected delivered message, but got '%+v' (%v)", event, err)
+	}
+
+	tr.Stop()
+	items, _ := slow.Load()
+	if len(items) != 1 || items[0].MessageId != 1 {
+		t.Errorf("FAIL. Expected only the other message to stay tracked, but got %d items", len(items))
+	}
+}
+
+// failingStore fails to save polled messages and to delete finished ones.
+type failingStore struct {
+	*tracker.MemoryStore
+}
+
+func (s *failingStore) Save(item *tracker.Item) error {
+	if item.Polls > 0 {
+		return errors.New("disk is full")
+	}
+
+	return s.MemoryStore.Save(item)
+}
+
+func (s *failingStore) Delete(key tracker.Key) error {
+	return errors.New("disk is full")
+}
+
+func TestReportStoreErrors(t *testing.T) {
+	var mu sync.Mutex
+	var errs []error
+	tr := newTracker(&failingStore{MemoryStore: tracker.NewMemoryStore()}, scriptedPoller(messaging.Sent, messaging.Delivered))
+	tr.OnError = func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}
+
+	if err := tr.Start(context.Background()); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+	defer tr.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if _, _, err := tr.SendAndWait(ctx, messaging.Viber, func() (int64, error) { return 429, nil }); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// the failed save after the first poll and the failed delete after the last one
+	if len(errs) != 2 {
+		t.Errorf("FAIL. Expected 2 reported errors, but got '%v'", errs)
+	}
+}
+
+func TestRecordPolledStatuses(t *testing.T) {
+	messages := store.NewMemoryStore()
+	tr := newTracker(tracker.NewMemoryStore(), scriptedPoller(messaging.Sent, messaging.Delivered)).SetMessageStore(messages)
+
+	if err := tr.Start(context.Background()); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+	defer tr.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	if _, _, err := tr.SendAndWait(ctx, messaging.Viber, func() (int64, error) { return 429, nil }); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	record, err := messages.Get(messaging.Viber, 429)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	if record.Status != messaging.Delivered || len(record.History) != 2 || record.History[0].Source != reconcile.Polling {
+		t.Errorf("FAIL. Expected delivered record with 2 polled transitions, but got '%+v'", record)
+	}
+}
+
+func TestTrackingSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tracker.json")
+
+	store, err := tracker.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	// track the message without starting the tracker, as if the process died right after it
+	tr := newTracker(store, scriptedPoller(messaging.Sent))
+	if err := tr.Track(messaging.Viber, 429); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	store, err = tracker.OpenFileStore(path)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	tr = newTracker(store, scriptedPoller(messaging.Pending, messaging.Delivered))
+	events := tr.Events()
+	if err := tr.Start(context.Background()); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+	defer tr.Stop()
+
+	var last tracker.Event
+	timeout := time.After(2 * time.Second)
+	for !last.Final() {
+		select {
+		case last = <-events:
+		case <-timeout:
+			t.Fatalf("FAIL. Message was not delivered in time")
+		}
+	}
+
+	if last.Status != messaging.Delivered || last.Previous != messaging.Pending {
+		t.Errorf("FAIL. Expected change from '%s' to '%s', but got '%+v'", messaging.Pending, messaging.Delivered, last)
+	}
+
+	items, _ := store.Load()
+	if len(items) != 0 {
+		t.Errorf("FAIL. Expected no tracked messages in the store, but got '%+v'", items)
+	}
+}
+
+func TestViberSmsPoller(t *testing.T) {
+	var inputData = []struct {
+		response       string
+		expectedStatus messaging.DeliveryStatus
+	}{
+		{`{"message_id":429,"status":1}`, messaging.Delivered},
+		{`{"message_id":429,"status":4}`, messaging.Sent},
+		{`{"message_id":429,"status":4,"sms_message_id":22}`, messaging.Sent},
+		{`{"message_id":429,"status":4,"sms_message_id":22,"sms_message_status":2}`, messaging.Delivered},
+	}
+
+	poller := tracker.ViberSmsPoller(viberplussms.NewClient(""))
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	for _, input := range inputData {
+		t.Run(input.response, func(t *testing.T) {
+			httpmock.RegisterResponder("POST", "https://web.it-decision.com/v1/api/receive-viber",
+				httpmock.NewStringResponder(200, input.response))
+
+			status, err := poller.Poll(429)
+			if err != nil {
+				t.Fatalf("FAIL. Unexpected error '%v'", err)
+			}
+
+			if status != input.expectedStatus {
+				t.Errorf("FAIL. Expected status '%s', but got '%s'", input.expectedStatus, status)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package viber
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber/internal"
+)
+
+// CallbackEvent represents a Viber message status callback sent to the message CallbackUrl.
+type CallbackEvent struct {
+	MessageId  int64         `json:"message_id"` // Id of the Viber message.
+	Status     MessageStatus `json:"status"`     // Viber message status.
+	ReceivedAt time.Time     `json:"-"`          // Time when the callback was received.
+}
+
+// CallbackHandler is an http.Handler which receives Viber message status callbacks.
+type CallbackHandler struct {
+	base internal.CallbackHandler
+}
+
+// NewCallbackHandler creates new handler of Viber message status callbacks.
+// The handle function is called for every valid callback. If it returns an error,
+// the handler responds with 500 status code, so the callback may be retried.
+func NewCallbackHandler(handle func(event *CallbackEvent) error) *CallbackHandler {
+	return &CallbackHandler{
+		base: internal.CallbackHandler{
+			Decode: func(body []byte) (interface{}, error) {
+				return DecodeCallbackEvent(body)
+			},
+			Dispatch: func(event interface{}) error {
+				return handle(event.(*CallbackEvent))
+			},
+		},
+	}
+}
+
+// SetSecurity sets the protection from forged callbacks. Requests rejected by it are answered with 403 status code.
+func (h *CallbackHandler) SetSecurity(security *CallbackSecurity) *CallbackHandler {
+	h.base.Verify = security.Verify
+	h.base.Claim = func(r *http.Request, event interface{}) (func(), error) {
+		e := event.(*CallbackEvent)
+		return security.Claim(r, e.MessageId, e.Status.String())
+	}
+	return h
+}
+
+// ServeHTTP implements http.Handler interface.
+func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.base.ServeHTTP(w, r)
+}
+
+// DecodeCallbackEvent decodes and validates the Viber message status callback payload.
+func DecodeCallbackEvent(body []byte) (*CallbackEvent, error) {
+	// the status pointer tells a missing status from the zero Sent status
+	var payload struct {
+		CallbackEvent
+		Status *MessageStatus `json:"status"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Status == nil {
+		return nil, errors.New("status is required")
+	}
+
+	event := payload.CallbackEvent
+	event.Status = *payload.Status
+	if err := ValidateCallbackStatus(event.MessageId, event.Status); err != nil {
+		return nil, err
+	}
+
+	event.ReceivedAt = time.Now()
+	return &event, nil
+}
+
+// ValidateCallbackStatus returns an error if the message id or the status of the callback is invalid.
+func ValidateCallbackStatus(messageId int64, status MessageStatus) error {
+	if messageId <= 0 {
+		return errors.New("message_id is required")
+	}
+
+	if _, ok := messageStatusNames.Name(int64(status)); !ok {
+		return fmt.Errorf("unknown status %d", status)
+	}
+
+	return nil
+}
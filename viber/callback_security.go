@@ -0,0 +1,329 @@
+package viber
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	callbackTimestampParam = "dt_ts"
+	callbackNonceParam     = "dt_nonce"
+	callbackSignatureParam = "dt_sig"
+	maxCallbackClockSkew   = 5 * time.Minute
+	defaultCallbackMaxAge  = 48 * time.Hour
+	callbackSweepInterval  = time.Minute
+)
+
+// CallbackSecurity protects callback handlers from forged requests.
+//
+// SignCallbackUrl adds the issue time, a random nonce and the HMAC signature to the callback URL set to the message,
+// and the handler accepts only requests to the URLs signed with one of the secrets, not older than
+// MaxAge and coming from the allowed networks.
+//
+// The URL of every message must be signed separately: status callbacks to a signed URL are accepted for one message only
+// (the one passed to BindMessage or, if it was not called, the one of the first callback), and a callback
+// with the same status is accepted once. Received callbacks are remembered in memory until their URLs expire.
+type CallbackSecurity struct {
+	// Secrets are HMAC keys. New URLs are signed with the first secret, while URLs signed with
+	// any of them are accepted, so the secret can be rotated by prepending the new one and
+	// removing the old one once all messages signed with it have expired.
+	Secrets []string
+	// MaxAge is a replay window: callbacks to URLs signed earlier are rejected (48 hours by default).
+	// It should be longer than the message validity period.
+	MaxAge time.Duration
+	// AllowedNetworks limits addresses callbacks may come from (any address by default).
+	AllowedNetworks []*net.IPNet
+	// TrustForwardedFor should be true if the handler is behind a reverse proxy which sets
+	// the X-Forwarded-For header. The last address of the header is checked then.
+	TrustForwardedFor bool
+	// Now returns current time (time.Now by default).
+	Now func() time.Time
+
+	mu       sync.Mutex
+	bound    map[string]callbackBinding // message ids by URL nonces
+	received map[string]time.Time       // expiry times by signatures and statuses of received callbacks
+	sweepAt  time.Time
+}
+
+type callbackBinding struct {
+	messageId int64
+	expiresAt time.Time
+}
+
+// NewCallbackSecurity creates new callback security with the given secrets, the first one signs new URLs.
+func NewCallbackSecurity(secrets ...string) *CallbackSecurity {
+	return &CallbackSecurity{Secrets: secrets}
+}
+
+// AllowNetworks adds networks (like "203.0.113.0/24") or single addresses (like "203.0.113.7") to the allowlist.
+func (s *CallbackSecurity) AllowNetworks(networks ...string) error {
+	for _, network := range networks {
+		if !strings.Contains(network, "/") {
+			if ip := net.ParseIP(network); ip != nil && ip.To4() != nil {
+				network += "/32"
+			} else {
+				network += "/128"
+			}
+		}
+
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			return err
+		}
+
+		s.AllowedNetworks = append(s.AllowedNetworks, ipNet)
+	}
+
+	return nil
+}
+
+// SignCallbackUrl returns the callback URL with the issue time and the signature added.
+// Set the returned URL as the message CallbackUrl.
+func (s *CallbackSecurity) SignCallbackUrl(callbackUrl string) (string, error) {
+	if len(s.Secrets) == 0 {
+		return "", errors.New("callback security: no secrets configured")
+	}
+
+	u, err := url.Parse(callbackUrl)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	query.Del(callbackSignatureParam)
+	query.Set(callbackTimestampParam, strconv.FormatInt(s.now().Unix(), 10))
+	query.Set(callbackNonceParam, hex.EncodeToString(nonce))
+	query.Set(callbackSignatureParam, sign(s.Secrets[0], u.Path, query))
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// Verify returns an error if the callback request is not allowed.
+func (s *CallbackSecurity) Verify(r *http.Request) error {
+	if len(s.AllowedNetworks) > 0 {
+		if err := s.verifyAddress(r); err != nil {
+			return err
+		}
+	}
+
+	if len(s.Secrets) == 0 {
+		return nil
+	}
+
+	query := r.URL.Query()
+	signature := query.Get(callbackSignatureParam)
+	if signature == "" {
+		return errors.New("callback signature is missing")
+	}
+
+	valid := false
+	for _, secret := range s.Secrets {
+		if hmac.Equal([]byte(signature), []byte(sign(secret, r.URL.Path, query))) {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return errors.New("callback signature is invalid")
+	}
+
+	timestamp, err := strconv.ParseInt(query.Get(callbackTimestampParam), 10, 64)
+	if err != nil {
+		return errors.New("callback timestamp is invalid")
+	}
+
+	issuedAt := time.Unix(timestamp, 0)
+	now := s.now()
+	if issuedAt.After(now.Add(maxCallbackClockSkew)) {
+		return errors.New("callback timestamp is in the future")
+	}
+	if now.Sub(issuedAt) > s.maxAge() {
+		return errors.New("callback URL has expired")
+	}
+
+	return nil
+}
+
+// BindMessage binds the signed callback URL to the id of the message it was set to, so callbacks of other messages
+// to the URL are rejected. Call it once the message is sent, otherwise the URL is bound by its first callback.
+func (s *CallbackSecurity) BindMessage(callbackUrl string, messageId int64) error {
+	u, err := url.Parse(callbackUrl)
+	if err != nil {
+		return err
+	}
+
+	query := u.Query()
+	nonce := query.Get(callbackNonceParam)
+	if nonce == "" {
+		return errors.New("callback nonce is missing")
+	}
+	timestamp, err := strconv.ParseInt(query.Get(callbackTimestampParam), 10, 64)
+	if err != nil {
+		return errors.New("callback timestamp is invalid")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err = s.bind(nonce, messageId, time.Unix(timestamp, 0).Add(s.maxAge()))
+	return err
+}
+
+// Claim checks the status callback of the message to the verified request is neither a replay nor sent to the URL
+// of another message and remembers it. The returned function forgets the callback, so it is accepted again
+// if it could not be processed.
+func (s *CallbackSecurity) Claim(r *http.Request, messageId int64, status string) (func(), error) {
+	if len(s.Secrets) == 0 {
+		return func() {}, nil
+	}
+
+	query := r.URL.Query()
+	nonce := query.Get(callbackNonceParam)
+	if nonce == "" {
+		return nil, errors.New("callback nonce is missing")
+	}
+	timestamp, err := strconv.ParseInt(query.Get(callbackTimestampParam), 10, 64)
+	if err != nil {
+		return nil, errors.New("callback timestamp is invalid")
+	}
+	expiresAt := time.Unix(timestamp, 0).Add(s.maxAge())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(s.now())
+	key := query.Get(callbackSignatureParam) + "/" + status
+	if _, ok := s.received[key]; ok {
+		return nil, errors.New("callback was already received")
+	}
+
+	bound, err := s.bind(nonce, messageId, expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	s.received[key] = expiresAt
+
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		delete(s.received, key)
+		if bound {
+			delete(s.bound, nonce)
+		}
+	}, nil
+}
+
+func (s *CallbackSecurity) verifyAddress(r *http.Request) error {
+	address := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+
+	if s.TrustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			addresses := strings.Split(forwarded, ",")
+			address = strings.TrimSpace(addresses[len(addresses)-1])
+		}
+	}
+
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return fmt.Errorf("callback address '%s' is invalid", address)
+	}
+
+	for _, network := range s.AllowedNetworks {
+		if network.Contains(ip) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("callback address '%s' is not allowed", address)
+}
+
+// bind binds the URL nonce to the message id and returns true if it was not bound yet. It must be called with mu held.
+func (s *CallbackSecurity) bind(nonce string, messageId int64, expiresAt time.Time) (bool, error) {
+	if binding, ok := s.bound[nonce]; ok {
+		if binding.messageId != messageId {
+			return false, fmt.Errorf("callback URL is bound to another message than %d", messageId)
+		}
+		return false, nil
+	}
+
+	if s.bound == nil {
+		s.bound = make(map[string]callbackBinding)
+		s.received = make(map[string]time.Time)
+	}
+	s.bound[nonce] = callbackBinding{messageId: messageId, expiresAt: expiresAt}
+	return true, nil
+}
+
+// sweep forgets bindings and callbacks of expired URLs, at most once per callbackSweepInterval.
+// It must be called with mu held.
+func (s *CallbackSecurity) sweep(now time.Time) {
+	if now.Before(s.sweepAt) {
+		return
+	}
+	s.sweepAt = now.Add(callbackSweepInterval)
+
+	for nonce, binding := range s.bound {
+		if now.After(binding.expiresAt) {
+			delete(s.bound, nonce)
+		}
+	}
+	for key, expiresAt := range s.received {
+		if now.After(expiresAt) {
+			delete(s.received, key)
+		}
+	}
+}
+
+func (s *CallbackSecurity) maxAge() time.Duration {
+	if s.MaxAge <= 0 {
+		return defaultCallbackMaxAge
+	}
+
+	return s.MaxAge
+}
+
+func (s *CallbackSecurity) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+
+	return time.Now()
+}
+
+// sign returns the HMAC of the URL path and the query parameters except the signature itself.
+func sign(secret string, path string, query url.Values) string {
+	signed := url.Values{}
+	for key, values := range query {
+		if key != callbackSignatureParam {
+			signed[key] = values
+		}
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(path))
+	mac.Write([]byte{'?'})
+	mac.Write([]byte(signed.Encode()))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
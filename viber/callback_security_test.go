@@ -0,0 +1,152 @@
+package viber_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+)
+
+func TestCallbackSecurity(t *testing.T) {
+	issuedAt := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	var inputData = []struct {
+		name            string
+		signSecret      string
+		secrets         []string
+		tamper          func(u *url.URL)
+		receivedAfter   time.Duration
+		remoteAddr      string
+		forwardedFor    string
+		allowedNetworks []string
+		expectedCode    int
+	}{
+		{"signed", "s1", []string{"s1"}, nil, time.Hour, "198.51.100.1:4000", "", nil, http.StatusOK},
+		{"rotated secret", "old", []string{"new", "old"}, nil, time.Hour, "198.51.100.1:4000", "", nil, http.StatusOK},
+		{"removed secret", "old", []string{"new"}, nil, time.Hour, "198.51.100.1:4000", "", nil, http.StatusForbidden},
+		{"missing signature", "s1", []string{"s1"}, func(u *url.URL) { u.RawQuery = "" }, time.Hour, "198.51.100.1:4000", "", nil, http.StatusForbidden},
+		{"forged message", "s1", []string{"s1"}, func(u *url.URL) {
+			q := u.Query()
+			q.Set("order", "2")
+			u.RawQuery = q.Encode()
+		}, time.Hour, "198.51.100.1:4000", "", nil, http.StatusForbidden},
+		{"forged timestamp", "s1", []string{"s1"}, func(u *url.URL) {
+			q := u.Query()
+			q.Set("dt_ts", "1")
+			u.RawQuery = q.Encode()
+		}, time.Hour, "198.51.100.1:4000", "", nil, http.StatusForbidden},
+		{"expired", "s1", []string{"s1"}, nil, 49 * time.Hour, "198.51.100.1:4000", "", nil, http.StatusForbidden},
+		{"issued in future", "s1", []string{"s1"}, nil, -time.Hour, "198.51.100.1:4000", "", nil, http.StatusForbidden},
+		{"allowed address", "s1", []string{"s1"}, nil, time.Hour, "198.51.100.1:4000", "", []string{"198.51.100.0/24"}, http.StatusOK},
+		{"denied address", "s1", []string{"s1"}, nil, time.Hour, "203.0.113.9:4000", "", []string{"198.51.100.0/24"}, http.StatusForbidden},
+		{"forwarded address", "s1", []string{"s1"}, nil, time.Hour, "10.0.0.1:4000", "203.0.113.9, 198.51.100.1", []string{"198.51.100.1"}, http.StatusOK},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			signer := viber.NewCallbackSecurity(input.signSecret)
+			signer.Now = func() time.Time { return issuedAt }
+
+			signedUrl, err := signer.SignCallbackUrl("https://yourdomain.com/viber-callback?order=1")
+			if err != nil {
+				t.Fatalf("FAIL. Unexpected error '%v'", err)
+			}
+
+			u, _ := url.Parse(signedUrl)
+			if input.tamper != nil {
+				input.tamper(u)
+			}
+
+			security := viber.NewCallbackSecurity(input.secrets...)
+			security.MaxAge = 48 * time.Hour
+			security.TrustForwardedFor = input.forwardedFor != ""
+			security.Now = func() time.Time { return issuedAt.Add(input.receivedAfter) }
+			if err := security.AllowNetworks(input.allowedNetworks...); err != nil {
+				t.Fatalf("FAIL. Unexpected error '%v'", err)
+			}
+
+			handled := false
+			handler := viber.NewCallbackHandler(func(event *viber.CallbackEvent) error {
+				handled = true
+				return nil
+			}).SetSecurity(security)
+
+			request := httptest.NewRequest(http.MethodPost, u.String(), strings.NewReader(`{"message_id":429,"status":1}`))
+			request.RemoteAddr = input.remoteAddr
+			if input.forwardedFor != "" {
+				request.Header.Set("X-Forwarded-For", input.forwardedFor)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+
+			if recorder.Code != input.expectedCode {
+				t.Errorf("FAIL. Expected response code '%d', but got '%d' (%s)", input.expectedCode, recorder.Code, recorder.Body.String())
+			}
+
+			if handled != (input.expectedCode == http.StatusOK) {
+				t.Errorf("FAIL. Expected callback to be handled: %t", input.expectedCode == http.StatusOK)
+			}
+		})
+	}
+}
+
+func TestCallbackReplay(t *testing.T) {
+	issuedAt := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	now := issuedAt
+	security := viber.NewCallbackSecurity("s1")
+	security.Now = func() time.Time { return now }
+
+	signedUrl, err := security.SignCallbackUrl("https://yourdomain.com/viber-callback")
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+	otherUrl, _ := security.SignCallbackUrl("https://yourdomain.com/viber-callback")
+	if otherUrl == signedUrl {
+		t.Fatalf("FAIL. Expected every signed URL to be unique")
+	}
+	if err := security.BindMessage(otherUrl, 430); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	handleError := error(nil)
+	handler := viber.NewCallbackHandler(func(event *viber.CallbackEvent) error {
+		return handleError
+	}).SetSecurity(security)
+
+	var inputData = []struct {
+		name          string
+		callbackUrl   string
+		body          string
+		receivedAfter time.Duration
+		handleError   error
+		expectedCode  int
+	}{
+		{"first callback", signedUrl, `{"message_id":429,"status":0}`, time.Minute, nil, http.StatusOK},
+		{"next status", signedUrl, `{"message_id":429,"status":1}`, time.Hour, nil, http.StatusOK},
+		{"replayed status", signedUrl, `{"message_id":429,"status":1}`, 2 * time.Hour, nil, http.StatusForbidden},
+		{"another message", signedUrl, `{"message_id":777,"status":1}`, 2 * time.Hour, nil, http.StatusForbidden},
+		{"bound to another message", otherUrl, `{"message_id":429,"status":3}`, 2 * time.Hour, nil, http.StatusForbidden},
+		{"failed handler", otherUrl, `{"message_id":430,"status":1}`, 2 * time.Hour, errors.New("database is down"), http.StatusInternalServerError},
+		{"retried after failure", otherUrl, `{"message_id":430,"status":1}`, 3 * time.Hour, nil, http.StatusOK},
+		{"expired by default", signedUrl, `{"message_id":429,"status":4}`, 49 * time.Hour, nil, http.StatusForbidden},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			now = issuedAt.Add(input.receivedAfter)
+			handleError = input.handleError
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, input.callbackUrl, strings.NewReader(input.body)))
+			if recorder.Code != input.expectedCode {
+				t.Errorf("FAIL. Expected response code '%d', but got '%d' (%s)", input.expectedCode, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
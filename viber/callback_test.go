@@ -0,0 +1,58 @@
+package viber_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+)
+
+func TestCallbackHandler(t *testing.T) {
+	var inputData = []struct {
+		name          string
+		method        string
+		body          string
+		handleError   error
+		expectedCode  int
+		expectedEvent *viber.CallbackEvent
+	}{
+		{"delivered", http.MethodPost, `{"message_id":429,"status":1}`, nil, http.StatusOK, &viber.CallbackEvent{MessageId: 429, Status: viber.Delivered}},
+		{"status name", http.MethodPost, `{"message_id":429,"status":"Rejected"}`, nil, http.StatusOK, &viber.CallbackEvent{MessageId: 429, Status: viber.Rejected}},
+		{"wrong method", http.MethodGet, ``, nil, http.StatusMethodNotAllowed, nil},
+		{"malformed payload", http.MethodPost, `{"message_id":`, nil, http.StatusBadRequest, nil},
+		{"missing message id", http.MethodPost, `{"status":1}`, nil, http.StatusBadRequest, nil},
+		{"unknown status", http.MethodPost, `{"message_id":429,"status":17}`, nil, http.StatusBadRequest, nil},
+		{"missing status", http.MethodPost, `{"message_id":429}`, nil, http.StatusBadRequest, nil},
+		{"null status", http.MethodPost, `{"message_id":429,"status":null}`, nil, http.StatusBadRequest, nil},
+		{"handler error", http.MethodPost, `{"message_id":429,"status":0}`, errors.New("database is down"), http.StatusInternalServerError, &viber.CallbackEvent{MessageId: 429, Status: viber.Sent}},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			var received *viber.CallbackEvent
+			handler := viber.NewCallbackHandler(func(event *viber.CallbackEvent) error {
+				received = event
+				return input.handleError
+			})
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(input.method, "/viber-callback", strings.NewReader(input.body)))
+
+			if recorder.Code != input.expectedCode {
+				t.Errorf("FAIL. Expected response code '%d', but got '%d'", input.expectedCode, recorder.Code)
+			}
+
+			if (received == nil) != (input.expectedEvent == nil) ||
+				(received != nil && (received.MessageId != input.expectedEvent.MessageId || received.Status != input.expectedEvent.Status)) {
+				t.Errorf("FAIL. Expected event '%+v', but got '%+v'", input.expectedEvent, received)
+			}
+
+			if received != nil && received.ReceivedAt.IsZero() {
+				t.Errorf("FAIL. Expected event receive time to be set")
+			}
+		})
+	}
+}
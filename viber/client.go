@@ -1,7 +1,10 @@
 package viber
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"strings"
 
 	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber/internal"
 )
@@ -29,7 +32,7 @@ const (
 	Rejected
 	Undelivered
 	Pending
-	Unknown = iota + 20
+	Unknown MessageStatus = iota + 20 // Unknown status has code 26.
 )
 
 // String returns the message status description.
@@ -60,6 +63,12 @@ type MessageReceipt struct {
 	Status    MessageStatus `json:"status"`     // Viber message status
 }
 
+// MessageReceiptResult holds the receipt of the particular Viber message or the error occurred while getting it.
+type MessageReceiptResult struct {
+	Receipt *MessageReceipt
+	Err     error
+}
+
 // Client is used to work with Viber messages.
 type Client struct {
 	base *internal.BaseClient
@@ -71,6 +80,7 @@ func NewClient(apiKey string) *Client {
 		base: &internal.BaseClient{
 			ApiKey:              apiKey,
 			ParseViberErrorFunc: parseViberError,
+			BatchOptions:        internal.NewBatchOptions(IsRateLimitError),
 		},
 	}
 }
@@ -90,6 +100,53 @@ func (client *Client) GetMessageStatus(messageId int64) (*MessageReceipt, error)
 	return messageReceipt, nil
 }
 
+// SetBatchLimits sets the maximum number of simultaneous requests and the maximum
+// number of requests per second used by GetMessageStatuses. Zero requestsPerSecond means no rate limit.
+func (client *Client) SetBatchLimits(concurrency int, requestsPerSecond float64) *Client {
+	client.base.BatchOptions.Concurrency = concurrency
+	client.base.BatchOptions.RequestsPerSecond = requestsPerSecond
+	return client
+}
+
+// GetMessageStatuses returns statuses of many Viber messages.
+// Requests are sent concurrently (see SetBatchLimits), duplicate ids are requested once and
+// requests rejected because of the rate limit are retried. Returned map contains a result for every
+// requested id. If the context is done before all statuses are received, remaining ids get
+// the context error, which is returned as well.
+func (client *Client) GetMessageStatuses(ctx context.Context, messageIds []int64) (map[int64]MessageReceiptResult, error) {
+	results, err := client.base.GetMessageStatusResponses(ctx, messageIds, func() interface{} {
+		return &MessageReceipt{}
+	})
+
+	receipts := make(map[int64]MessageReceiptResult, len(results))
+	for id, result := range results {
+		receipt, _ := result.Value.(*MessageReceipt)
+		receipts[id] = MessageReceiptResult{Receipt: receipt, Err: result.Err}
+	}
+
+	return receipts, err
+}
+
+// invalidParameterCode is a code of the error returned for an empty or invalid request parameter.
+const invalidParameterCode = 1
+
+// IsRateLimitError returns true if the error means the Viber API rate limit was exceeded.
+func IsRateLimitError(err error) bool {
+	viberError, ok := err.(Error)
+	return ok && viberError.Status == 429
+}
+
+// IsInvalidSenderError returns true if the error (or any error it wraps) means the message sender (source_addr)
+// was rejected. Such errors have the invalid parameter code and mention source_addr in the name or the message.
+func IsInvalidSenderError(err error) bool {
+	var viberError Error
+	if !errors.As(err, &viberError) || viberError.Code != invalidParameterCode {
+		return false
+	}
+
+	return strings.Contains(strings.ToLower(viberError.Name+" "+viberError.Message), "source_addr")
+}
+
 func parseViberError(responseBody []byte) error {
 	var viberError Error
 	if err := json.Unmarshal(responseBody, &viberError); err != nil {
@@ -1,7 +1,12 @@
 package viber_test
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"sync"
 	"testing"
 
 	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
@@ -95,3 +100,84 @@ func TestGetViberMessageStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestGetViberMessageStatuses(t *testing.T) {
+	var responses = map[int64][]string{
+		1: {`{"message_id":1,"status":1}`},
+		2: {`{"name":"Too Many Requests","message":"Rate limit exceeded","code":0,"status":429}`, `{"message_id":2,"status":4}`},
+		3: {`{"name":"Invalid Parameter: message_id","message":"Empty parameter or parameter validation error","code":1,"status":400}`},
+	}
+
+	var expectedStatuses = map[int64]viber.MessageStatus{1: viber.Delivered, 2: viber.Undelivered}
+
+	client := viber.NewClient("").SetBatchLimits(3, 0)
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	var mu sync.Mutex
+	httpmock.RegisterResponder("POST", "https://web.it-decision.com/v1/api/receive-viber",
+		func(req *http.Request) (*http.Response, error) {
+			var request map[string]int64
+			if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+				return nil, err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			id := request["message_id"]
+			response := responses[id][0]
+			if len(responses[id]) > 1 {
+				responses[id] = responses[id][1:]
+			}
+
+			return httpmock.NewStringResponse(200, response), nil
+		})
+
+	results, err := client.GetMessageStatuses(context.Background(), []int64{1, 2, 3, 3})
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	if len(results) != 3 {
+		t.Errorf("FAIL. Expected 3 results, but got %d", len(results))
+	}
+
+	for id, expectedStatus := range expectedStatuses {
+		result := results[id]
+		if result.Err != nil || result.Receipt == nil || result.Receipt.MessageId != id || result.Receipt.Status != expectedStatus {
+			t.Errorf("FAIL. Expected status '%s' for message %d, but got '%+v'", expectedStatus, id, result)
+		}
+	}
+
+	if viberError, ok := results[3].Err.(viber.Error); !ok || viberError.Status != 400 {
+		t.Errorf("FAIL. Expected Viber error for message 3, but got '%+v'", results[3])
+	}
+
+	if calls := httpmock.GetTotalCallCount(); calls != 4 {
+		t.Errorf("FAIL. Expected 4 requests, but got %d", calls)
+	}
+}
+
+func TestIsInvalidSenderError(t *testing.T) {
+	var inputData = []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"invalid source_addr", viber.Error{Name: "Invalid Parameter: source_addr", Message: "Empty parameter or parameter validation error", Code: 1, Status: 400}, true},
+		{"different wording", viber.Error{Name: "Invalid parameter", Message: "Source_Addr is not allowed", Code: 1, Status: 400}, true},
+		{"wrapped", fmt.Errorf("send to 380504444444: %w", viber.Error{Name: "Invalid Parameter: source_addr", Code: 1, Status: 400}), true},
+		{"other parameter", viber.Error{Name: "Invalid Parameter: destination_addr", Code: 1, Status: 400}, false},
+		{"other code", viber.Error{Name: "source_addr", Code: 2, Status: 400}, false},
+		{"not Viber error", errors.New("Invalid Parameter: source_addr"), false},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			if actual := viber.IsInvalidSenderError(input.err); actual != input.expected {
+				t.Errorf("FAIL. Expected %t, but got %t", input.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package viber
+
+import (
+	"encoding/json"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/internal/enum"
+)
+
+var messageStatusNames = enum.Names{
+	"Sent":        int64(Sent),
+	"Delivered":   int64(Delivered),
+	"Error":       int64(ErrorStatus),
+	"Rejected":    int64(Rejected),
+	"Undelivered": int64(Undelivered),
+	"Pending":     int64(Pending),
+	"Unknown":     int64(Unknown),
+}
+
+var messageStatusAliases = enum.Names{
+	"ErrorStatus": int64(ErrorStatus),
+}
+
+var messageTypeNames = enum.Names{
+	"TextOnly":            int64(TextOnly),
+	"TextImageButton":     int64(TextImageButton),
+	"TextOnly2Way":        int64(TextOnly2Way),
+	"TextImageButton2Way": int64(TextImageButton2Way),
+}
+
+var messageSourceTypeNames = enum.Names{
+	"Promotional":   int64(Promotional),
+	"Transactional": int64(Transactional),
+}
+
+// ParseMessageStatus parses the message status from its name (like "Delivered") or numeric code (like "1").
+func ParseMessageStatus(text string) (MessageStatus, error) {
+	code, err := enum.Parse(text, "Viber message status", 16, true, messageStatusNames, messageStatusAliases)
+	return MessageStatus(code), err
+}
+
+// MarshalText implements encoding.TextMarshaler interface.
+// Known statuses are encoded as names, unknown ones as numeric codes.
+func (s MessageStatus) MarshalText() ([]byte, error) {
+	return messageStatusNames.Text(int64(s)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler interface. It accepts names and numeric codes.
+func (s *MessageStatus) UnmarshalText(text []byte) error {
+	status, err := ParseMessageStatus(string(text))
+	if err != nil {
+		return err
+	}
+
+	*s = status
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface. It accepts names and numeric codes.
+func (s *MessageStatus) UnmarshalJSON(data []byte) error {
+	return enum.UnmarshalJSON(data, s.UnmarshalText)
+}
+
+// ParseMessageType parses the message type from its name (like "TextOnly") or numeric code (like "106").
+func ParseMessageType(text string) (MessageType, error) {
+	code, err := enum.Parse(text, "Viber message type", 16, true, messageTypeNames)
+	return MessageType(code), err
+}
+
+// MarshalText implements encoding.TextMarshaler interface.
+// Known message types are encoded as names, unknown ones as numeric codes.
+func (t MessageType) MarshalText() ([]byte, error) {
+	return messageTypeNames.Text(int64(t)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler interface. It accepts names and numeric codes.
+func (t *MessageType) UnmarshalText(text []byte) error {
+	messageType, err := ParseMessageType(string(text))
+	if err != nil {
+		return err
+	}
+
+	*t = messageType
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface. It accepts names and numeric codes.
+func (t *MessageType) UnmarshalJSON(data []byte) error {
+	return enum.UnmarshalJSON(data, t.UnmarshalText)
+}
+
+// ParseMessageSourceType parses the message source type from its name (like "Promotional") or numeric code (like "1").
+func ParseMessageSourceType(text string) (MessageSourceType, error) {
+	code, err := enum.Parse(text, "Viber message source type", 16, true, messageSourceTypeNames)
+	return MessageSourceType(code), err
+}
+
+// MarshalText implements encoding.TextMarshaler interface.
+// Known source types are encoded as names, unknown ones as numeric codes.
+func (t MessageSourceType) MarshalText() ([]byte, error) {
+	return messageSourceTypeNames.Text(int64(t)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler interface. It accepts names and numeric codes.
+func (t *MessageSourceType) UnmarshalText(text []byte) error {
+	sourceType, err := ParseMessageSourceType(string(text))
+	if err != nil {
+		return err
+	}
+
+	*t = sourceType
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface. It accepts names and numeric codes.
+func (t *MessageSourceType) UnmarshalJSON(data []byte) error {
+	return enum.UnmarshalJSON(data, t.UnmarshalText)
+}
+
+// MarshalJSON implements json.Marshaler interface. The message is encoded in the Viber API format,
+// where the message type and the source type are numeric codes.
+func (m Message) MarshalJSON() ([]byte, error) {
+	type message Message
+	return json.Marshal(struct {
+		message
+		MessageType uint16 `json:"message_type"`
+		SourceType  uint16 `json:"source_type"`
+	}{message(m), uint16(m.MessageType), uint16(m.SourceType)})
+}
@@ -0,0 +1,107 @@
+package viber_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+)
+
+func TestParseViberEnums(t *testing.T) {
+	var inputData = []struct {
+		text         string
+		parse        func(string) (interface{}, error)
+		expected     interface{}
+		expectsError bool
+	}{
+		{"Delivered", parseStatus, viber.Delivered, false},
+		{"error", parseStatus, viber.ErrorStatus, false},
+		{"ErrorStatus", parseStatus, viber.ErrorStatus, false},
+		{"26", parseStatus, viber.Unknown, false},
+		{"Lost", parseStatus, nil, true},
+		{"70000", parseStatus, nil, true},
+		{"TextOnly2Way", parseType, viber.TextOnly2Way, false},
+		{"108", parseType, viber.TextImageButton, false},
+		{"Transactional", parseSourceType, viber.Transactional, false},
+		{"1", parseSourceType, viber.Promotional, false},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.text, func(t *testing.T) {
+			value, err := input.parse(input.text)
+			if (err != nil) != input.expectsError {
+				t.Errorf("FAIL. Expected error: %t, but got '%v'", input.expectsError, err)
+			}
+
+			if err == nil && value != input.expected {
+				t.Errorf("FAIL. Expected value '%v', but got '%v'", input.expected, value)
+			}
+		})
+	}
+}
+
+func parseStatus(text string) (interface{}, error) {
+	return viber.ParseMessageStatus(text)
+}
+
+func parseType(text string) (interface{}, error) {
+	return viber.ParseMessageType(text)
+}
+
+func parseSourceType(text string) (interface{}, error) {
+	return viber.ParseMessageSourceType(text)
+}
+
+func TestMessageReceiptJson(t *testing.T) {
+	receipt := viber.MessageReceipt{MessageId: 429, Status: viber.ErrorStatus}
+
+	content, err := json.Marshal(receipt)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	expectedJson := `{"message_id":429,"status":"Error"}`
+	if string(content) != expectedJson {
+		t.Errorf("FAIL. Expected JSON '%s', but got '%s'", expectedJson, content)
+	}
+
+	for _, data := range []string{expectedJson, `{"message_id":429,"status":2}`} {
+		var decoded viber.MessageReceipt
+		if err := json.Unmarshal([]byte(data), &decoded); err != nil {
+			t.Fatalf("FAIL. Unexpected error '%v'", err)
+		}
+
+		if decoded != receipt {
+			t.Errorf("FAIL. Expected receipt '%+v', but got '%+v'", receipt, decoded)
+		}
+	}
+}
+
+func TestMessageWireFormat(t *testing.T) {
+	message := viber.NewMessage().
+		SetMessageType(viber.TextImageButton).
+		SetSourceType(viber.Transactional)
+
+	content, err := json.Marshal(message)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	if fields["message_type"] != float64(108) || fields["source_type"] != float64(2) {
+		t.Errorf("FAIL. Expected numeric message and source types, but got '%s'", content)
+	}
+
+	var decoded viber.Message
+	if err := json.Unmarshal(content, &decoded); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	if decoded != *message {
+		t.Errorf("FAIL. Expected message '%+v', but got '%+v'", *message, decoded)
+	}
+}
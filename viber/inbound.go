@@ -0,0 +1,106 @@
+package viber
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber/internal"
+)
+
+// InboundMessage represents a user reply to a two-way Viber message (TextOnly2Way or TextImageButton2Way).
+type InboundMessage struct {
+	MessageId  int64     `json:"message_id"`       // Id of the two-way Viber message the user replied to.
+	Phone      string    `json:"source_addr"`      // Phone of the user who replied (receiver of the originating message).
+	Sender     string    `json:"destination_addr"` // Sender of the originating message the reply is addressed to.
+	Text       string    `json:"text"`             // Reply text in the UTF8 format.
+	ReceivedAt time.Time `json:"-"`                // Time when the reply was received.
+	Origin     *Message  `json:"-"`                // Originating message, if it was found by the origin lookup.
+}
+
+// InboundHandler is an http.Handler which receives user replies to two-way Viber messages.
+type InboundHandler struct {
+	base   internal.CallbackHandler
+	lookup func(messageId int64) (*Message, error)
+}
+
+// NewInboundHandler creates new handler of replies to two-way Viber messages.
+// The handle function is called for every valid reply. If it returns an error,
+// the handler responds with 500 status code, so the reply may be retried.
+func NewInboundHandler(handle func(message *InboundMessage) error) *InboundHandler {
+	h := &InboundHandler{}
+	h.base = internal.CallbackHandler{
+		Decode: func(body []byte) (interface{}, error) {
+			return DecodeInboundMessage(body)
+		},
+		Dispatch: func(event interface{}) error {
+			message := event.(*InboundMessage)
+			if err := h.link(message); err != nil {
+				return err
+			}
+			return handle(message)
+		},
+	}
+
+	return h
+}
+
+// SetOriginLookup sets the function which returns the sent message by its id, so replies are linked
+// to their originating messages. The lookup should return nil message if the message is unknown.
+func (h *InboundHandler) SetOriginLookup(lookup func(messageId int64) (*Message, error)) *InboundHandler {
+	h.lookup = lookup
+	return h
+}
+
+// SetSecurity sets the protection from forged requests. Requests rejected by it are answered with 403 status code.
+func (h *InboundHandler) SetSecurity(security *CallbackSecurity) *InboundHandler {
+	h.base.Verify = security.Verify
+	return h
+}
+
+// ServeHTTP implements http.Handler interface.
+func (h *InboundHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.base.ServeHTTP(w, r)
+}
+
+// link sets the originating message of the reply and fills the phone and the sender from it if they are missing.
+func (h *InboundHandler) link(message *InboundMessage) error {
+	if h.lookup == nil || message.MessageId <= 0 {
+		return nil
+	}
+
+	origin, err := h.lookup(message.MessageId)
+	if err != nil || origin == nil {
+		return err
+	}
+
+	message.Origin = origin
+	if message.Phone == "" {
+		message.Phone = origin.Receiver
+	}
+	if message.Sender == "" {
+		message.Sender = origin.Sender
+	}
+
+	return nil
+}
+
+// DecodeInboundMessage decodes and validates the reply payload.
+// The reply must have the originating message id or the user phone.
+func DecodeInboundMessage(body []byte) (*InboundMessage, error) {
+	var message InboundMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, err
+	}
+
+	if message.MessageId < 0 {
+		return nil, errors.New("message_id is invalid")
+	}
+	if message.MessageId == 0 && message.Phone == "" {
+		return nil, errors.New("message_id or source_addr is required")
+	}
+
+	message.ReceivedAt = time.Now()
+	return &message, nil
+}
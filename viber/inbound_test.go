@@ -0,0 +1,96 @@
+package viber_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+)
+
+func TestInboundHandler(t *testing.T) {
+	origin := &viber.Message{Sender: "Sender", Receiver: "380504444444", MessageType: viber.TextOnly2Way, Text: "Reply 1 to confirm"}
+	lookup := func(messageId int64) (*viber.Message, error) {
+		switch messageId {
+		case 429:
+			return origin, nil
+		case 500:
+			return nil, errors.New("database is down")
+		default:
+			return nil, nil
+		}
+	}
+
+	var inputData = []struct {
+		name            string
+		body            string
+		expectedCode    int
+		expectedMessage *viber.InboundMessage
+	}{
+		{"full reply", `{"message_id":429,"source_addr":"380504444444","destination_addr":"Sender","text":"1"}`, http.StatusOK,
+			&viber.InboundMessage{MessageId: 429, Phone: "380504444444", Sender: "Sender", Text: "1", Origin: origin}},
+		{"linked by message id", `{"message_id":429,"text":"STATUS"}`, http.StatusOK,
+			&viber.InboundMessage{MessageId: 429, Phone: "380504444444", Sender: "Sender", Text: "STATUS", Origin: origin}},
+		{"unknown origin", `{"message_id":430,"source_addr":"380505555555","text":"HELP"}`, http.StatusOK,
+			&viber.InboundMessage{MessageId: 430, Phone: "380505555555", Text: "HELP"}},
+		{"without message id", `{"source_addr":"380505555555","destination_addr":"Sender","text":"HELP"}`, http.StatusOK,
+			&viber.InboundMessage{Phone: "380505555555", Sender: "Sender", Text: "HELP"}},
+		{"missing message id and phone", `{"text":"HELP"}`, http.StatusBadRequest, nil},
+		{"malformed payload", `{"message_id":`, http.StatusBadRequest, nil},
+		{"lookup error", `{"message_id":500,"text":"1"}`, http.StatusInternalServerError, nil},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			var received *viber.InboundMessage
+			handler := viber.NewInboundHandler(func(message *viber.InboundMessage) error {
+				received = message
+				return nil
+			}).SetOriginLookup(lookup)
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/viber-inbound", strings.NewReader(input.body)))
+
+			if recorder.Code != input.expectedCode {
+				t.Errorf("FAIL. Expected response code '%d', but got '%d'", input.expectedCode, recorder.Code)
+			}
+
+			if (received == nil) != (input.expectedMessage == nil) {
+				t.Fatalf("FAIL. Expected message '%+v', but got '%+v'", input.expectedMessage, received)
+			}
+			if received == nil {
+				return
+			}
+
+			expected := input.expectedMessage
+			if received.MessageId != expected.MessageId || received.Phone != expected.Phone || received.Sender != expected.Sender ||
+				received.Text != expected.Text || received.Origin != expected.Origin {
+				t.Errorf("FAIL. Expected message '%+v', but got '%+v'", expected, received)
+			}
+
+			if received.ReceivedAt.IsZero() {
+				t.Errorf("FAIL. Expected message receive time to be set")
+			}
+		})
+	}
+}
+
+func TestMessageTypeIsTwoWay(t *testing.T) {
+	var inputData = []struct {
+		messageType viber.MessageType
+		expected    bool
+	}{
+		{viber.TextOnly, false},
+		{viber.TextImageButton, false},
+		{viber.TextOnly2Way, true},
+		{viber.TextImageButton2Way, true},
+	}
+
+	for _, input := range inputData {
+		if input.messageType.IsTwoWay() != input.expected {
+			t.Errorf("FAIL. Expected %s two-way to be %t", input.messageType, input.expected)
+		}
+	}
+}
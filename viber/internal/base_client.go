@@ -2,12 +2,15 @@ package internal
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"strings"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/internal/batch"
 )
 
 const baseUrl = "https://web.it-decision.com/v1/api"
@@ -17,12 +20,23 @@ const messageIdPropertyName = "message_id"
 type BaseClient struct {
 	ApiKey              string
 	ParseViberErrorFunc func([]byte) error
+	BatchOptions        batch.Options
+}
+
+// NewBatchOptions returns default options of the batch status requests.
+// Requests failed because of the rate limit are retried a few times.
+func NewBatchOptions(isRateLimitError func(error) bool) batch.Options {
+	return batch.Options{
+		Concurrency: batch.DefaultConcurrency,
+		MaxRetries:  3,
+		IsRetryable: isRateLimitError,
+	}
 }
 
 // SendMessage sends Viber message.
 func (cl *BaseClient) SendMessage(message interface{}) (int64, error) {
 	url := fmt.Sprintf("%s/send-viber", baseUrl)
-	responseBody, err := cl.makeHttpRequest(url, message)
+	responseBody, err := cl.makeHttpRequest(context.Background(), url, message)
 	if err != nil {
 		return -1, err
 	}
@@ -42,10 +56,14 @@ func (cl *BaseClient) SendMessage(message interface{}) (int64, error) {
 
 // GetMessageStatus
 func (cl *BaseClient) GetMessageStatusResponse(messageId int64, result interface{}) error {
+	return cl.getMessageStatusResponse(context.Background(), messageId, result)
+}
+
+func (cl *BaseClient) getMessageStatusResponse(ctx context.Context, messageId int64, result interface{}) error {
 	url := fmt.Sprintf("%s/receive-viber", baseUrl)
 	request := map[string]int64{messageIdPropertyName: messageId}
 
-	responseBody, err := cl.makeHttpRequest(url, request)
+	responseBody, err := cl.makeHttpRequest(ctx, url, request)
 	if err != nil {
 		return err
 	}
@@ -57,12 +75,25 @@ func (cl *BaseClient) GetMessageStatusResponse(messageId int64, result interface
 	return nil
 }
 
+// GetMessageStatusResponses gets statuses of many messages concurrently.
+// newResult is called to create the value each response is unmarshalled into.
+func (cl *BaseClient) GetMessageStatusResponses(ctx context.Context, messageIds []int64, newResult func() interface{}) (map[int64]batch.Result, error) {
+	return batch.Run(ctx, messageIds, cl.BatchOptions, func(ctx context.Context, id int64) (interface{}, error) {
+		result := newResult()
+		if err := cl.getMessageStatusResponse(ctx, id, result); err != nil {
+			return nil, err
+		}
+
+		return result, nil
+	})
+}
+
 // MakeHttpRequest performs HTTP request to the Viber endpoints and returns response body.
-func (cl *BaseClient) makeHttpRequest(url string, requestContent interface{}) ([]byte, error) {
+func (cl *BaseClient) makeHttpRequest(ctx context.Context, url string, requestContent interface{}) ([]byte, error) {
 	jsonRequest, _ := json.Marshal(requestContent)
 	accessKeyBase64 := base64.StdEncoding.EncodeToString([]byte(cl.ApiKey))
 
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(jsonRequest))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonRequest))
 	if err != nil {
 		return nil, err
 	}
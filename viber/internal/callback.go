@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"io/ioutil"
+	"net/http"
+)
+
+const maxCallbackBodySize = 1 << 20
+
+// CallbackHandler is a base HTTP handler of Viber and Viber plus SMS callbacks.
+type CallbackHandler struct {
+	Decode   func(body []byte) (interface{}, error) // Decode decodes and validates the request body.
+	Dispatch func(event interface{}) error          // Dispatch passes the decoded event to the user function.
+	Verify   func(r *http.Request) error            // Verify checks whether the request is allowed (optional).
+	// Claim checks whether the decoded event is allowed for the request (optional). The returned function is called
+	// if the event could not be processed.
+	Claim func(r *http.Request, event interface{}) (func(), error)
+}
+
+// ServeHTTP implements http.Handler interface.
+// It responds with 405 to non POST requests, with 403 to requests rejected by Verify or Claim, with 400 to malformed or invalid payloads,
+// with 500 if the user function fails and with 200 if the event was processed.
+func (h *CallbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if h.Verify != nil {
+		if err := h.Verify(r); err != nil {
+			http.Error(w, "forbidden: "+err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxCallbackBodySize))
+	if err != nil {
+		http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	event, err := h.Decode(body)
+	if err != nil {
+		http.Error(w, "invalid callback: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	release := func() {}
+	if h.Claim != nil {
+		if release, err = h.Claim(r, event); err != nil {
+			http.Error(w, "forbidden: "+err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+
+	if err := h.Dispatch(event); err != nil {
+		release()
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
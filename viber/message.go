@@ -10,6 +10,27 @@ const (
 	TextImageButton2Way MessageType = 208
 )
 
+// String returns the message type description.
+func (t MessageType) String() string {
+	switch t {
+	case TextOnly:
+		return "TextOnly"
+	case TextImageButton:
+		return "TextImageButton"
+	case TextOnly2Way:
+		return "TextOnly2Way"
+	case TextImageButton2Way:
+		return "TextImageButton2Way"
+	default:
+		return "Invalid message type"
+	}
+}
+
+// IsTwoWay returns true if users can reply to messages of the type.
+func (t MessageType) IsTwoWay() bool {
+	return t == TextOnly2Way || t == TextImageButton2Way
+}
+
 // MessageSourceType represents message sending procedure.
 type MessageSourceType uint16
 
@@ -18,6 +39,18 @@ const (
 	Transactional
 )
 
+// String returns the message source type description.
+func (t MessageSourceType) String() string {
+	switch t {
+	case Promotional:
+		return "Promotional"
+	case Transactional:
+		return "Transactional"
+	default:
+		return "Invalid source type"
+	}
+}
+
 // Message represents a Viber message.
 type Message struct {
 	Sender         string            `json:"source_addr"`      // Sender is a message sender (from whom message is sent).
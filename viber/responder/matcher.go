@@ -0,0 +1,46 @@
+package responder
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Matcher decides whether the user reply triggers a rule or a menu option.
+type Matcher interface {
+	Match(text string) bool
+}
+
+// MatcherFunc is an adapter to use ordinary functions as matchers.
+type MatcherFunc func(text string) bool
+
+// Match implements Matcher interface.
+func (f MatcherFunc) Match(text string) bool {
+	return f(text)
+}
+
+// Keyword returns the matcher of replies equal to any of the keywords, ignoring case and surrounding spaces.
+func Keyword(keywords ...string) Matcher {
+	return MatcherFunc(func(text string) bool {
+		text = strings.TrimSpace(text)
+		for _, keyword := range keywords {
+			if strings.EqualFold(text, keyword) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Regexp returns the matcher of replies matching the regular expression.
+func Regexp(expression *regexp.Regexp) Matcher {
+	return MatcherFunc(func(text string) bool {
+		return expression.MatchString(strings.TrimSpace(text))
+	})
+}
+
+// Any returns the matcher of all replies. It is useful as the last rule or option.
+func Any() Matcher {
+	return MatcherFunc(func(text string) bool {
+		return true
+	})
+}
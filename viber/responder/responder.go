@@ -0,0 +1,318 @@
+// Package responder contains a rule engine which automatically answers replies to two-way Viber messages.
+package responder
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber/session"
+)
+
+const defaultMenuTimeout = 10 * time.Minute
+
+// Sender sends Viber messages. It is implemented by viber.Client.
+type Sender interface {
+	SendMessage(message *viber.Message) (int64, error)
+}
+
+// Action describes what is done when the rule or the menu option matches the reply.
+type Action struct {
+	Reply   string // Reply is a text sent to the user (nothing is sent if empty).
+	Menu    string // Menu is a name of the menu the user enters, its prompt is sent after the reply. Empty value leaves the current menu.
+	Handoff bool   // Handoff passes the conversation to a human, the responder doesn't answer the user until Release.
+}
+
+// Rule represents a global rule checked for every reply which doesn't match an option of the current menu.
+type Rule struct {
+	Matcher Matcher
+	Action  Action
+}
+
+// Option represents a menu option.
+type Option struct {
+	Matcher Matcher
+	Action  Action
+}
+
+// Menu represents a step of a multi-step conversation. Options are checked in order, then global rules.
+type Menu struct {
+	Name         string   // Name identifies the menu in actions.
+	Prompt       string   // Prompt is a text sent when the user enters the menu.
+	Options      []Option // Options are checked in order.
+	InvalidReply string   // InvalidReply is sent when nothing matches, the user stays in the menu. If empty, the conversation is handed off.
+}
+
+// Outcome represents what the responder did with the reply.
+type Outcome struct {
+	Matched   bool    // Matched is true if a rule or a menu option matched the reply.
+	Menu      string  // Menu is the menu the user is in after the reply.
+	HandedOff bool    // HandedOff is true if the reply was passed to a human.
+	Replies   []int64 // Replies are ids of the sent messages.
+}
+
+// Responder answers user replies by rules and menus. Replies which match nothing are handed off
+// to a human with OnHandoff, as well as all following replies of the user until Release.
+type Responder struct {
+	MenuTimeout  time.Duration                             // MenuTimeout is a time after which the idle user leaves the menu (10 minutes by default).
+	OnHandoff    func(message *viber.InboundMessage) error // OnHandoff passes the reply to the human queue.
+	HandoffReply string                                    // HandoffReply is sent to the user when the conversation is handed off (optional).
+	Now          func() time.Time                          // Now returns current time (time.Now by default).
+
+	client   Sender
+	sessions *session.Manager
+	rules    []Rule
+	menus    map[string]*Menu
+
+	mu     sync.Mutex
+	states map[session.Key]*state
+	locks  map[session.Key]*userLock
+}
+
+// userLock serializes handling of replies of the user, users is a number of replies holding or waiting for it.
+type userLock struct {
+	mu    sync.Mutex
+	users int
+}
+
+// state is a conversation state of the user.
+type state struct {
+	menu      string
+	handedOff bool
+	updatedAt time.Time
+}
+
+// New creates new responder which sends replies with the given client.
+func New(client Sender) *Responder {
+	return &Responder{
+		client: client,
+		menus:  make(map[string]*Menu),
+		states: make(map[session.Key]*state),
+		locks:  make(map[session.Key]*userLock),
+	}
+}
+
+// SetSessions sets the session manager used to send replies, so they are kept in the conversation thread.
+func (r *Responder) SetSessions(sessions *session.Manager) *Responder {
+	r.sessions = sessions
+	return r
+}
+
+// AddRule adds the global rule. Rules are checked in the order they were added.
+func (r *Responder) AddRule(matcher Matcher, action Action) *Responder {
+	r.rules = append(r.rules, Rule{Matcher: matcher, Action: action})
+	return r
+}
+
+// AddMenu adds the menu which can be entered by actions.
+func (r *Responder) AddMenu(menu *Menu) *Responder {
+	r.menus[menu.Name] = menu
+	return r
+}
+
+// Handle answers the user reply and returns what was done. Replies of the same user are handled one at a time,
+// so every reply sees the state left by the previous one.
+func (r *Responder) Handle(message *viber.InboundMessage) (Outcome, error) {
+	key := session.Key{Phone: message.Phone, Sender: message.Sender}
+	unlock := r.lock(key)
+	defer unlock()
+
+	current := r.state(key)
+
+	if current.handedOff {
+		return Outcome{HandedOff: true}, r.handoff(message)
+	}
+
+	var menu *Menu
+	if current.menu != "" {
+		menu = r.menus[current.menu]
+	}
+
+	action, matched := r.match(menu, message.Text)
+	if !matched {
+		if menu != nil && menu.InvalidReply != "" {
+			outcome := Outcome{Menu: menu.Name}
+			err := r.reply(key, menu.InvalidReply, &outcome)
+			r.setState(key, state{menu: menu.Name})
+			return outcome, err
+		}
+		action = Action{Handoff: true}
+	}
+
+	outcome := Outcome{Matched: matched}
+	if action.Handoff {
+		r.setState(key, state{handedOff: true})
+		outcome.HandedOff = true
+		if r.HandoffReply != "" {
+			if err := r.reply(key, r.HandoffReply, &outcome); err != nil {
+				return outcome, err
+			}
+		}
+		return outcome, r.handoff(message)
+	}
+
+	if err := r.reply(key, action.Reply, &outcome); err != nil {
+		return outcome, err
+	}
+
+	if action.Menu == "" {
+		r.setState(key, state{})
+		return outcome, nil
+	}
+
+	next, ok := r.menus[action.Menu]
+	if !ok {
+		r.setState(key, state{})
+		return outcome, fmt.Errorf("responder: unknown menu '%s'", action.Menu)
+	}
+
+	r.setState(key, state{menu: next.Name})
+	outcome.Menu = next.Name
+	return outcome, r.reply(key, next.Prompt, &outcome)
+}
+
+// HandleInbound returns a function which answers replies. Pass it to viber.NewInboundHandler.
+func (r *Responder) HandleInbound() func(message *viber.InboundMessage) error {
+	return func(message *viber.InboundMessage) error {
+		_, err := r.Handle(message)
+		return err
+	}
+}
+
+// Menu returns the name of the menu the user is in, or empty string.
+func (r *Responder) Menu(phone string, sender string) string {
+	return r.state(session.Key{Phone: phone, Sender: sender}).menu
+}
+
+// IsHandedOff returns true if the conversation with the user is handed off to a human.
+func (r *Responder) IsHandedOff(phone string, sender string) bool {
+	return r.state(session.Key{Phone: phone, Sender: sender}).handedOff
+}
+
+// Release returns the handed off conversation to the responder.
+func (r *Responder) Release(phone string, sender string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.states, session.Key{Phone: phone, Sender: sender})
+}
+
+// match returns the action of the first matching option of the menu or global rule.
+func (r *Responder) match(menu *Menu, text string) (Action, bool) {
+	if menu != nil {
+		for _, option := range menu.Options {
+			if option.Matcher.Match(text) {
+				return option.Action, true
+			}
+		}
+	}
+
+	for _, rule := range r.rules {
+		if rule.Matcher.Match(text) {
+			return rule.Action, true
+		}
+	}
+
+	return Action{}, false
+}
+
+func (r *Responder) reply(key session.Key, text string, outcome *Outcome) error {
+	if text == "" {
+		return nil
+	}
+
+	var messageId int64
+	var err error
+	if r.sessions != nil {
+		messageId, err = r.sessions.Reply(key.Phone, key.Sender, text)
+	}
+	if r.sessions == nil || err == session.ErrNoSession {
+		messageId, err = r.client.SendMessage(viber.NewMessage().SetSender(key.Sender).SetReceiver(key.Phone).
+			SetMessageType(viber.TextOnly2Way).SetSourceType(viber.Transactional).SetText(text))
+	}
+	if err != nil {
+		return err
+	}
+
+	outcome.Replies = append(outcome.Replies, messageId)
+	return nil
+}
+
+func (r *Responder) handoff(message *viber.InboundMessage) error {
+	if r.OnHandoff == nil {
+		return nil
+	}
+
+	return r.OnHandoff(message)
+}
+
+// lock waits until other replies of the user are handled and returns the function which lets the next one in.
+func (r *Responder) lock(key session.Key) func() {
+	r.mu.Lock()
+	l, ok := r.locks[key]
+	if !ok {
+		l = &userLock{}
+		r.locks[key] = l
+	}
+	l.users++
+	r.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if l.users--; l.users == 0 {
+			delete(r.locks, key)
+		}
+	}
+}
+
+// state returns the conversation state of the user, the menu is left after MenuTimeout.
+func (r *Responder) state(key session.Key) state {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s, ok := r.states[key]
+	if !ok {
+		return state{}
+	}
+
+	if s.menu != "" && !r.now().Before(s.updatedAt.Add(r.menuTimeout())) {
+		delete(r.states, key)
+		return state{}
+	}
+
+	return *s
+}
+
+func (r *Responder) setState(key session.Key, s state) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if s.menu == "" && !s.handedOff {
+		delete(r.states, key)
+		return
+	}
+
+	s.updatedAt = r.now()
+	r.states[key] = &s
+}
+
+func (r *Responder) menuTimeout() time.Duration {
+	if r.MenuTimeout <= 0 {
+		return defaultMenuTimeout
+	}
+
+	return r.MenuTimeout
+}
+
+func (r *Responder) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+
+	return time.Now()
+}
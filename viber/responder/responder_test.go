@@ -0,0 +1,245 @@
+package responder_test
+
+import (
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber/responder"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber/session"
+)
+
+type fakeSender struct {
+	mu   sync.Mutex
+	sent []*viber.Message
+}
+
+func (s *fakeSender) SendMessage(message *viber.Message) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = append(s.sent, message)
+	return int64(100 + len(s.sent)), nil
+}
+
+func (s *fakeSender) texts() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var texts []string
+	for _, message := range s.sent {
+		texts = append(texts, message.Text)
+	}
+	return texts
+}
+
+func newSupportResponder(sender *fakeSender) *responder.Responder {
+	return responder.New(sender).
+		AddRule(responder.Keyword("HELP", "?"), responder.Action{Reply: "Reply STATUS or MENU"}).
+		AddRule(responder.Regexp(regexp.MustCompile(`(?i)^status\s*\d*$`)), responder.Action{Reply: "Your order is on the way"}).
+		AddRule(responder.Keyword("MENU"), responder.Action{Menu: "main"}).
+		AddMenu(&responder.Menu{
+			Name:   "main",
+			Prompt: "1 - delivery, 2 - payment, 0 - operator",
+			Options: []responder.Option{
+				{Matcher: responder.Keyword("1"), Action: responder.Action{Menu: "delivery"}},
+				{Matcher: responder.Keyword("2"), Action: responder.Action{Reply: "We accept cards"}},
+				{Matcher: responder.Keyword("0"), Action: responder.Action{Handoff: true}},
+			},
+			InvalidReply: "Please reply 1, 2 or 0",
+		}).
+		AddMenu(&responder.Menu{
+			Name:   "delivery",
+			Prompt: "1 - courier, 2 - pickup",
+			Options: []responder.Option{
+				{Matcher: responder.Keyword("1", "2"), Action: responder.Action{Reply: "Done"}},
+			},
+		})
+}
+
+func reply(text string) *viber.InboundMessage {
+	return &viber.InboundMessage{MessageId: 429, Phone: "380504444444", Sender: "Sender", Text: text}
+}
+
+func TestResponderFlows(t *testing.T) {
+	var inputData = []struct {
+		name             string
+		replies          []string
+		expectedTexts    []string
+		expectedMenu     string
+		expectedHandoffs int
+	}{
+		{"keyword", []string{" help "}, []string{"Reply STATUS or MENU"}, "", 0},
+		{"regexp", []string{"Status 42"}, []string{"Your order is on the way"}, "", 0},
+		{"menu steps", []string{"MENU", "1", "2"}, []string{"1 - delivery, 2 - payment, 0 - operator", "1 - courier, 2 - pickup", "Done"}, "", 0},
+		{"invalid menu option", []string{"MENU", "5"}, []string{"1 - delivery, 2 - payment, 0 - operator", "Please reply 1, 2 or 0"}, "main", 0},
+		{"global rule inside menu", []string{"MENU", "HELP"}, []string{"1 - delivery, 2 - payment, 0 - operator", "Reply STATUS or MENU"}, "", 0},
+		{"unknown reply is handed off", []string{"Where is my parcel?", "Hello?"}, nil, "", 2},
+		{"handoff option", []string{"MENU", "0", "HELP"}, []string{"1 - delivery, 2 - payment, 0 - operator"}, "", 2},
+		{"no invalid reply in menu", []string{"MENU", "1", "3"}, []string{"1 - delivery, 2 - payment, 0 - operator", "1 - courier, 2 - pickup"}, "", 1},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			sender := &fakeSender{}
+			handoffs := 0
+			r := newSupportResponder(sender)
+			r.OnHandoff = func(message *viber.InboundMessage) error {
+				handoffs++
+				return nil
+			}
+
+			for _, text := range input.replies {
+				if _, err := r.Handle(reply(text)); err != nil {
+					t.Fatalf("FAIL. Unexpected error '%v'", err)
+				}
+			}
+
+			texts := sender.texts()
+			if len(texts) != len(input.expectedTexts) {
+				t.Fatalf("FAIL. Expected replies '%v', but got '%v'", input.expectedTexts, texts)
+			}
+			for i := range texts {
+				if texts[i] != input.expectedTexts[i] {
+					t.Errorf("FAIL. Expected replies '%v', but got '%v'", input.expectedTexts, texts)
+					break
+				}
+			}
+
+			if menu := r.Menu("380504444444", "Sender"); menu != input.expectedMenu {
+				t.Errorf("FAIL. Expected menu '%s', but got '%s'", input.expectedMenu, menu)
+			}
+
+			if handoffs != input.expectedHandoffs {
+				t.Errorf("FAIL. Expected %d handoffs, but got %d", input.expectedHandoffs, handoffs)
+			}
+		})
+	}
+}
+
+func TestReplyMessage(t *testing.T) {
+	sender := &fakeSender{}
+	r := newSupportResponder(sender)
+
+	outcome, err := r.Handle(reply("HELP"))
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	if !outcome.Matched || len(outcome.Replies) != 1 || outcome.Replies[0] != 101 {
+		t.Errorf("FAIL. Unexpected outcome '%+v'", outcome)
+	}
+
+	message := sender.sent[0]
+	if message.Sender != "Sender" || message.Receiver != "380504444444" || message.MessageType != viber.TextOnly2Way {
+		t.Errorf("FAIL. Unexpected reply '%+v'", message)
+	}
+}
+
+func TestReleaseAndMenuTimeout(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	sender := &fakeSender{}
+	r := newSupportResponder(sender)
+	r.Now = func() time.Time { return now }
+	r.MenuTimeout = time.Minute
+
+	r.Handle(reply("MENU"))
+	now = now.Add(2 * time.Minute)
+	if menu := r.Menu("380504444444", "Sender"); menu != "" {
+		t.Errorf("FAIL. Expected idle user to leave the menu, but got '%s'", menu)
+	}
+
+	r.Handle(reply("operator please"))
+	if !r.IsHandedOff("380504444444", "Sender") {
+		t.Fatalf("FAIL. Expected conversation to be handed off")
+	}
+
+	r.Release("380504444444", "Sender")
+	outcome, _ := r.Handle(reply("HELP"))
+	if outcome.HandedOff || !outcome.Matched {
+		t.Errorf("FAIL. Expected released conversation to be answered, but got '%+v'", outcome)
+	}
+}
+
+func TestRepliesKeptInSession(t *testing.T) {
+	sender := &fakeSender{}
+	sessions := session.New(sender)
+	messageId, _ := sessions.Start(viber.NewMessage().SetSender("Sender").SetReceiver("380504444444").
+		SetMessageType(viber.TextOnly2Way).SetSourceType(viber.Promotional).SetText("Reply HELP for help"))
+
+	r := newSupportResponder(sender).SetSessions(sessions)
+	handle := sessions.HandleInbound(func(s *session.Session, message *viber.InboundMessage) error {
+		_, err := r.Handle(message)
+		return err
+	})
+
+	if err := handle(&viber.InboundMessage{MessageId: messageId, Phone: "380504444444", Sender: "Sender", Text: "HELP"}); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	s, _ := sessions.Get("380504444444", "Sender")
+	if len(s.Thread) != 3 || s.Thread[2].Text != "Reply STATUS or MENU" {
+		t.Errorf("FAIL. Expected reply in the session thread, but got '%+v'", s.Thread)
+	}
+	if sender.sent[1].SourceType != viber.Promotional {
+		t.Errorf("FAIL. Expected reply with the session source type, but got '%s'", sender.sent[1].SourceType)
+	}
+}
+
+// gatedSender blocks sending of the text until the gate is opened.
+type gatedSender struct {
+	*fakeSender
+	text    string
+	entered chan struct{}
+	gate    chan struct{}
+}
+
+func (s *gatedSender) SendMessage(message *viber.Message) (int64, error) {
+	if message.Text == s.text {
+		close(s.entered)
+		<-s.gate
+	}
+
+	return s.fakeSender.SendMessage(message)
+}
+
+func TestConcurrentRepliesOfUser(t *testing.T) {
+	sender := &gatedSender{fakeSender: &fakeSender{}, text: "Let's see", entered: make(chan struct{}), gate: make(chan struct{})}
+	handoffs := 0
+	r := responder.New(sender).
+		AddRule(responder.Keyword("MENU"), responder.Action{Reply: "Let's see", Menu: "main"}).
+		AddMenu(&responder.Menu{
+			Name:    "main",
+			Prompt:  "1 - delivery, 2 - payment",
+			Options: []responder.Option{{Matcher: responder.Keyword("2"), Action: responder.Action{Reply: "We accept cards"}}},
+		})
+	r.OnHandoff = func(message *viber.InboundMessage) error {
+		handoffs++
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		r.Handle(reply("MENU"))
+	}()
+
+	// the next reply arrives while the answer to the first one is being sent
+	<-sender.entered
+	go func() {
+		defer wg.Done()
+		r.Handle(reply("2"))
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(sender.gate)
+	wg.Wait()
+
+	expected := []string{"Let's see", "1 - delivery, 2 - payment", "We accept cards"}
+	texts := sender.texts()
+	if len(texts) != len(expected) || texts[2] != expected[2] || handoffs != 0 {
+		t.Errorf("FAIL. Expected replies '%v' without handoffs, but got '%v' and %d handoffs", expected, texts, handoffs)
+	}
+}
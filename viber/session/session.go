@@ -0,0 +1,376 @@
+// Package session contains a manager of conversations with users started by two-way Viber messages.
+package session
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+)
+
+const (
+	defaultTimeout         = 24 * time.Hour
+	defaultMaxThreadLength = 100
+)
+
+// ErrNoSession is returned when there is no open session with the user.
+var ErrNoSession = errors.New("session: no open session")
+
+// Direction represents whether the thread message was sent to the user or received from them.
+type Direction string
+
+const (
+	Outbound Direction = "outbound" // Outbound message was sent to the user.
+	Inbound  Direction = "inbound"  // Inbound message is a user reply.
+)
+
+// Key identifies the conversation: the user phone and the sender name the user talks to.
+type Key struct {
+	Phone  string `json:"phone"`
+	Sender string `json:"sender"`
+}
+
+// ThreadMessage represents a message of the conversation.
+type ThreadMessage struct {
+	Direction   Direction         `json:"direction"`    // Direction of the message.
+	MessageId   int64             `json:"message_id"`   // Id of the sent message, or of the message the user replied to.
+	MessageType viber.MessageType `json:"message_type"` // Type of the sent message (zero for replies).
+	Text        string            `json:"text"`         // Message text.
+	At          time.Time         `json:"at"`           // Time when the message was sent or received.
+}
+
+// Session represents an open conversation with the user.
+type Session struct {
+	Key
+	MessageType    viber.MessageType       `json:"message_type"`     // Type of the message which started the session.
+	SourceType     viber.MessageSourceType `json:"source_type"`      // Source type of the messages sent in the session.
+	StartedAt      time.Time               `json:"started_at"`       // Time when the session was started.
+	LastActivityAt time.Time               `json:"last_activity_at"` // Time of the last sent or received message.
+	ExpiresAt      time.Time               `json:"expires_at"`       // Time when the session expires without activity.
+	Thread         []ThreadMessage         `json:"thread"`           // Messages of the conversation in order.
+}
+
+// Sender sends Viber messages. It is implemented by viber.Client.
+type Sender interface {
+	SendMessage(message *viber.Message) (int64, error)
+}
+
+// Manager tracks conversations started by two-way Viber messages. A session is opened (or renewed)
+// by every two-way message sent to the user and expires after Timeout without activity.
+type Manager struct {
+	Timeout         time.Duration         // Timeout is an inactivity period after which the session expires (24 hours by default).
+	MaxThreadLength int                   // MaxThreadLength is a maximum number of kept thread messages, older ones are dropped (100 by default).
+	OnExpired       func(session Session) // OnExpired is called for every expired session.
+	Now             func() time.Time      // Now returns current time (time.Now by default).
+
+	client Sender
+
+	mu       sync.Mutex
+	sessions map[Key]*openSession
+	queue    expiryQueue
+	origins  map[int64]*viber.Message
+}
+
+// openSession is a session kept by the manager, index is its position in the expiry queue.
+type openSession struct {
+	Session
+	index int
+}
+
+// New creates new session manager which sends messages with the given client.
+func New(client Sender) *Manager {
+	return &Manager{
+		client:   client,
+		sessions: make(map[Key]*openSession),
+		origins:  make(map[int64]*viber.Message),
+	}
+}
+
+// Start sends the two-way message and opens the session with its receiver, or renews the open one.
+func (m *Manager) Start(message *viber.Message) (int64, error) {
+	if !message.MessageType.IsTwoWay() {
+		return -1, fmt.Errorf("session: message type %s is not two-way", message.MessageType)
+	}
+
+	messageId, err := m.client.SendMessage(message)
+	if err != nil {
+		return messageId, err
+	}
+
+	m.Record(messageId, message)
+	return messageId, nil
+}
+
+// Record opens or renews the session for the two-way message sent by other means (like the outbox).
+// Messages of other types are ignored.
+func (m *Manager) Record(messageId int64, message *viber.Message) {
+	if !message.MessageType.IsTwoWay() {
+		return
+	}
+
+	m.mu.Lock()
+	expired := m.expire()
+
+	now := m.now()
+	key := Key{Phone: message.Receiver, Sender: message.Sender}
+	session, ok := m.sessions[key]
+	if !ok {
+		session = &openSession{Session: Session{Key: key, MessageType: message.MessageType, SourceType: message.SourceType, StartedAt: now}}
+		m.sessions[key] = session
+		heap.Push(&m.queue, session)
+	}
+
+	origin := *message
+	m.origins[messageId] = &origin
+	m.append(session, ThreadMessage{Direction: Outbound, MessageId: messageId, MessageType: message.MessageType, Text: message.Text, At: now})
+	m.mu.Unlock()
+
+	m.notifyExpired(expired)
+}
+
+// Receive adds the user reply to the open session and returns the session.
+// The session is found by the originating message id, or by the phone and the sender of the reply.
+func (m *Manager) Receive(message *viber.InboundMessage) (Session, error) {
+	m.mu.Lock()
+	expired := m.expire()
+
+	key := Key{Phone: message.Phone, Sender: message.Sender}
+	if origin, ok := m.origins[message.MessageId]; ok {
+		key = Key{Phone: origin.Receiver, Sender: origin.Sender}
+	}
+
+	session, ok := m.sessions[key]
+	if !ok {
+		m.mu.Unlock()
+		m.notifyExpired(expired)
+		return Session{}, ErrNoSession
+	}
+
+	at := message.ReceivedAt
+	if at.IsZero() {
+		at = m.now()
+	}
+	m.append(session, ThreadMessage{Direction: Inbound, MessageId: message.MessageId, Text: message.Text, At: at})
+	result := session.copy()
+	m.mu.Unlock()
+
+	m.notifyExpired(expired)
+	return result, nil
+}
+
+// HandleInbound returns a function which adds replies to their sessions and passes them to the handle function.
+// The session is nil if the reply doesn't belong to an open session. Pass it to viber.NewInboundHandler.
+func (m *Manager) HandleInbound(handle func(session *Session, message *viber.InboundMessage) error) func(message *viber.InboundMessage) error {
+	return func(message *viber.InboundMessage) error {
+		session, err := m.Receive(message)
+		if err == ErrNoSession {
+			return handle(nil, message)
+		}
+		if err != nil {
+			return err
+		}
+
+		return handle(&session, message)
+	}
+}
+
+// Reply sends the text to the user in the open session. The reply has the session sender
+// and source type and is two-way, so the user may answer again.
+func (m *Manager) Reply(phone string, sender string, text string) (int64, error) {
+	return m.ReplyMessage(phone, sender, viber.NewMessage().SetMessageType(viber.TextOnly2Way).SetText(text))
+}
+
+// ReplyMessage sends the message to the user in the open session. The receiver, the sender and
+// the source type are taken from the session, and the message type is changed to its two-way version.
+func (m *Manager) ReplyMessage(phone string, sender string, message *viber.Message) (int64, error) {
+	m.mu.Lock()
+	expired := m.expire()
+	session, ok := m.sessions[Key{Phone: phone, Sender: sender}]
+	var sourceType viber.MessageSourceType
+	if ok {
+		sourceType = session.SourceType
+	}
+	m.mu.Unlock()
+	m.notifyExpired(expired)
+
+	if !ok {
+		return -1, ErrNoSession
+	}
+
+	reply := *message
+	reply.Receiver = phone
+	reply.Sender = sender
+	reply.SourceType = sourceType
+	switch reply.MessageType {
+	case viber.TextOnly, 0:
+		reply.MessageType = viber.TextOnly2Way
+	case viber.TextImageButton:
+		reply.MessageType = viber.TextImageButton2Way
+	}
+
+	return m.Start(&reply)
+}
+
+// Get returns the open session with the user.
+func (m *Manager) Get(phone string, sender string) (Session, bool) {
+	m.mu.Lock()
+	expired := m.expire()
+	session, ok := m.sessions[Key{Phone: phone, Sender: sender}]
+	var result Session
+	if ok {
+		result = session.copy()
+	}
+	m.mu.Unlock()
+
+	m.notifyExpired(expired)
+	return result, ok
+}
+
+// Close closes the session with the user. Later replies don't belong to any session.
+func (m *Manager) Close(phone string, sender string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if session, ok := m.sessions[Key{Phone: phone, Sender: sender}]; ok {
+		m.remove(session)
+	}
+}
+
+// Lookup returns the two-way message of an open session by its id, or nil if it is unknown.
+// Pass it to viber.InboundHandler.SetOriginLookup.
+func (m *Manager) Lookup(messageId int64) (*viber.Message, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	origin, ok := m.origins[messageId]
+	if !ok {
+		return nil, nil
+	}
+
+	message := *origin
+	return &message, nil
+}
+
+// Expire closes expired sessions, it is also done by every other call.
+func (m *Manager) Expire() {
+	m.mu.Lock()
+	expired := m.expire()
+	m.mu.Unlock()
+
+	m.notifyExpired(expired)
+}
+
+// append adds the message to the thread and renews the session.
+func (m *Manager) append(session *openSession, message ThreadMessage) {
+	session.Thread = append(session.Thread, message)
+	if extra := len(session.Thread) - m.maxThreadLength(); extra > 0 {
+		for _, dropped := range session.Thread[:extra] {
+			if dropped.Direction == Outbound {
+				delete(m.origins, dropped.MessageId)
+			}
+		}
+		session.Thread = append([]ThreadMessage(nil), session.Thread[extra:]...)
+	}
+
+	session.LastActivityAt = message.At
+	session.ExpiresAt = message.At.Add(m.timeout())
+	heap.Fix(&m.queue, session.index)
+}
+
+// expire removes expired sessions and returns their copies. It must be called under the lock.
+// Sessions are taken from the expiry queue, so open sessions are not checked.
+func (m *Manager) expire() []Session {
+	now := m.now()
+	var expired []Session
+	for len(m.queue) > 0 && !now.Before(m.queue[0].ExpiresAt) {
+		session := m.queue[0]
+		expired = append(expired, session.copy())
+		m.remove(session)
+	}
+
+	return expired
+}
+
+func (m *Manager) remove(session *openSession) {
+	delete(m.sessions, session.Key)
+	heap.Remove(&m.queue, session.index)
+	for _, message := range session.Thread {
+		if message.Direction == Outbound {
+			delete(m.origins, message.MessageId)
+		}
+	}
+}
+
+func (m *Manager) notifyExpired(expired []Session) {
+	if m.OnExpired == nil {
+		return
+	}
+
+	for _, session := range expired {
+		m.OnExpired(session)
+	}
+}
+
+func (m *Manager) timeout() time.Duration {
+	if m.Timeout <= 0 {
+		return defaultTimeout
+	}
+
+	return m.Timeout
+}
+
+func (m *Manager) maxThreadLength() int {
+	if m.MaxThreadLength <= 0 {
+		return defaultMaxThreadLength
+	}
+
+	return m.MaxThreadLength
+}
+
+func (m *Manager) now() time.Time {
+	if m.Now != nil {
+		return m.Now()
+	}
+
+	return time.Now()
+}
+
+func (s *Session) copy() Session {
+	c := *s
+	c.Thread = append([]ThreadMessage(nil), s.Thread...)
+	return c
+}
+
+// expiryQueue is a min-heap of open sessions by the expiration time. It implements heap.Interface.
+type expiryQueue []*openSession
+
+func (q expiryQueue) Len() int {
+	return len(q)
+}
+
+func (q expiryQueue) Less(i, j int) bool {
+	return q[i].ExpiresAt.Before(q[j].ExpiresAt)
+}
+
+func (q expiryQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *expiryQueue) Push(x interface{}) {
+	session := x.(*openSession)
+	session.index = len(*q)
+	*q = append(*q, session)
+}
+
+func (q *expiryQueue) Pop() interface{} {
+	old := *q
+	session := old[len(old)-1]
+	old[len(old)-1] = nil
+	*q = old[:len(old)-1]
+	return session
+}
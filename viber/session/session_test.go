@@ -0,0 +1,198 @@
+package session_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber/session"
+)
+
+var _ session.Sender = viber.NewClient("<YOUR_API_KEY>")
+
+type fakeSender struct {
+	mu     sync.Mutex
+	sent   []*viber.Message
+	nextId int64
+}
+
+func (s *fakeSender) SendMessage(message *viber.Message) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sent = append(s.sent, message)
+	s.nextId++
+	return 100 + s.nextId, nil
+}
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newTwoWayMessage(text string) *viber.Message {
+	return viber.NewMessage().SetSender("Sender").SetReceiver("380504444444").SetMessageType(viber.TextImageButton2Way).
+		SetSourceType(viber.Transactional).SetText(text).SetImageUrl("https://yourdomain.com/image.png").
+		SetButtonCaption("Open").SetButtonAction("https://yourdomain.com")
+}
+
+func TestConversation(t *testing.T) {
+	sender := &fakeSender{}
+	c := &clock{now: time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)}
+	manager := session.New(sender)
+	manager.Now = c.Now
+
+	messageId, err := manager.Start(newTwoWayMessage("Reply 1 to confirm"))
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	// the reply is linked by the originating message id
+	c.now = c.now.Add(time.Minute)
+	s, err := manager.Receive(&viber.InboundMessage{MessageId: messageId, Text: "1", ReceivedAt: c.now})
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+	if s.Phone != "380504444444" || s.Sender != "Sender" || len(s.Thread) != 2 {
+		t.Errorf("FAIL. Unexpected session '%+v'", s)
+	}
+
+	if _, err := manager.Reply("380504444444", "Sender", "Confirmed"); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	reply := sender.sent[1]
+	if reply.Sender != "Sender" || reply.Receiver != "380504444444" || reply.MessageType != viber.TextOnly2Way ||
+		reply.SourceType != viber.Transactional || reply.Text != "Confirmed" {
+		t.Errorf("FAIL. Unexpected reply '%+v'", reply)
+	}
+
+	// the reply without the message id is linked by the phone and the sender
+	s, err = manager.Receive(&viber.InboundMessage{Phone: "380504444444", Sender: "Sender", Text: "Thanks"})
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	expected := []session.Direction{session.Outbound, session.Inbound, session.Outbound, session.Inbound}
+	if len(s.Thread) != len(expected) {
+		t.Fatalf("FAIL. Expected thread of %d messages, but got '%+v'", len(expected), s.Thread)
+	}
+	for i, message := range s.Thread {
+		if message.Direction != expected[i] {
+			t.Errorf("FAIL. Expected message %d direction '%s', but got '%s'", i, expected[i], message.Direction)
+		}
+	}
+
+	if origin, _ := manager.Lookup(messageId); origin == nil || origin.Text != "Reply 1 to confirm" {
+		t.Errorf("FAIL. Expected origin message, but got '%+v'", origin)
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	c := &clock{now: time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)}
+	var expired []session.Session
+	manager := session.New(&fakeSender{})
+	manager.Now = c.Now
+	manager.Timeout = time.Hour
+	manager.OnExpired = func(s session.Session) {
+		expired = append(expired, s)
+	}
+
+	messageId, _ := manager.Start(newTwoWayMessage("Reply 1 to confirm"))
+
+	// activity renews the session
+	c.now = c.now.Add(50 * time.Minute)
+	if _, err := manager.Receive(&viber.InboundMessage{MessageId: messageId, Text: "1"}); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	c.now = c.now.Add(50 * time.Minute)
+	if _, ok := manager.Get("380504444444", "Sender"); !ok {
+		t.Fatalf("FAIL. Expected session to be open")
+	}
+
+	c.now = c.now.Add(11 * time.Minute)
+	if _, err := manager.Receive(&viber.InboundMessage{MessageId: messageId, Text: "2"}); err != session.ErrNoSession {
+		t.Errorf("FAIL. Expected no session error, but got '%v'", err)
+	}
+	if _, err := manager.Reply("380504444444", "Sender", "Too late"); err != session.ErrNoSession {
+		t.Errorf("FAIL. Expected no session error, but got '%v'", err)
+	}
+	if origin, _ := manager.Lookup(messageId); origin != nil {
+		t.Errorf("FAIL. Expected origin of expired session to be forgotten")
+	}
+
+	if len(expired) != 1 || len(expired[0].Thread) != 2 {
+		t.Errorf("FAIL. Expected 1 expired session with 2 messages, but got '%+v'", expired)
+	}
+}
+
+func TestStartRequiresTwoWayMessage(t *testing.T) {
+	manager := session.New(&fakeSender{})
+
+	message := newTwoWayMessage("Hello").SetMessageType(viber.TextOnly)
+	if _, err := manager.Start(message); err == nil {
+		t.Errorf("FAIL. Expected error for one-way message")
+	}
+
+	if _, ok := manager.Get("380504444444", "Sender"); ok {
+		t.Errorf("FAIL. Expected no session for one-way message")
+	}
+}
+
+func TestHandleInbound(t *testing.T) {
+	manager := session.New(&fakeSender{})
+	manager.Start(newTwoWayMessage("Reply 1 to confirm"))
+
+	var sessions []*session.Session
+	handle := manager.HandleInbound(func(s *session.Session, message *viber.InboundMessage) error {
+		sessions = append(sessions, s)
+		return nil
+	})
+
+	handle(&viber.InboundMessage{Phone: "380504444444", Sender: "Sender", Text: "1"})
+	handle(&viber.InboundMessage{Phone: "380505555555", Sender: "Sender", Text: "1"})
+
+	if len(sessions) != 2 || sessions[0] == nil || sessions[1] != nil {
+		t.Errorf("FAIL. Expected reply of known user to have a session and unknown one not, but got '%+v'", sessions)
+	}
+}
+
+func TestExpireManySessions(t *testing.T) {
+	c := &clock{now: time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)}
+	var expired []string
+	manager := session.New(&fakeSender{})
+	manager.Now = c.Now
+	manager.Timeout = time.Hour
+	manager.OnExpired = func(s session.Session) {
+		expired = append(expired, s.Phone)
+	}
+
+	// sessions are started a minute apart, the first one is renewed later
+	phones := []string{"380500000001", "380500000002", "380500000003", "380500000004"}
+	for _, phone := range phones {
+		manager.Start(newTwoWayMessage("Hello").SetReceiver(phone))
+		c.now = c.now.Add(time.Minute)
+	}
+	if _, err := manager.Reply(phones[0], "Sender", "Still there?"); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	c.now = c.now.Add(58 * time.Minute)
+	manager.Expire()
+
+	expected := []string{phones[1], phones[2]}
+	if len(expired) != len(expected) || expired[0] != expected[0] || expired[1] != expected[1] {
+		t.Errorf("FAIL. Expected sessions '%v' to expire in order, but got '%v'", expected, expired)
+	}
+
+	for _, phone := range []string{phones[0], phones[3]} {
+		if _, ok := manager.Get(phone, "Sender"); !ok {
+			t.Errorf("FAIL. Expected session with '%s' to be open", phone)
+		}
+	}
+}
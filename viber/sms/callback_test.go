@@ -0,0 +1,70 @@
+package sms_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+func TestCallbackHandler(t *testing.T) {
+	var inputData = []struct {
+		name          string
+		body          string
+		expectedCode  int
+		expectedEvent *sms.CallbackEvent
+	}{
+		{
+			"delivered through Viber",
+			`{"message_id":429,"status":1}`,
+			http.StatusOK,
+			&sms.CallbackEvent{MessageId: 429, Status: viber.Delivered},
+		},
+		{
+			"delivered through SMS",
+			`{"message_id":429,"status":4,"sms_message_id":22,"sms_message_status":2}`,
+			http.StatusOK,
+			&sms.CallbackEvent{MessageId: 429, Status: viber.Undelivered, SmsMessageId: 22, SmsMessageStatus: sms.SmsDelivered},
+		},
+		{"missing SMS id", `{"message_id":429,"status":4,"sms_message_status":2}`, http.StatusBadRequest, nil},
+		{"unknown SMS status", `{"message_id":429,"status":4,"sms_message_id":22,"sms_message_status":9}`, http.StatusBadRequest, nil},
+		{"missing status", `{"message_id":429,"sms_message_id":22,"sms_message_status":2}`, http.StatusBadRequest, nil},
+		{"malformed payload", `not a json`, http.StatusBadRequest, nil},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			var received *sms.CallbackEvent
+			handler := sms.NewCallbackHandler(func(event *sms.CallbackEvent) error {
+				received = event
+				return nil
+			})
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/viber-callback", strings.NewReader(input.body)))
+
+			if recorder.Code != input.expectedCode {
+				t.Errorf("FAIL. Expected response code '%d', but got '%d'", input.expectedCode, recorder.Code)
+			}
+
+			if input.expectedEvent == nil {
+				if received != nil {
+					t.Errorf("FAIL. Expected no event, but got '%+v'", received)
+				}
+				return
+			}
+
+			if received == nil {
+				t.Fatalf("FAIL. Expected event '%+v', but got nil", input.expectedEvent)
+			}
+
+			received.ReceivedAt = input.expectedEvent.ReceivedAt
+			if *received != *input.expectedEvent {
+				t.Errorf("FAIL. Expected event '%+v', but got '%+v'", input.expectedEvent, received)
+			}
+		})
+	}
+}
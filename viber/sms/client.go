@@ -1,6 +1,7 @@
 package sms
 
 import (
+	"context"
 	"encoding/json"
 
 	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
@@ -39,6 +40,12 @@ type MessageReceipt struct {
 	SmsMessageStatus SmsMessageStatus    `json:"sms_message_status"` // SMS message status (if available, only for transactional messages)
 }
 
+// MessageReceiptResult holds the receipt of the particular Viber plus SMS message or the error occurred while getting it.
+type MessageReceiptResult struct {
+	Receipt *MessageReceipt
+	Err     error
+}
+
 // Message represents a Viber plus SMS message.
 type Message struct {
 	viber.Message
@@ -66,6 +73,7 @@ func NewClient(apiKey string) *Client {
 		base: &internal.BaseClient{
 			ApiKey:              apiKey,
 			ParseViberErrorFunc: parseViberError,
+			BatchOptions:        internal.NewBatchOptions(viber.IsRateLimitError),
 		},
 	}
 }
@@ -85,6 +93,33 @@ func (client *Client) GetMessageStatus(messageId int64) (*MessageReceipt, error)
 	return messageReceipt, nil
 }
 
+// SetBatchLimits sets the maximum number of simultaneous requests and the maximum
+// number of requests per second used by GetMessageStatuses. Zero requestsPerSecond means no rate limit.
+func (client *Client) SetBatchLimits(concurrency int, requestsPerSecond float64) *Client {
+	client.base.BatchOptions.Concurrency = concurrency
+	client.base.BatchOptions.RequestsPerSecond = requestsPerSecond
+	return client
+}
+
+// GetMessageStatuses returns statuses of many Viber plus SMS messages.
+// Requests are sent concurrently (see SetBatchLimits), duplicate ids are requested once and
+// requests rejected because of the rate limit are retried. Returned map contains a result for every
+// requested id. If the context is done before all statuses are received, remaining ids get
+// the context error, which is returned as well.
+func (client *Client) GetMessageStatuses(ctx context.Context, messageIds []int64) (map[int64]MessageReceiptResult, error) {
+	results, err := client.base.GetMessageStatusResponses(ctx, messageIds, func() interface{} {
+		return &MessageReceipt{}
+	})
+
+	receipts := make(map[int64]MessageReceiptResult, len(results))
+	for id, result := range results {
+		receipt, _ := result.Value.(*MessageReceipt)
+		receipts[id] = MessageReceiptResult{Receipt: receipt, Err: result.Err}
+	}
+
+	return receipts, err
+}
+
 func parseViberError(responseBody []byte) error {
 	var viberError viber.Error
 	if err := json.Unmarshal(responseBody, &viberError); err != nil {
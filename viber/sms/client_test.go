@@ -1,7 +1,10 @@
 package sms_test
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"testing"
 
 	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
@@ -110,3 +113,50 @@ func TestGetViberPlusSmsMessageStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestGetViberPlusSmsMessageStatuses(t *testing.T) {
+	var responses = map[int64]string{
+		1: `{"message_id":1,"status":1}`,
+		2: `{"message_id":2,"status":4,"sms_message_id":22,"sms_message_status":2}`,
+	}
+
+	var expectedReceipts = map[int64]sms.MessageReceipt{
+		1: {MessageId: 1, Status: viber.Delivered},
+		2: {MessageId: 2, Status: viber.Undelivered, SmsMessageId: 22, SmsMessageStatus: sms.SmsDelivered},
+	}
+
+	client := sms.NewClient("").SetBatchLimits(2, 0)
+
+	httpmock.Activate()
+	defer httpmock.DeactivateAndReset()
+
+	httpmock.RegisterResponder("POST", "https://web.it-decision.com/v1/api/receive-viber",
+		func(req *http.Request) (*http.Response, error) {
+			var request map[string]int64
+			if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+				return nil, err
+			}
+
+			return httpmock.NewStringResponse(200, responses[request["message_id"]]), nil
+		})
+
+	results, err := client.GetMessageStatuses(context.Background(), []int64{2, 1, 2})
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	if len(results) != len(expectedReceipts) {
+		t.Errorf("FAIL. Expected %d results, but got %d", len(expectedReceipts), len(results))
+	}
+
+	for id, expectedReceipt := range expectedReceipts {
+		result := results[id]
+		if result.Err != nil || result.Receipt == nil || *result.Receipt != expectedReceipt {
+			t.Errorf("FAIL. Expected receipt '%+v' for message %d, but got '%+v'", expectedReceipt, id, result)
+		}
+	}
+
+	if calls := httpmock.GetTotalCallCount(); calls != 2 {
+		t.Errorf("FAIL. Expected 2 requests, but got %d", calls)
+	}
+}
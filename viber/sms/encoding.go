@@ -0,0 +1,67 @@
+package sms
+
+import (
+	"encoding/json"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/internal/enum"
+)
+
+var smsMessageStatusNames = enum.Names{
+	"Delivered":     int64(SmsDelivered),
+	"Expired":       int64(SmsExpired),
+	"Undeliverable": int64(SmsUndeliverable),
+}
+
+var smsMessageStatusAliases = enum.Names{
+	"SmsDelivered":     int64(SmsDelivered),
+	"SmsExpired":       int64(SmsExpired),
+	"SmsUndeliverable": int64(SmsUndeliverable),
+}
+
+// ParseSmsMessageStatus parses the SMS message status from its name (like "Delivered") or numeric code (like "2").
+func ParseSmsMessageStatus(text string) (SmsMessageStatus, error) {
+	code, err := enum.Parse(text, "SMS message status", 16, true, smsMessageStatusNames, smsMessageStatusAliases)
+	return SmsMessageStatus(code), err
+}
+
+// MarshalText implements encoding.TextMarshaler interface.
+// Known statuses are encoded as names, unknown ones as numeric codes.
+func (s SmsMessageStatus) MarshalText() ([]byte, error) {
+	return smsMessageStatusNames.Text(int64(s)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler interface. It accepts names and numeric codes.
+func (s *SmsMessageStatus) UnmarshalText(text []byte) error {
+	status, err := ParseSmsMessageStatus(string(text))
+	if err != nil {
+		return err
+	}
+
+	*s = status
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface. It accepts names and numeric codes.
+func (s *SmsMessageStatus) UnmarshalJSON(data []byte) error {
+	return enum.UnmarshalJSON(data, s.UnmarshalText)
+}
+
+// MarshalJSON implements json.Marshaler interface. The message is encoded in the Viber API format.
+// It is required because the embedded viber.Message implements json.Marshaler as well.
+func (m Message) MarshalJSON() ([]byte, error) {
+	content, err := json.Marshal(m.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil, err
+	}
+
+	if fields["text_sms"], err = json.Marshal(m.SmsText); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(fields)
+}
@@ -0,0 +1,68 @@
+package sms_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+func TestMessageReceiptJson(t *testing.T) {
+	receipt := sms.MessageReceipt{MessageId: 429, Status: viber.Undelivered, SmsMessageId: 22, SmsMessageStatus: sms.SmsExpired}
+
+	content, err := json.Marshal(receipt)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	expectedJson := `{"message_id":429,"status":"Undelivered","sms_message_id":22,"sms_message_status":"Expired"}`
+	if string(content) != expectedJson {
+		t.Errorf("FAIL. Expected JSON '%s', but got '%s'", expectedJson, content)
+	}
+
+	for _, data := range []string{
+		expectedJson,
+		`{"message_id":429,"status":4,"sms_message_id":22,"sms_message_status":3}`,
+		`{"message_id":429,"status":"undelivered","sms_message_id":22,"sms_message_status":"SmsExpired"}`,
+	} {
+		var decoded sms.MessageReceipt
+		if err := json.Unmarshal([]byte(data), &decoded); err != nil {
+			t.Fatalf("FAIL. Unexpected error '%v'", err)
+		}
+
+		if decoded != receipt {
+			t.Errorf("FAIL. Expected receipt '%+v', but got '%+v'", receipt, decoded)
+		}
+	}
+}
+
+func TestMessageWireFormat(t *testing.T) {
+	message := sms.NewMessage()
+	message.SetMessageType(viber.TextOnly)
+	message.SetSourceType(viber.Transactional)
+	message.SetSmsText("SMS Message")
+
+	content, err := json.Marshal(message)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(content, &fields); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	if fields["message_type"] != float64(106) || fields["source_type"] != float64(2) || fields["text_sms"] != "SMS Message" {
+		t.Errorf("FAIL. Expected Viber API format, but got '%s'", content)
+	}
+
+	var decoded sms.Message
+	if err := json.Unmarshal(content, &decoded); err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	if decoded != *message {
+		t.Errorf("FAIL. Expected message '%+v', but got '%+v'", *message, decoded)
+	}
+}
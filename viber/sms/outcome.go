@@ -0,0 +1,135 @@
+package sms
+
+import (
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/internal/enum"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+)
+
+// Outcome represents which channel ultimately delivered the Viber plus SMS message.
+// It is the outcome of fallback.Attempt as well.
+type Outcome uint16
+
+const (
+	InProgress        Outcome = iota // InProgress means the final outcome is not known yet.
+	DeliveredViaViber                // DeliveredViaViber means the Viber message was delivered.
+	DeliveredViaSms                  // DeliveredViaSms means the Viber message was not delivered and the SMS message was.
+	NotDelivered                     // NotDelivered means neither the Viber message nor the SMS message was delivered.
+	Unconfirmed                      // Unconfirmed means the final status was not received in time. Resolve never returns it.
+)
+
+var outcomeNames = enum.Names{
+	"InProgress":        int64(InProgress),
+	"DeliveredViaViber": int64(DeliveredViaViber),
+	"DeliveredViaSms":   int64(DeliveredViaSms),
+	"NotDelivered":      int64(NotDelivered),
+	"Unconfirmed":       int64(Unconfirmed),
+}
+
+// String returns the outcome description.
+func (o Outcome) String() string {
+	if name, ok := outcomeNames.Name(int64(o)); ok {
+		return name
+	}
+
+	return "Invalid outcome"
+}
+
+// MarshalText implements encoding.TextMarshaler interface.
+func (o Outcome) MarshalText() ([]byte, error) {
+	return outcomeNames.Text(int64(o)), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler interface. It accepts names and numeric codes.
+func (o *Outcome) UnmarshalText(text []byte) error {
+	code, err := enum.Parse(string(text), "outcome", 16, true, outcomeNames)
+	if err != nil {
+		return err
+	}
+
+	*o = Outcome(code)
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler interface. It accepts JSON strings and numbers.
+func (o *Outcome) UnmarshalJSON(data []byte) error {
+	return enum.UnmarshalJSON(data, o.UnmarshalText)
+}
+
+// Resolution represents the resolved outcome of the Viber plus SMS message.
+type Resolution struct {
+	Outcome            Outcome `json:"outcome"`              // Outcome is the channel which delivered the message, or that both failed.
+	FallbackTriggered  bool    `json:"fallback_triggered"`   // FallbackTriggered is true if the SMS message was sent.
+	ViberMessageId     int64   `json:"viber_message_id"`     // ViberMessageId is an id of the Viber message.
+	SmsMessageId       int64   `json:"sms_message_id"`       // SmsMessageId is an id of the SMS message (zero if it was not sent).
+	DeliveredMessageId int64   `json:"delivered_message_id"` // DeliveredMessageId is an id of the delivered message (zero if nothing was delivered).
+}
+
+// IsFinal returns true if the outcome won't change.
+func (r Resolution) IsFinal() bool {
+	return r.Outcome != InProgress
+}
+
+// Resolve interprets the Viber and SMS statuses of the receipt as a single outcome.
+//
+// The Viber message which failed (ErrorStatus, Rejected or Undelivered) without the SMS message
+// is InProgress, since the SMS message may not be attached to the receipt yet.
+// Use ResolveFor if the message is known to be promotional.
+func (r *MessageReceipt) Resolve() Resolution {
+	return r.ResolveFor(viber.Transactional)
+}
+
+// ResolveFor interprets the Viber and SMS statuses of the receipt of the message with the source type as a single outcome.
+// The provider sends SMS messages for transactional messages only, so the failed promotional Viber message
+// resolves to NotDelivered.
+func (r *MessageReceipt) ResolveFor(sourceType viber.MessageSourceType) Resolution {
+	resolution := Resolution{
+		Outcome:           InProgress,
+		FallbackTriggered: r.SmsMessageId > 0,
+		ViberMessageId:    r.MessageId,
+		SmsMessageId:      r.SmsMessageId,
+	}
+
+	if r.Status == viber.Delivered {
+		resolution.Outcome = DeliveredViaViber
+		resolution.DeliveredMessageId = r.MessageId
+		return resolution
+	}
+
+	if !resolution.FallbackTriggered {
+		switch r.Status {
+		case viber.ErrorStatus, viber.Rejected, viber.Undelivered:
+			if sourceType != viber.Transactional {
+				resolution.Outcome = NotDelivered
+			}
+		}
+		return resolution
+	}
+
+	switch r.SmsMessageStatus {
+	case SmsDelivered:
+		resolution.Outcome = DeliveredViaSms
+		resolution.DeliveredMessageId = r.SmsMessageId
+	case SmsExpired, SmsUndeliverable:
+		resolution.Outcome = NotDelivered
+	}
+
+	return resolution
+}
+
+// Resolve interprets the Viber and SMS statuses of the callback as a single outcome, like MessageReceipt.Resolve.
+func (e *CallbackEvent) Resolve() Resolution {
+	return e.ResolveFor(viber.Transactional)
+}
+
+// ResolveFor interprets the Viber and SMS statuses of the callback of the message with the source type
+// as a single outcome, like MessageReceipt.ResolveFor.
+func (e *CallbackEvent) ResolveFor(sourceType viber.MessageSourceType) Resolution {
+	receipt := MessageReceipt{
+		MessageId:        e.MessageId,
+		Status:           e.Status,
+		SmsMessageId:     e.SmsMessageId,
+		SmsMessageStatus: e.SmsMessageStatus,
+	}
+
+	return receipt.ResolveFor(sourceType)
+}
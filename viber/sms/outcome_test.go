@@ -0,0 +1,99 @@
+package sms_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+func TestResolveReceipt(t *testing.T) {
+	var inputData = []struct {
+		name                string
+		receipt             sms.MessageReceipt
+		expectedOutcome     sms.Outcome
+		expectedFallback    bool
+		expectedDeliveredId int64
+	}{
+		{"delivered via Viber", sms.MessageReceipt{MessageId: 429, Status: viber.Delivered}, sms.DeliveredViaViber, false, 429},
+		{"Viber sent", sms.MessageReceipt{MessageId: 429, Status: viber.Sent}, sms.InProgress, false, 0},
+		{"Viber pending", sms.MessageReceipt{MessageId: 429, Status: viber.Pending}, sms.InProgress, false, 0},
+		{"Viber failed before SMS is attached", sms.MessageReceipt{MessageId: 429, Status: viber.Rejected}, sms.InProgress, false, 0},
+		{"SMS sent", sms.MessageReceipt{MessageId: 429, Status: viber.Undelivered, SmsMessageId: 22}, sms.InProgress, true, 0},
+		{"delivered via SMS", sms.MessageReceipt{MessageId: 429, Status: viber.Undelivered, SmsMessageId: 22, SmsMessageStatus: sms.SmsDelivered}, sms.DeliveredViaSms, true, 22},
+		{"SMS expired", sms.MessageReceipt{MessageId: 429, Status: viber.ErrorStatus, SmsMessageId: 22, SmsMessageStatus: sms.SmsExpired}, sms.NotDelivered, true, 0},
+		{"SMS undeliverable", sms.MessageReceipt{MessageId: 429, Status: viber.Undelivered, SmsMessageId: 22, SmsMessageStatus: sms.SmsUndeliverable}, sms.NotDelivered, true, 0},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			resolution := input.receipt.Resolve()
+
+			if resolution.Outcome != input.expectedOutcome {
+				t.Errorf("FAIL. Expected outcome '%s', but got '%s'", input.expectedOutcome, resolution.Outcome)
+			}
+			if resolution.FallbackTriggered != input.expectedFallback {
+				t.Errorf("FAIL. Expected fallback triggered: %t, but got %t", input.expectedFallback, resolution.FallbackTriggered)
+			}
+			if resolution.DeliveredMessageId != input.expectedDeliveredId {
+				t.Errorf("FAIL. Expected delivered message id '%d', but got '%d'", input.expectedDeliveredId, resolution.DeliveredMessageId)
+			}
+			if resolution.ViberMessageId != input.receipt.MessageId || resolution.SmsMessageId != input.receipt.SmsMessageId {
+				t.Errorf("FAIL. Unexpected ids in '%+v'", resolution)
+			}
+			if resolution.IsFinal() != (input.expectedOutcome != sms.InProgress) {
+				t.Errorf("FAIL. Unexpected final flag of '%+v'", resolution)
+			}
+
+			event := sms.CallbackEvent{MessageId: input.receipt.MessageId, Status: input.receipt.Status,
+				SmsMessageId: input.receipt.SmsMessageId, SmsMessageStatus: input.receipt.SmsMessageStatus}
+			if event.Resolve() != resolution {
+				t.Errorf("FAIL. Expected callback resolution '%+v', but got '%+v'", resolution, event.Resolve())
+			}
+		})
+	}
+}
+
+func TestResolvePolledBeforeSms(t *testing.T) {
+	receipts := []sms.MessageReceipt{
+		{MessageId: 429, Status: viber.Undelivered},
+		{MessageId: 429, Status: viber.Undelivered, SmsMessageId: 22},
+		{MessageId: 429, Status: viber.Undelivered, SmsMessageId: 22, SmsMessageStatus: sms.SmsDelivered},
+	}
+	expectedOutcomes := []sms.Outcome{sms.InProgress, sms.InProgress, sms.DeliveredViaSms}
+
+	for i, receipt := range receipts {
+		if outcome := receipt.Resolve().Outcome; outcome != expectedOutcomes[i] {
+			t.Errorf("FAIL. Expected outcome '%s' of poll %d, but got '%s'", expectedOutcomes[i], i+1, outcome)
+		}
+	}
+
+	promotional := sms.MessageReceipt{MessageId: 429, Status: viber.Undelivered}
+	if outcome := promotional.ResolveFor(viber.Promotional).Outcome; outcome != sms.NotDelivered {
+		t.Errorf("FAIL. Expected outcome '%s' of the promotional message, but got '%s'", sms.NotDelivered, outcome)
+	}
+	event := sms.CallbackEvent{MessageId: 429, Status: viber.Undelivered}
+	if outcome := event.ResolveFor(viber.Promotional).Outcome; outcome != sms.NotDelivered {
+		t.Errorf("FAIL. Expected outcome '%s' of the promotional callback, but got '%s'", sms.NotDelivered, outcome)
+	}
+}
+
+func TestResolutionJson(t *testing.T) {
+	resolution := sms.Resolution{Outcome: sms.DeliveredViaSms, FallbackTriggered: true, ViberMessageId: 429, SmsMessageId: 22, DeliveredMessageId: 22}
+
+	content, err := json.Marshal(resolution)
+	if err != nil {
+		t.Fatalf("FAIL. Unexpected error '%v'", err)
+	}
+
+	expectedJson := `{"outcome":"DeliveredViaSms","fallback_triggered":true,"viber_message_id":429,"sms_message_id":22,"delivered_message_id":22}`
+	if string(content) != expectedJson {
+		t.Errorf("FAIL. Expected JSON '%s', but got '%s'", expectedJson, content)
+	}
+
+	var decoded sms.Resolution
+	if err := json.Unmarshal([]byte(`{"outcome":3}`), &decoded); err != nil || decoded.Outcome != sms.NotDelivered {
+		t.Errorf("FAIL. Expected outcome NotDelivered, but got '%s' (error '%v')", decoded.Outcome, err)
+	}
+}
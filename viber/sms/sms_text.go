@@ -0,0 +1,117 @@
+package sms
+
+import (
+	"strings"
+	"unicode"
+
+	plainsms "github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+)
+
+// SmsTextOptions represents how the SMS text is derived from the Viber message.
+type SmsTextOptions struct {
+	AppendLink    bool // AppendLink appends the button action URL to the text.
+	Transliterate bool // Transliterate replaces Cyrillic letters with Latin ones to send the text in GSM-7.
+	MaxSegments   int  // MaxSegments is a maximum number of SMS segments (no limit if zero).
+}
+
+// SmsText represents the SMS text derived from the Viber message.
+type SmsText struct {
+	Text        string            // Text is the derived SMS text.
+	Segments    int               // Segments is the number of SMS segments the text is sent in.
+	Encoding    plainsms.Encoding // Encoding is the encoding the text is sent in.
+	Truncated   bool              // Truncated is true if the text was trimmed to fit MaxSegments.
+	Cut         string            // Cut is the trimmed part of the SMS text, transliterated if Transliterate is set.
+	LinkDropped bool              // LinkDropped is true if the link was not appended because it doesn't fit MaxSegments.
+}
+
+// GenerateSmsText derives the SMS text from the Viber message text. If the text doesn't fit the maximum
+// number of segments, it is trimmed at a word boundary and the link (if any) is kept whole.
+// The link is dropped only if it doesn't fit on its own.
+func GenerateSmsText(message *viber.Message, options SmsTextOptions) SmsText {
+	body := strings.TrimSpace(message.Text)
+	if options.Transliterate {
+		body = plainsms.Transliterate(body)
+	}
+
+	link := ""
+	if options.AppendLink {
+		link = strings.TrimSpace(message.ButtonAction)
+	}
+
+	result := SmsText{}
+	suffix := ""
+	if link != "" {
+		suffix = link
+		if body != "" {
+			suffix = " " + link
+		}
+		if !fits(link, options.MaxSegments) {
+			result.LinkDropped = true
+			suffix = ""
+		}
+	}
+
+	if !fits(body+suffix, options.MaxSegments) {
+		kept, cut := trimToFit(body, suffix, options.MaxSegments)
+		if kept == "" && suffix != "" {
+			// nothing of the text fits with the link, so the link alone is sent
+			suffix = link
+		}
+		body = kept
+		result.Truncated = true
+		result.Cut = cut
+	}
+
+	result.Text = body + suffix
+	result.Segments, result.Encoding = plainsms.Segments(result.Text)
+	return result
+}
+
+// DeriveSmsText derives the SMS text from the Viber message text (see GenerateSmsText) and sets it as SmsText.
+func (m *Message) DeriveSmsText(options SmsTextOptions) SmsText {
+	result := GenerateSmsText(&m.Message, options)
+	m.SmsText = result.Text
+	return result
+}
+
+func fits(text string, maxSegments int) bool {
+	if maxSegments <= 0 {
+		return true
+	}
+
+	segments, _ := plainsms.Segments(text)
+	return segments <= maxSegments
+}
+
+// trimToFit returns the longest beginning of the text which fits the maximum number of segments
+// together with the suffix, and the trimmed rest of the text.
+func trimToFit(text, suffix string, maxSegments int) (string, string) {
+	runes := []rune(text)
+
+	// the number of segments never decreases as the text grows, so the longest fitting beginning is searched
+	low, high := 0, len(runes)
+	for low < high {
+		middle := (low + high + 1) / 2
+		if fits(string(runes[:middle])+suffix, maxSegments) {
+			low = middle
+		} else {
+			high = middle - 1
+		}
+	}
+
+	end := low
+	if end < len(runes) && !unicode.IsSpace(runes[end]) {
+		// step back to the last word boundary, the word is cut only if it is the only one
+		for i := end - 1; i > 0; i-- {
+			if unicode.IsSpace(runes[i]) {
+				end = i
+				break
+			}
+		}
+	}
+
+	kept := strings.TrimRightFunc(string(runes[:end]), unicode.IsSpace)
+	cut := strings.TrimSpace(string(runes[end:]))
+	return kept, cut
+}
@@ -0,0 +1,76 @@
+package sms_test
+
+import (
+	"strings"
+	"testing"
+
+	plainsms "github.com/IT-DecisionTelecom/decisiontelecom-go/sms"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber"
+	"github.com/IT-DecisionTelecom/decisiontelecom-go/viber/sms"
+)
+
+func TestGenerateSmsText(t *testing.T) {
+	longText := strings.Repeat("word ", 40) + "end"
+	link := "https://example.com/offer"
+
+	var inputData = []struct {
+		name                string
+		text                string
+		buttonAction        string
+		options             sms.SmsTextOptions
+		expectedText        string
+		expectedSegments    int
+		expectedEncoding    plainsms.Encoding
+		expectedTruncated   bool
+		expectedLinkDropped bool
+	}{
+		{"plain text", "Your code is 1234", "", sms.SmsTextOptions{}, "Your code is 1234", 1, plainsms.Gsm7, false, false},
+		{"link appended", "Big sale", link, sms.SmsTextOptions{AppendLink: true}, "Big sale " + link, 1, plainsms.Gsm7, false, false},
+		{"link not requested", "Big sale", link, sms.SmsTextOptions{}, "Big sale", 1, plainsms.Gsm7, false, false},
+		{"transliterated", "Привіт, Юрію", "", sms.SmsTextOptions{Transliterate: true}, "Pryvit, Yuriiu", 1, plainsms.Gsm7, false, false},
+		{"not transliterated", "Привіт", "", sms.SmsTextOptions{}, "Привіт", 1, plainsms.Ucs2, false, false},
+		{"no limit", longText, "", sms.SmsTextOptions{}, longText, 2, plainsms.Gsm7, false, false},
+		{"trimmed at word", longText, "", sms.SmsTextOptions{MaxSegments: 1}, strings.TrimSpace(strings.Repeat("word ", 32)), 1, plainsms.Gsm7, true, false},
+		{"trimmed with link", longText, link, sms.SmsTextOptions{AppendLink: true, MaxSegments: 1}, strings.TrimSpace(strings.Repeat("word ", 27)) + " " + link, 1, plainsms.Gsm7, true, false},
+		{"hard cut", strings.Repeat("a", 200), "", sms.SmsTextOptions{MaxSegments: 1}, strings.Repeat("a", 160), 1, plainsms.Gsm7, true, false},
+		{"link dropped", "Sale", "https://example.com/" + strings.Repeat("a", 200), sms.SmsTextOptions{AppendLink: true, MaxSegments: 1}, "Sale", 1, plainsms.Gsm7, false, true},
+	}
+
+	for _, input := range inputData {
+		t.Run(input.name, func(t *testing.T) {
+			message := viber.NewMessage().SetText(input.text).SetButtonAction(input.buttonAction)
+			result := sms.GenerateSmsText(message, input.options)
+
+			if result.Text != input.expectedText {
+				t.Errorf("FAIL. Expected text '%s', but got '%s'", input.expectedText, result.Text)
+			}
+			if result.Segments != input.expectedSegments || result.Encoding != input.expectedEncoding {
+				t.Errorf("FAIL. Expected %d %s segments, but got %d %s", input.expectedSegments, input.expectedEncoding, result.Segments, result.Encoding)
+			}
+			if result.Truncated != input.expectedTruncated {
+				t.Errorf("FAIL. Expected truncated: %t, but got %t", input.expectedTruncated, result.Truncated)
+			}
+			if result.LinkDropped != input.expectedLinkDropped {
+				t.Errorf("FAIL. Expected link dropped: %t, but got %t", input.expectedLinkDropped, result.LinkDropped)
+			}
+			if result.Truncated && result.Cut == "" {
+				t.Errorf("FAIL. Expected the cut part to be reported")
+			}
+		})
+	}
+}
+
+func TestDeriveSmsText(t *testing.T) {
+	message := sms.NewMessage()
+	message.SetText("Знижка 20% на все").SetButtonAction("https://example.com")
+
+	result := message.DeriveSmsText(sms.SmsTextOptions{AppendLink: true, Transliterate: true, MaxSegments: 1})
+
+	expected := "Znyzhka 20% na vse https://example.com"
+	if message.SmsText != expected || result.Text != expected {
+		t.Errorf("FAIL. Expected SMS text '%s', but got '%s'", expected, message.SmsText)
+	}
+	if result.Cut != "" {
+		t.Errorf("FAIL. Expected nothing to be cut, but got '%s'", result.Cut)
+	}
+}